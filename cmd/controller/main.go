@@ -17,15 +17,63 @@ limitations under the License.
 package main
 
 import (
+	"log"
+	"os"
+	"strconv"
+
 	// The set of controllers this controller process runs.
+	filteredFactory "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/filtered"
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
 
 	// This defines the shared main for injected controllers.
 	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
+)
+
+// These override the ingress class this controller reconciles and the name
+// of its gateway config map, so that several controllers can run side by side.
+const (
+	ingressClassEnv      = "INGRESS_CLASS_NAME"
+	gatewayConfigNameEnv = "CONFIG_GATEWAY_NAME"
+)
+
+// These limit the controller to the KIngresses of a comma separated list of
+// namespaces, see ingress.NamespaceScope. hack/generate-namespaced-rbac.sh
+// generates the Roles this takes.
+const (
+	watchNamespacesEnv   = "WATCH_NAMESPACES"
+	gatewayNamespacesEnv = "GATEWAY_NAMESPACES"
+	secretNamespacesEnv  = "SECRET_NAMESPACES"
 )
 
+// debugPortEnv enables the debug endpoint of the state of each KIngress on
+// that port of localhost. Reach it with kubectl port-forward.
+const debugPortEnv = "DEBUG_PORT"
+
 func main() {
-	sharedmain.Main("net-gateway-api-controller",
+	scope, err := ingress.ParseNamespaceScope(os.Getenv(watchNamespacesEnv),
+		os.Getenv(gatewayNamespacesEnv), os.Getenv(secretNamespacesEnv))
+	if err != nil {
+		log.Fatal("Invalid namespace scope: ", err)
+	}
+
+	debugPort := 0
+	if v := os.Getenv(debugPortEnv); v != "" {
+		if debugPort, err = strconv.Atoi(v); err != nil || debugPort < 0 || debugPort > 65535 {
+			log.Fatalf("Invalid %s %q", debugPortEnv, v)
+		}
+	}
+
+	ctx := ingress.WithIngressClass(signals.NewContext(), os.Getenv(ingressClassEnv))
+	ctx = config.WithGatewayConfigMapName(ctx, os.Getenv(gatewayConfigNameEnv))
+	ctx = ingress.WithNamespaceScope(ctx, scope)
+	ctx = ingress.WithDebugPort(ctx, debugPort)
+	// Only the HTTPRoutes and ReferenceGrants we created are watched.
+	ctx = filteredFactory.WithSelectors(ctx, resources.ManagedBySelector)
+
+	sharedmain.MainWithContext(ctx, ingress.ComponentName(ingress.IngressClassFromContext(ctx)),
 		ingress.NewController,
 	)
 }
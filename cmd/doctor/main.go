@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// doctor reports what keeps KIngresses from working, from the YAML or JSON
+// files of a cluster dump rather than from the cluster:
+//
+//	doctor [-class <ingress class>] [-config-gateway <name>] <directory>...
+//
+// The directories hold the KIngresses, HTTPRoutes, Gateways, ReferenceGrants
+// and Endpoints of the cluster, along with the config-gateway and optionally
+// the config-network ConfigMaps, e.g. as exported with
+//
+//	kubectl get kingress,httproutes,gateways,referencegrants,endpoints -A -o yaml
+//
+// It exits with status 1 when it finds problems.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"knative.dev/net-gateway-api/pkg/dump"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+)
+
+func main() {
+	class := flag.String("class", "", "the ingress class of the controller, if not the default one")
+	gatewayConfigName := flag.String("config-gateway", config.GatewayConfigName, "the name of the gateway ConfigMap of the controller")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var objs dump.Objects
+	for _, dir := range flag.Args() {
+		if err := objs.ReadDir(dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	ctx := config.WithGatewayConfigMapName(context.Background(), *gatewayConfigName)
+	cfg, err := objs.Config(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx = config.ToContext(ctx, cfg)
+	ctx = ingress.WithIngressClass(ctx, *class)
+
+	problems, err := ingress.Diagnose(ctx, &objs)
+	if err != nil {
+		log.Fatal("Failed to diagnose: ", err)
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// render prints the HTTPRoutes, ReferenceGrants and Gateway listener patches
+// the controller makes of KIngresses, without a cluster:
+//
+//	render [-config-gateway <name>] -f kingress.yaml -f config-gateway.yaml [-f gateways.yaml]
+//
+// The files hold KIngresses, the config-gateway ConfigMap and optionally the
+// config-network ConfigMap and the Gateways as they are on the cluster. "-"
+// reads from stdin.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"knative.dev/net-gateway-api/pkg/dump"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// files is a flag naming files, given once for each.
+type files []string
+
+func (f *files) String() string     { return strings.Join(*f, ",") }
+func (f *files) Set(v string) error { *f = append(*f, v); return nil }
+
+func main() {
+	var paths files
+	flag.Var(&paths, "f", "a file of KIngresses, ConfigMaps and Gateways, or - for stdin (repeatable)")
+	gatewayConfigName := flag.String("config-gateway", config.GatewayConfigName, "the name of the gateway ConfigMap of the controller")
+	flag.Parse()
+	if len(paths) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var in dump.Objects
+	for _, path := range paths {
+		if err := in.ReadFile(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx := config.WithGatewayConfigMapName(context.Background(), *gatewayConfigName)
+	cfg, err := in.Config(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx = config.ToContext(ctx, cfg)
+
+	out := &printer{w: os.Stdout}
+	for _, ing := range in.Ingresses {
+		r, err := ingress.Render(ctx, ing, in.Gateways)
+		if err != nil {
+			log.Fatalf("Failed to render KIngress %s/%s: %v", ing.Namespace, ing.Name, err)
+		}
+		out.rendering(r)
+	}
+	if out.err != nil {
+		log.Fatal("Failed to print: ", out.err)
+	}
+}
+
+// printer writes renderings as a YAML stream, with what isn't an object in
+// comments. The first error sticks.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *printer) document(comment string, obj interface{}) {
+	data, err := yaml.Marshal(obj)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	p.printf("---\n# %s\n%s", comment, data)
+}
+
+func (p *printer) rendering(r *ingress.Rendering) {
+	p.printf("# KIngress %s/%s\n", r.Ingress.Namespace, r.Ingress.Name)
+	if c := r.Ingress.Status.GetCondition(v1alpha1.IngressConditionReady); c != nil && c.IsFalse() {
+		p.printf("# Not ready: %s: %s\n", c.Reason, c.Message)
+	}
+	for _, event := range r.Events {
+		p.printf("# Event: %s\n", event)
+	}
+
+	for _, route := range r.HTTPRoutes {
+		route.APIVersion, route.Kind = gatewayapi.SchemeGroupVersion.String(), "HTTPRoute"
+		p.document(fmt.Sprintf("HTTPRoute %s/%s", route.Namespace, route.Name), route)
+	}
+	for _, grant := range r.ReferenceGrants {
+		grant.APIVersion, grant.Kind = gatewayv1alpha2.SchemeGroupVersion.String(), "ReferenceGrant"
+		p.document(fmt.Sprintf("ReferenceGrant %s/%s", grant.Namespace, grant.Name), grant)
+	}
+	for _, patch := range r.ListenerPatches {
+		// The patch is JSON, which is YAML too, but harder to review.
+		var ops interface{}
+		if err := yaml.Unmarshal(patch.Patch, &ops); err != nil && p.err == nil {
+			p.err = err
+		}
+		p.document(fmt.Sprintf("JSON patch of the listeners of Gateway %s", patch.Gateway), ops)
+	}
+}
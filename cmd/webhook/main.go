@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"knative.dev/net-gateway-api/pkg/webhook/configmaps"
+
+	// This defines the shared main for injected controllers.
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
+	"knative.dev/pkg/webhook"
+	"knative.dev/pkg/webhook/certificates"
+)
+
+const (
+	componentName = "net-gateway-api-webhook"
+	secretName    = "net-gateway-api-webhook-certs"
+
+	// gatewayConfigNamesEnv is the comma separated list of the names of the
+	// gateway config maps to validate, the CONFIG_GATEWAY_NAME of each
+	// controller.
+	gatewayConfigNamesEnv = "CONFIG_GATEWAY_NAMES"
+)
+
+func main() {
+	ctx := webhook.WithOptions(signals.NewContext(), webhook.Options{
+		ServiceName: componentName,
+		SecretName:  secretName,
+		Port:        webhook.PortFromEnv(8443),
+	})
+
+	var names []string
+	for _, name := range strings.Split(os.Getenv(gatewayConfigNamesEnv), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	ctx = configmaps.WithGatewayConfigNames(ctx, names...)
+
+	sharedmain.MainWithContext(ctx, componentName,
+		certificates.NewController,
+		configmaps.NewSecretController,
+		configmaps.NewController,
+	)
+}
@@ -0,0 +1,131 @@
+#!/usr/bin/env bash
+
+# Copyright 2023 The Knative Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Prints the ServiceAccount, Roles and RoleBindings the controller takes when
+# it is limited to some namespaces, from the same environment variables as the
+# controller:
+#
+#   WATCH_NAMESPACES=team-a,team-b GATEWAY_NAMESPACES=gateways \
+#     ./hack/generate-namespaced-rbac.sh | kubectl apply -f -
+#
+# SECRET_NAMESPACES defaults to WATCH_NAMESPACES. These replace the
+# knative-gateway-api-core ClusterRole of config/200-clusterrole.yaml, which
+# must not be applied: it carries the serving.knative.dev/controller label, so
+# Serving aggregates it into the ClusterRole of its own controller
+# ServiceAccount. That is also why the Roles are bound to a ServiceAccount of
+# their own, which the controller Deployment has to run as:
+#
+#   kubectl -n knative-serving patch deployment net-gateway-api-controller \
+#     -p '{"spec":{"template":{"spec":{"serviceAccountName":"net-gateway-api-controller"}}}}'
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+readonly SERVICE_ACCOUNT="${SERVICE_ACCOUNT:-net-gateway-api-controller}"
+readonly SYSTEM_NAMESPACE="${SYSTEM_NAMESPACE:-knative-serving}"
+readonly WATCH_NAMESPACES="${WATCH_NAMESPACES:?WATCH_NAMESPACES must be set}"
+readonly GATEWAY_NAMESPACES="${GATEWAY_NAMESPACES:?GATEWAY_NAMESPACES must be set}"
+readonly SECRET_NAMESPACES="${SECRET_NAMESPACES:-${WATCH_NAMESPACES}}"
+
+# role NAME NAMESPACES RULES prints a Role with the given rules and its
+# RoleBinding to the controller in each of the comma separated namespaces.
+function role() {
+  local name="$1" namespaces="$2" rules="$3"
+  local ns
+  for ns in ${namespaces//,/ }; do
+    cat <<YAML
+---
+kind: Role
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: ${name}
+  namespace: ${ns}
+  labels:
+    networking.knative.dev/ingress-provider: net-gateway-api
+    app.kubernetes.io/component: net-gateway-api
+    app.kubernetes.io/name: knative-serving
+rules:
+${rules}
+---
+kind: RoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: ${name}
+  namespace: ${ns}
+  labels:
+    networking.knative.dev/ingress-provider: net-gateway-api
+    app.kubernetes.io/component: net-gateway-api
+    app.kubernetes.io/name: knative-serving
+subjects:
+  - kind: ServiceAccount
+    name: ${SERVICE_ACCOUNT}
+    namespace: ${SYSTEM_NAMESPACE}
+roleRef:
+  kind: Role
+  name: ${name}
+  apiGroup: rbac.authorization.k8s.io
+YAML
+  done
+}
+
+cat <<YAML
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ${SERVICE_ACCOUNT}
+  namespace: ${SYSTEM_NAMESPACE}
+  labels:
+    networking.knative.dev/ingress-provider: net-gateway-api
+    app.kubernetes.io/component: net-gateway-api
+    app.kubernetes.io/name: knative-serving
+YAML
+
+# The controller watches its configuration (config-gateway, config-network,
+# config-logging, config-observability, config-tracing) and elects its leader
+# in the system namespace.
+role knative-gateway-api-system "${SYSTEM_NAMESPACE}" '  - apiGroups: [""]
+    resources: ["configmaps"]
+    verbs: ["get", "list", "watch"]
+  - apiGroups: ["coordination.k8s.io"]
+    resources: ["leases"]
+    verbs: ["get", "list", "create", "update", "delete", "patch", "watch"]'
+
+role knative-gateway-api-ingresses "${WATCH_NAMESPACES}" '  - apiGroups: ["networking.internal.knative.dev"]
+    resources: ["ingresses", "ingresses/status", "ingresses/finalizers"]
+    verbs: ["get", "list", "update", "patch", "watch"]
+  - apiGroups: ["gateway.networking.k8s.io"]
+    resources: ["httproutes"]
+    verbs: ["get", "list", "create", "update", "delete", "patch", "watch"]
+  - apiGroups: [""]
+    resources: ["events"]
+    verbs: ["create", "update", "patch"]'
+
+role knative-gateway-api-gateways "${GATEWAY_NAMESPACES}" '  - apiGroups: ["gateway.networking.k8s.io"]
+    resources: ["gateways"]
+    verbs: ["get", "list", "update", "patch", "watch"]
+  - apiGroups: [""]
+    resources: ["endpoints"]
+    verbs: ["get", "list", "watch"]'
+
+role knative-gateway-api-secrets "${SECRET_NAMESPACES}" '  - apiGroups: ["gateway.networking.k8s.io"]
+    resources: ["referencegrants", "referencepolicies"]
+    verbs: ["get", "list", "create", "update", "delete", "patch", "watch"]
+  # Listed and watched as metadata only; referenced Secrets are fetched.
+  - apiGroups: [""]
+    resources: ["secrets"]
+    verbs: ["get", "list", "watch"]'
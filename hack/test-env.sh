@@ -15,6 +15,11 @@
 # limitations under the License.
 
 export GATEWAY_API_VERSION="v0.6.0"
+
+# The unsupported e2e tests are kept by hand. The provider profiles of
+# config-gateway only describe the HTTPRoute filters a provider supports,
+# while most of these tests fail for reasons they don't capture (retries,
+# HTTP/2, websockets, gRPC), so they can't be derived from them.
 export ISTIO_VERSION="1.16.1"
 export ISTIO_UNSUPPORTED_E2E_TESTS="retry,httpoption,host-rewrite"
 export CONTOUR_VERSION="v1.23.0"
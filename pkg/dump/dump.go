@@ -0,0 +1,237 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dump reads the objects the controller deals with from YAML or JSON
+// files, as exported from a cluster with kubectl get -o yaml, for the tools
+// working without one.
+package dump
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	network "knative.dev/networking/pkg"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkcfg "knative.dev/networking/pkg/config"
+)
+
+// Objects are the objects read from the files. Gateway API objects of older
+// versions are converted to the ones the controller uses.
+type Objects struct {
+	Ingresses       []*v1alpha1.Ingress
+	HTTPRoutes      []*gatewayapi.HTTPRoute
+	Gateways        []*gatewayapi.Gateway
+	ReferenceGrants []*gatewayv1alpha2.ReferenceGrant
+	Endpoints       []*corev1.Endpoints
+	// ConfigMaps are keyed by name.
+	ConfigMaps map[string]*corev1.ConfigMap
+}
+
+// ReadDir reads the YAML and JSON files under the directory.
+func (o *Objects) ReadDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			return o.ReadFile(path)
+		}
+		return nil
+	})
+}
+
+// ReadFile reads the objects of a YAML or JSON file, - being stdin.
+func (o *Objects) ReadFile(path string) error {
+	if path == "-" {
+		return o.Read(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := o.Read(f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read adds the objects of a YAML or JSON stream. Objects of other kinds are
+// skipped, so that whole manifests can be fed in, and Lists are unpacked.
+func (o *Objects) Read(r io.Reader) error {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			// An empty document.
+			continue
+		}
+		if err := o.add(raw.Raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (o *Objects) add(data []byte) error {
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+
+	switch gvk := meta.GroupVersionKind(); gvk {
+	case corev1.SchemeGroupVersion.WithKind("List"):
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("invalid List: %w", err)
+		}
+		for _, item := range list.Items {
+			if err := o.add(item); err != nil {
+				return err
+			}
+		}
+
+	case v1alpha1.SchemeGroupVersion.WithKind("Ingress"):
+		ing := &v1alpha1.Ingress{}
+		if err := decode(data, gvk, ing); err != nil {
+			return err
+		}
+		o.Ingresses = append(o.Ingresses, ing)
+
+	case gatewayapi.SchemeGroupVersion.WithKind("HTTPRoute"):
+		route := &gatewayapi.HTTPRoute{}
+		if err := decode(data, gvk, route); err != nil {
+			return err
+		}
+		o.HTTPRoutes = append(o.HTTPRoutes, route)
+
+	case gatewayv1alpha2.SchemeGroupVersion.WithKind("HTTPRoute"):
+		alpha := &gatewayv1alpha2.HTTPRoute{}
+		if err := decode(data, gvk, alpha); err != nil {
+			return err
+		}
+		route, err := resources.HTTPRouteFromV1alpha2(alpha)
+		if err != nil {
+			return err
+		}
+		o.HTTPRoutes = append(o.HTTPRoutes, route)
+
+	case gatewayapi.SchemeGroupVersion.WithKind("Gateway"):
+		gw := &gatewayapi.Gateway{}
+		if err := decode(data, gvk, gw); err != nil {
+			return err
+		}
+		o.Gateways = append(o.Gateways, gw)
+
+	case gatewayv1alpha2.SchemeGroupVersion.WithKind("Gateway"):
+		alpha := &gatewayv1alpha2.Gateway{}
+		if err := decode(data, gvk, alpha); err != nil {
+			return err
+		}
+		gw, err := resources.GatewayFromV1alpha2(alpha)
+		if err != nil {
+			return err
+		}
+		o.Gateways = append(o.Gateways, gw)
+
+	case gatewayv1alpha2.SchemeGroupVersion.WithKind("ReferenceGrant"):
+		grant := &gatewayv1alpha2.ReferenceGrant{}
+		if err := decode(data, gvk, grant); err != nil {
+			return err
+		}
+		o.ReferenceGrants = append(o.ReferenceGrants, grant)
+
+	case gatewayv1alpha2.SchemeGroupVersion.WithKind("ReferencePolicy"):
+		policy := &gatewayv1alpha2.ReferencePolicy{}
+		if err := decode(data, gvk, policy); err != nil {
+			return err
+		}
+		o.ReferenceGrants = append(o.ReferenceGrants, resources.ReferenceGrantFromPolicy(policy))
+
+	case corev1.SchemeGroupVersion.WithKind("Endpoints"):
+		eps := &corev1.Endpoints{}
+		if err := decode(data, gvk, eps); err != nil {
+			return err
+		}
+		o.Endpoints = append(o.Endpoints, eps)
+
+	case corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+		cm := &corev1.ConfigMap{}
+		if err := decode(data, gvk, cm); err != nil {
+			return err
+		}
+		if o.ConfigMaps == nil {
+			o.ConfigMaps = make(map[string]*corev1.ConfigMap)
+		}
+		o.ConfigMaps[cm.Name] = cm
+	}
+	return nil
+}
+
+func decode(data []byte, gvk fmt.Stringer, obj interface{}) error {
+	if err := json.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("invalid %s: %w", gvk, err)
+	}
+	return nil
+}
+
+// Config returns the configuration of the controller from the ConfigMaps,
+// config-network being optional. The gateway ConfigMap is the one named in
+// ctx, see config.WithGatewayConfigMapName.
+func (o *Objects) Config(ctx context.Context) (*config.Config, error) {
+	name := config.GatewayConfigMapName(ctx)
+	cm, ok := o.ConfigMaps[name]
+	if !ok {
+		return nil, fmt.Errorf("no %s ConfigMap among the files", name)
+	}
+	gateway, err := config.NewGatewayFromConfigMap(cm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", cm.Name, err)
+	}
+
+	cm, ok = o.ConfigMaps[networkcfg.ConfigMapName]
+	if !ok {
+		cm = &corev1.ConfigMap{}
+	}
+	net, err := network.NewConfigFromConfigMap(cm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", networkcfg.ConfigMapName, err)
+	}
+	return &config.Config{Gateway: gateway, Network: net}, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dump
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const manifests = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.internal.knative.dev/v1alpha1
+  kind: Ingress
+  metadata:
+    name: hello
+    namespace: default
+- apiVersion: gateway.networking.k8s.io/v1alpha2
+  kind: Gateway
+  metadata:
+    name: knative-gateway
+    namespace: istio-system
+  spec:
+    gatewayClassName: istio
+    listeners:
+    - name: http
+      port: 80
+      protocol: HTTP
+---
+# Skipped.
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-gateway
+  namespace: knative-serving
+data:
+  visibility: |
+    ExternalIP:
+      class: istio
+      gateway: istio-system/knative-gateway
+      service: istio-system/istio-ingressgateway
+    ClusterLocal:
+      class: istio
+      gateway: istio-system/knative-local-gateway
+      service: istio-system/knative-local-gateway
+`
+
+func TestRead(t *testing.T) {
+	var objs Objects
+	if err := objs.Read(strings.NewReader(manifests)); err != nil {
+		t.Fatal("Read() =", err)
+	}
+
+	if len(objs.Ingresses) != 1 || objs.Ingresses[0].Name != "hello" {
+		t.Errorf("Ingresses = %v, want: [hello]", objs.Ingresses)
+	}
+	if len(objs.Gateways) != 1 || objs.Gateways[0].Name != "knative-gateway" || len(objs.Gateways[0].Spec.Listeners) != 1 {
+		t.Errorf("Gateways = %v, want: [knative-gateway] with its listener", objs.Gateways)
+	}
+	if len(objs.ConfigMaps) != 1 {
+		t.Errorf("ConfigMaps = %v, want: [config-gateway]", objs.ConfigMaps)
+	}
+
+	cfg, err := objs.Config(context.Background())
+	if err != nil {
+		t.Fatal("Config() =", err)
+	}
+	if got := cfg.Gateway.Gateways["ExternalIP"].Gateway.String(); got != "istio-system/knative-gateway" {
+		t.Errorf("ExternalIP Gateway = %s, want: istio-system/knative-gateway", got)
+	}
+}
+
+func TestConfigMissing(t *testing.T) {
+	var objs Objects
+	if _, err := objs.Config(context.Background()); err == nil {
+		t.Error("Config() = nil, want an error without config-gateway")
+	}
+}
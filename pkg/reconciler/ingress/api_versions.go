@@ -0,0 +1,262 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayalphainformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions/apis/v1alpha2"
+	gatewayinformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions/apis/v1beta1"
+	gatewayalphalisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha2"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	gwapiclient "knative.dev/net-gateway-api/pkg/client/injection/client"
+	"knative.dev/net-gateway-api/pkg/client/injection/informers/factory"
+	filteredFactory "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/filtered"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/logging"
+)
+
+func init() {
+	// Clients are set up before informers, so the informers below can pick
+	// their version from what was discovered.
+	injection.Default.RegisterClient(withAPIVersions)
+	injection.Default.RegisterInformer(withHTTPRouteInformer)
+	injection.Default.RegisterInformer(withGatewayInformer)
+	injection.Default.RegisterInformer(withReferenceGrantInformer)
+}
+
+type apiVersionsKey struct{}
+
+// withAPIVersions discovers the versions of the Gateway API kinds served by
+// the cluster. The defaults are assumed when that fails.
+func withAPIVersions(ctx context.Context, _ *rest.Config) context.Context {
+	logger := logging.FromContext(ctx)
+	versions, err := discoverAPIVersions(gwapiclient.Get(ctx).Discovery())
+	if err != nil {
+		logger.Errorw("Failed to discover the served Gateway API versions, assuming the defaults", zap.Error(err))
+		versions = resources.DefaultAPIVersions
+	}
+	logger.Infof("Using Gateway API versions %+v", versions)
+	return context.WithValue(ctx, apiVersionsKey{}, versions)
+}
+
+// apiVersionsFromContext returns the discovered Gateway API versions, or the
+// defaults when there was no discovery, as in tests.
+func apiVersionsFromContext(ctx context.Context) resources.APIVersions {
+	if versions, ok := ctx.Value(apiVersionsKey{}).(resources.APIVersions); ok {
+		return versions
+	}
+	return resources.DefaultAPIVersions
+}
+
+// discoverAPIVersions picks the version of each Gateway API kind, preferring
+// v1beta1 over v1alpha2. ReferenceGrants and ReferencePolicies only exist in
+// v1alpha2 for us, and are left empty when not served.
+func discoverAPIVersions(client discovery.DiscoveryInterface) (resources.APIVersions, error) {
+	served := make(map[string]map[resources.APIVersion]bool)
+	for _, version := range []resources.APIVersion{resources.V1beta1, resources.V1alpha2} {
+		list, err := client.ServerResourcesForGroupVersion(version.GroupVersion().String())
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return resources.APIVersions{}, fmt.Errorf("failed to discover %s: %w", version.GroupVersion(), err)
+		}
+		for _, r := range list.APIResources {
+			if served[r.Kind] == nil {
+				served[r.Kind] = make(map[resources.APIVersion]bool)
+			}
+			served[r.Kind][version] = true
+		}
+	}
+
+	pick := func(kind string, versions ...resources.APIVersion) resources.APIVersion {
+		for _, version := range versions {
+			if served[kind][version] {
+				return version
+			}
+		}
+		return ""
+	}
+	versions := resources.APIVersions{
+		HTTPRoute:       pick("HTTPRoute", resources.V1beta1, resources.V1alpha2),
+		Gateway:         pick("Gateway", resources.V1beta1, resources.V1alpha2),
+		ReferenceGrant:  pick("ReferenceGrant", resources.V1alpha2),
+		ReferencePolicy: pick("ReferencePolicy", resources.V1alpha2),
+	}
+	if versions.HTTPRoute == "" || versions.Gateway == "" {
+		return resources.APIVersions{}, fmt.Errorf("neither %s nor %s serve both HTTPRoutes and Gateways",
+			resources.V1beta1.GroupVersion(), resources.V1alpha2.GroupVersion())
+	}
+	return versions, nil
+}
+
+type httpRouteInformerKey struct{}
+
+// withHTTPRouteInformer sets up the HTTPRoute informer in the served version.
+// v1alpha2 HTTPRoutes are cached as v1beta1, so that the rest of the
+// controller only ever deals with the latter. Only the HTTPRoutes we created
+// are watched, see resources.ManagedBySelector.
+func withHTTPRouteInformer(ctx context.Context) (context.Context, controller.Informer) {
+	f := filteredFactory.Get(ctx, resources.ManagedBySelector)
+	var inf gatewayinformers.HTTPRouteInformer = f.Gateway().V1beta1().HTTPRoutes()
+	if apiVersionsFromContext(ctx).HTTPRoute == resources.V1alpha2 {
+		alpha := f.Gateway().V1alpha2().HTTPRoutes().Informer()
+		if err := alpha.SetTransform(httpRouteFromV1alpha2); err != nil {
+			logging.FromContext(ctx).Panicw("Failed to set the HTTPRoute transform", zap.Error(err))
+		}
+		inf = &httpRouteInformer{informer: alpha}
+	}
+	return context.WithValue(ctx, httpRouteInformerKey{}, inf), inf.Informer()
+}
+
+func getHTTPRouteInformer(ctx context.Context) gatewayinformers.HTTPRouteInformer {
+	untyped := ctx.Value(httpRouteInformerKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the HTTPRoute informer from context.")
+	}
+	return untyped.(gatewayinformers.HTTPRouteInformer)
+}
+
+type gatewayInformerKey struct{}
+
+// withGatewayInformer sets up the Gateway informer in the served version, the
+// same way as withHTTPRouteInformer.
+func withGatewayInformer(ctx context.Context) (context.Context, controller.Informer) {
+	f := factory.Get(ctx)
+	var inf gatewayinformers.GatewayInformer = f.Gateway().V1beta1().Gateways()
+	if apiVersionsFromContext(ctx).Gateway == resources.V1alpha2 {
+		alpha := f.Gateway().V1alpha2().Gateways().Informer()
+		if err := alpha.SetTransform(gatewayFromV1alpha2); err != nil {
+			logging.FromContext(ctx).Panicw("Failed to set the Gateway transform", zap.Error(err))
+		}
+		inf = &gatewayInformer{informer: alpha}
+	}
+	return context.WithValue(ctx, gatewayInformerKey{}, inf), inf.Informer()
+}
+
+func getGatewayInformer(ctx context.Context) gatewayinformers.GatewayInformer {
+	untyped := ctx.Value(gatewayInformerKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the Gateway informer from context.")
+	}
+	return untyped.(gatewayinformers.GatewayInformer)
+}
+
+type referenceGrantInformerKey struct{}
+
+// withReferenceGrantInformer sets up the informer of the ReferenceGrants we
+// created, which caches ReferencePolicies as ReferenceGrants on clusters that
+// predate the latter.
+func withReferenceGrantInformer(ctx context.Context) (context.Context, controller.Informer) {
+	f := filteredFactory.Get(ctx, resources.ManagedBySelector)
+	var inf gatewayalphainformers.ReferenceGrantInformer = f.Gateway().V1alpha2().ReferenceGrants()
+	if apiVersionsFromContext(ctx).UsesReferencePolicies() {
+		policies := f.Gateway().V1alpha2().ReferencePolicies().Informer()
+		if err := policies.SetTransform(referenceGrantFromPolicy); err != nil {
+			logging.FromContext(ctx).Panicw("Failed to set the ReferencePolicy transform", zap.Error(err))
+		}
+		inf = &referenceGrantInformer{informer: policies}
+	}
+	return context.WithValue(ctx, referenceGrantInformerKey{}, inf), inf.Informer()
+}
+
+func getReferenceGrantInformer(ctx context.Context) gatewayalphainformers.ReferenceGrantInformer {
+	untyped := ctx.Value(referenceGrantInformerKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the ReferenceGrant informer from context.")
+	}
+	return untyped.(gatewayalphainformers.ReferenceGrantInformer)
+}
+
+// httpRouteInformer is a v1beta1 HTTPRoute informer over a v1alpha2 one
+// whose objects are converted as they are cached.
+type httpRouteInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+func (i *httpRouteInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *httpRouteInformer) Lister() gatewaylisters.HTTPRouteLister {
+	return gatewaylisters.NewHTTPRouteLister(i.informer.GetIndexer())
+}
+
+// gatewayInformer is a v1beta1 Gateway informer over a v1alpha2 one whose
+// objects are converted as they are cached.
+type gatewayInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+func (i *gatewayInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *gatewayInformer) Lister() gatewaylisters.GatewayLister {
+	return gatewaylisters.NewGatewayLister(i.informer.GetIndexer())
+}
+
+// referenceGrantInformer is a ReferenceGrant informer over a ReferencePolicy
+// one whose objects are converted as they are cached.
+type referenceGrantInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+func (i *referenceGrantInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *referenceGrantInformer) Lister() gatewayalphalisters.ReferenceGrantLister {
+	return gatewayalphalisters.NewReferenceGrantLister(i.informer.GetIndexer())
+}
+
+// httpRouteFromV1alpha2 converts v1alpha2 HTTPRoutes to v1beta1, passing
+// anything else through.
+func httpRouteFromV1alpha2(obj interface{}) (interface{}, error) {
+	if route, ok := obj.(*gatewayv1alpha2.HTTPRoute); ok {
+		return resources.HTTPRouteFromV1alpha2(route)
+	}
+	return obj, nil
+}
+
+// gatewayFromV1alpha2 converts v1alpha2 Gateways to v1beta1, passing
+// anything else through.
+func gatewayFromV1alpha2(obj interface{}) (interface{}, error) {
+	if gw, ok := obj.(*gatewayv1alpha2.Gateway); ok {
+		return resources.GatewayFromV1alpha2(gw)
+	}
+	return obj, nil
+}
+
+// referenceGrantFromPolicy converts ReferencePolicies to ReferenceGrants,
+// passing anything else through.
+func referenceGrantFromPolicy(obj interface{}) (interface{}, error) {
+	if policy, ok := obj.(*gatewayv1alpha2.ReferencePolicy); ok {
+		return resources.ReferenceGrantFromPolicy(policy), nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	fakegatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+)
+
+func TestDiscoverAPIVersions(t *testing.T) {
+	served := func(version resources.APIVersion, kinds ...string) *metav1.APIResourceList {
+		list := &metav1.APIResourceList{GroupVersion: version.GroupVersion().String()}
+		for _, kind := range kinds {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Kind: kind})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name    string
+		served  []*metav1.APIResourceList
+		want    resources.APIVersions
+		wantErr bool
+	}{{
+		name: "v1beta1 is preferred",
+		served: []*metav1.APIResourceList{
+			served(resources.V1beta1, "Gateway", "GatewayClass", "HTTPRoute"),
+			served(resources.V1alpha2, "Gateway", "GatewayClass", "HTTPRoute", "ReferenceGrant"),
+		},
+		want: resources.DefaultAPIVersions,
+	}, {
+		name: "only v1alpha2",
+		served: []*metav1.APIResourceList{
+			served(resources.V1alpha2, "Gateway", "GatewayClass", "HTTPRoute", "ReferencePolicy"),
+		},
+		want: resources.APIVersions{
+			HTTPRoute:       resources.V1alpha2,
+			Gateway:         resources.V1alpha2,
+			ReferencePolicy: resources.V1alpha2,
+		},
+	}, {
+		name: "ReferenceGrant next to ReferencePolicy",
+		served: []*metav1.APIResourceList{
+			served(resources.V1beta1, "Gateway", "GatewayClass", "HTTPRoute"),
+			served(resources.V1alpha2, "Gateway", "GatewayClass", "HTTPRoute", "ReferenceGrant", "ReferencePolicy"),
+		},
+		want: resources.APIVersions{
+			HTTPRoute:       resources.V1beta1,
+			Gateway:         resources.V1beta1,
+			ReferenceGrant:  resources.V1alpha2,
+			ReferencePolicy: resources.V1alpha2,
+		},
+	}, {
+		name: "no HTTPRoutes",
+		served: []*metav1.APIResourceList{
+			served(resources.V1alpha2, "Gateway", "GatewayClass"),
+		},
+		wantErr: true,
+	}, {
+		name:    "no Gateway API",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fakegatewayclientset.NewSimpleClientset()
+			client.Resources = test.served
+
+			got, err := discoverAPIVersions(client.Discovery())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("discoverAPIVersions() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error("discoverAPIVersions() (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestVersionedClient(t *testing.T) {
+	ctx := context.Background()
+	fake := fakegatewayclientset.NewSimpleClientset()
+	client := newVersionedClient(fake, resources.APIVersions{
+		HTTPRoute: resources.V1alpha2,
+		Gateway:   resources.V1beta1,
+	})
+
+	w, err := client.GatewayV1beta1().HTTPRoutes("ns").Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal("Watch() =", err)
+	}
+	defer w.Stop()
+
+	route := &gatewayapi.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "example.com", Namespace: "ns"},
+		Spec: gatewayapi.HTTPRouteSpec{
+			Hostnames: []gatewayapi.Hostname{"example.com"},
+		},
+	}
+	if _, err := client.GatewayV1beta1().HTTPRoutes("ns").Create(ctx, route, metav1.CreateOptions{}); err != nil {
+		t.Fatal("Create() =", err)
+	}
+
+	if _, err := fake.GatewayV1alpha2().HTTPRoutes("ns").Get(ctx, route.Name, metav1.GetOptions{}); err != nil {
+		t.Error("The HTTPRoute wasn't created in v1alpha2:", err)
+	}
+	if _, err := fake.GatewayV1beta1().HTTPRoutes("ns").Get(ctx, route.Name, metav1.GetOptions{}); err == nil {
+		t.Error("The HTTPRoute was created in v1beta1")
+	}
+
+	got, err := client.GatewayV1beta1().HTTPRoutes("ns").Get(ctx, route.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Get() =", err)
+	}
+	if diff := cmp.Diff(route, got); diff != "" {
+		t.Error("Get() (-want, +got):", diff)
+	}
+
+	event := <-w.ResultChan()
+	if _, ok := event.Object.(*gatewayapi.HTTPRoute); !ok {
+		t.Errorf("Watch() got a %T, want a v1beta1 HTTPRoute", event.Object)
+	}
+
+	if _, ok := client.GatewayV1beta1().Gateways("ns").(*v1alpha2Gateways); ok {
+		t.Error("Gateways are served in v1beta1, yet the client converts them")
+	}
+}
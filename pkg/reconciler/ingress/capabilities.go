@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+)
+
+// unsupportedFeatureReason is the reason set on Ingresses that need a feature
+// their Gateway doesn't support.
+const unsupportedFeatureReason = "UnsupportedFeature"
+
+// capabilityTracker remembers the Gateways that rejected HTTPRoutes for using
+// filters on backendRefs, so that the HTTPRoutes attached to them do without.
+// The zero value is ready to use.
+//
+// What it learned is lost on restart, when the HTTPRoutes get their backend
+// filters back until their Gateways reject them again.
+type capabilityTracker struct {
+	mu sync.Mutex
+	// noBackendFilters holds the Gateways that don't support filters on
+	// backendRefs, despite what config-gateway says.
+	noBackendFilters map[types.NamespacedName]struct{}
+}
+
+// observe records what the status the Gateway reported on the HTTPRoute
+// tells about its capabilities.
+func (t *capabilityTracker) observe(route *gatewayapi.HTTPRoute, gwName types.NamespacedName) {
+	if !hasBackendFilters(route) {
+		return
+	}
+	for _, parent := range route.Status.Parents {
+		if gw, ok := parentGateway(parent.ParentRef, route.Namespace); !ok || gw != gwName {
+			continue
+		}
+		if !rejectedAsUnsupported(route, parent) {
+			continue
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.noBackendFilters == nil {
+			t.noBackendFilters = make(map[types.NamespacedName]struct{})
+		}
+		t.noBackendFilters[gwName] = struct{}{}
+		return
+	}
+}
+
+// restrict returns the given capabilities of the Gateway, less what it
+// turned out not to support.
+func (t *capabilityTracker) restrict(gwName types.NamespacedName, caps *config.Capabilities) *config.Capabilities {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.noBackendFilters[gwName]; !ok || len(caps.BackendFilters) == 0 {
+		return caps
+	}
+	restricted := caps.DeepCopy()
+	restricted.BackendFilters = nil
+	return restricted
+}
+
+func hasBackendFilters(route *gatewayapi.HTTPRoute) bool {
+	for _, rule := range route.Spec.Rules {
+		for _, backend := range rule.BackendRefs {
+			if len(backend.Filters) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rejectedAsUnsupported returns whether the Gateway rejected the current
+// generation of the HTTPRoute for using something it doesn't support.
+func rejectedAsUnsupported(route *gatewayapi.HTTPRoute, parent gatewayapi.RouteParentStatus) bool {
+	for _, cond := range parent.Conditions {
+		if cond.ObservedGeneration != route.Generation {
+			continue
+		}
+		switch gatewayapi.RouteConditionType(cond.Type) {
+		case gatewayapi.RouteConditionAccepted, gatewayapi.RouteConditionResolvedRefs:
+			if cond.Status == metav1.ConditionFalse && cond.Reason == string(gatewayapi.RouteReasonUnsupportedValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
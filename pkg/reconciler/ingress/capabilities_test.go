@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgotesting "k8s.io/client-go/testing"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/ingress"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileCapabilities(t *testing.T) {
+	noBackendFilters := &config.Capabilities{
+		Filters: []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+	}
+
+	table := TableTest{{
+		Name: "headers of the only backend go on the rule",
+		Key:  "ns/name",
+		Ctx:  withCapabilities(noBackendFilters),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			capsRoute(t, ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders), noBackendFilters),
+		},
+		WantPatches:       []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{Object: ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders, readyStatus)}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "headers of split backends need backend filters",
+		Key:  "ns/name",
+		Ctx:  withCapabilities(noBackendFilters),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTrafficSplit, withFinalizer),
+		}, servicesAndEndpoints...),
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTrafficSplit, withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(unsupportedFeatureReason,
+					`Gateway istio-system/istio-gateway does not support RequestHeaderModifier filters on backendRefs, needed to set headers on the 2 backends of host "example.com"`)
+			}),
+		}},
+	}, {
+		Name: "Gateway rejected backend filters",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders, withFinalizer),
+			capsRoute(t, ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders), nil, rejectedAsUnsupportedStatus),
+		}, servicesAndEndpoints...),
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: capsRoute(t, ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders), noBackendFilters, rejectedAsUnsupportedStatus),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withSplitHeaders, withFinalizer, readyStatus),
+		}},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		cfg := defaultConfig.DeepCopy()
+		if caps, ok := ctx.Value(capabilitiesKey{}).(*config.Capabilities); ok {
+			external := cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP]
+			external.Capabilities = caps
+			cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP] = external
+		}
+		return newTestIngressReconciler(ctx, listers, newTestReconciler(ctx, listers), cfg)
+	}))
+}
+
+func TestCapabilityTracker(t *testing.T) {
+	gwName := types.NamespacedName{Namespace: testNamespace, Name: publicName}
+	caps := &config.Capabilities{
+		Filters:        []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+		BackendFilters: []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+	}
+	var tracker capabilityTracker
+
+	accepted := capsRoute(t, ing(withBasicSpec, withSplitHeaders), nil).(*gatewayapi.HTTPRoute)
+	tracker.observe(accepted, gwName)
+	if got := tracker.restrict(gwName, caps); got != caps {
+		t.Errorf("restrict() = %v, want: %v", got, caps)
+	}
+
+	stale := capsRoute(t, ing(withBasicSpec, withSplitHeaders), nil, rejectedAsUnsupportedStatus).(*gatewayapi.HTTPRoute)
+	stale.Generation++
+	tracker.observe(stale, gwName)
+	if got := tracker.restrict(gwName, caps); got != caps {
+		t.Errorf("restrict() after a stale rejection = %v, want: %v", got, caps)
+	}
+
+	rejected := capsRoute(t, ing(withBasicSpec, withSplitHeaders), nil, rejectedAsUnsupportedStatus).(*gatewayapi.HTTPRoute)
+	tracker.observe(rejected, gwName)
+	got := tracker.restrict(gwName, caps)
+	if got.SupportsBackendFilter(gatewayapi.HTTPRouteFilterRequestHeaderModifier) {
+		t.Error("restrict() kept the backend filters of a Gateway that rejected them")
+	}
+	if !got.SupportsFilter(gatewayapi.HTTPRouteFilterRequestHeaderModifier) {
+		t.Error("restrict() dropped the rule filters")
+	}
+	if other := (types.NamespacedName{Namespace: testNamespace, Name: "other"}); tracker.restrict(other, caps) != caps {
+		t.Error("restrict() restricted another Gateway")
+	}
+}
+
+type capabilitiesKey struct{}
+
+func withCapabilities(caps *config.Capabilities) context.Context {
+	return context.WithValue(context.Background(), capabilitiesKey{}, caps)
+}
+
+func withSplitHeaders(i *v1alpha1.Ingress) {
+	i.Spec.Rules[0].HTTP.Paths[0].Splits[0].AppendHeaders = map[string]string{
+		"Knative-Serving-Revision": "goo",
+	}
+}
+
+func withTrafficSplit(i *v1alpha1.Ingress) {
+	i.Spec.Rules[0].HTTP.Paths[0].Splits = []v1alpha1.IngressBackendSplit{{
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceName:      "goo",
+			ServiceNamespace: i.Namespace,
+			ServicePort:      intstr.FromInt(123),
+		},
+		Percent:       50,
+		AppendHeaders: map[string]string{"Knative-Serving-Revision": "goo"},
+	}, {
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceName:      "doo",
+			ServiceNamespace: i.Namespace,
+			ServicePort:      intstr.FromInt(124),
+		},
+		Percent:       50,
+		AppendHeaders: map[string]string{"Knative-Serving-Revision": "doo"},
+	}}
+}
+
+// rejectedAsUnsupportedStatus has the public Gateway reject the HTTPRoute for
+// using something it doesn't support.
+func rejectedAsUnsupportedStatus(r *gatewayapi.HTTPRoute) {
+	r.Status.Parents = []gatewayapi.RouteParentStatus{{
+		ParentRef: r.Spec.ParentRefs[0],
+		Conditions: []metav1.Condition{{
+			Type:               string(gatewayapi.RouteConditionAccepted),
+			Status:             metav1.ConditionFalse,
+			Reason:             string(gatewayapi.RouteReasonUnsupportedValue),
+			ObservedGeneration: r.Generation,
+		}},
+	}}
+}
+
+// capsRoute returns the HTTPRoute for the first rule of the Ingress on the
+// public Gateway, using only the given capabilities.
+func capsRoute(t *testing.T, i *v1alpha1.Ingress, caps *config.Capabilities, opts ...HTTPRouteOption) runtime.Object {
+	t.Helper()
+	ingress.InsertProbe(i)
+	rule := &i.Spec.Rules[0]
+	route, err := resources.MakeHTTPRoute(context.Background(), i, rule, *defaultConfig.Gateway.Gateways[rule.Visibility].Gateway, caps)
+	if err != nil {
+		t.Fatal("MakeHTTPRoute() =", err)
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	return route
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingclientset "knative.dev/networking/pkg/client/clientset/versioned"
+	networkinglisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+// leaderAwareReconciler is the subset of the generated Ingress reconciler
+// that classChangeReconciler delegates to.
+type leaderAwareReconciler interface {
+	controller.Reconciler
+	pkgreconciler.LeaderAware
+	IsLeaderFor(types.NamespacedName) bool
+}
+
+// classChangeReconciler wraps the generated Ingress reconciler. The generated
+// reconciler ignores every KIngress that is not annotated with our ingress
+// class, so a KIngress that moves to another class would keep its HTTPRoutes,
+// ReferenceGrants and Gateway listeners. This reconciler tears those down and
+// releases our finalizer before handing the KIngress over.
+type classChangeReconciler struct {
+	leaderAwareReconciler
+
+	reconciler    *Reconciler
+	lister        networkinglisters.IngressLister
+	client        networkingclientset.Interface
+	configStore   pkgreconciler.ConfigStore
+	recorder      record.EventRecorder
+	classFilter   func(interface{}) bool
+	finalizerName string
+	scope         NamespaceScope
+}
+
+var _ controller.Reconciler = (*classChangeReconciler)(nil)
+
+// Promote implements reconciler.LeaderAware, only enqueuing Ingresses of our
+// class, and those of another class still carrying our finalizer. The latter
+// moved while no replica of ours was leading, so no update told us about it.
+func (r *classChangeReconciler) Promote(b pkgreconciler.Bucket, enq func(pkgreconciler.Bucket, types.NamespacedName)) error {
+	return r.leaderAwareReconciler.Promote(b, func(b pkgreconciler.Bucket, key types.NamespacedName) {
+		ing, err := r.lister.Ingresses(key.Namespace).Get(key.Name)
+		if err == nil && (r.classFilter(ing) || sets.NewString(ing.Finalizers...).Has(r.finalizerName)) {
+			enq(b, key)
+		}
+	})
+}
+
+// Reconcile implements controller.Reconciler
+func (r *classChangeReconciler) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return r.leaderAwareReconciler.Reconcile(ctx, key)
+	}
+	if !r.scope.includesIngress(namespace) {
+		logging.FromContext(ctx).Infof("Ignoring KIngress %s outside of the watched namespaces %v", key, r.scope.Ingresses)
+		return nil
+	}
+
+	ing, err := r.lister.Ingresses(namespace).Get(name)
+	if err != nil || r.classFilter(ing) ||
+		!r.IsLeaderFor(types.NamespacedName{Namespace: namespace, Name: name}) {
+		return r.leaderAwareReconciler.Reconcile(ctx, key)
+	}
+
+	if r.configStore != nil {
+		ctx = r.configStore.ToContext(ctx)
+	}
+	ctx = controller.WithEventRecorder(ctx, r.recorder)
+
+	return r.releaseIngress(ctx, ing.DeepCopy())
+}
+
+// releaseIngress removes everything we created for an Ingress that is now
+// handled by another ingress class, and our finalizer. The HTTPRoutes are
+// deleted last since they're how we tell that an Ingress still has resources
+// of ours, so a failure part way through is retried on the next reconcile.
+// Our finalizer goes regardless, as the HTTPRoutes may already be gone, e.g.
+// deleted by hand or yielded to the winner of a hostname conflict.
+func (r *classChangeReconciler) releaseIngress(ctx context.Context, ing *v1alpha1.Ingress) error {
+	logger := logging.FromContext(ctx)
+
+	routes, err := r.reconciler.ownedHTTPRoutes(ctx, ing)
+	if err != nil {
+		return err
+	}
+
+	if len(routes) > 0 {
+		logger.Infof("Ingress %s/%s is no longer of our class, removing its resources", ing.Namespace, ing.Name)
+
+		if err := r.reconciler.FinalizeKind(ctx, ing); err != nil {
+			return err
+		}
+		if err := r.reconciler.deleteReferenceGrants(ctx, ing); err != nil {
+			return err
+		}
+	}
+	if err := r.removeFinalizer(ctx, ing); err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+	return r.reconciler.deleteHTTPRoutes(ctx, ing, routes)
+}
+
+func (r *classChangeReconciler) removeFinalizer(ctx context.Context, ing *v1alpha1.Ingress) error {
+	finalizers := sets.NewString(ing.Finalizers...)
+	if !finalizers.Has(r.finalizerName) {
+		return nil
+	}
+	finalizers.Delete(r.finalizerName)
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers.List(),
+			"resourceVersion": ing.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.NetworkingV1alpha1().Ingresses(ing.Namespace).Patch(ctx, ing.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		r.recorder.Eventf(ing, corev1.EventTypeWarning, "FinalizerUpdateFailed", "Failed to update finalizers for %q: %v", ing.Name, err)
+		return err
+	}
+	r.recorder.Eventf(ing, corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", ing.Name)
+	return nil
+}
+
+// ingressClassLister returns Ingresses whose ingress class is only set through
+// networking.IngressClassAnnotationAltKey with the class copied over to
+// networking.IngressClassAnnotationKey.
+type ingressClassLister struct {
+	networkinglisters.IngressLister
+}
+
+// Ingresses implements networkinglisters.IngressLister
+func (l *ingressClassLister) Ingresses(namespace string) networkinglisters.IngressNamespaceLister {
+	return &ingressClassNamespaceLister{IngressNamespaceLister: l.IngressLister.Ingresses(namespace)}
+}
+
+type ingressClassNamespaceLister struct {
+	networkinglisters.IngressNamespaceLister
+}
+
+// Get implements networkinglisters.IngressNamespaceLister
+func (l *ingressClassNamespaceLister) Get(name string) (*v1alpha1.Ingress, error) {
+	ing, err := l.IngressNamespaceLister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, class, ok := networking.IngressClassAnnotation.Get(ing.Annotations)
+	if !ok || key == networking.IngressClassAnnotationKey {
+		return ing, nil
+	}
+
+	// Don't modify the informers copy.
+	ing = ing.DeepCopy()
+	ing.Annotations[networking.IngressClassAnnotationKey] = class
+	return ing, nil
+}
+
+// classKeyReconciler hands the Reconciler the Ingresses of ingressClassLister
+// with the class it copied over removed again. The Reconciler copies the
+// annotations of an Ingress onto its HTTPRoutes, which would otherwise carry
+// a class annotation nobody set, and differ with the key the class is under.
+type classKeyReconciler struct {
+	*Reconciler
+
+	// lister returns the Ingresses as they are stored.
+	lister networkinglisters.IngressLister
+}
+
+// ReconcileKind implements Interface.ReconcileKind
+func (r *classKeyReconciler) ReconcileKind(ctx context.Context, ing *v1alpha1.Ingress) pkgreconciler.Event {
+	if stored, err := r.lister.Ingresses(ing.Namespace).Get(ing.Name); err == nil {
+		if _, ok := stored.Annotations[networking.IngressClassAnnotationKey]; !ok {
+			delete(ing.Annotations, networking.IngressClassAnnotationKey)
+		}
+	}
+	return r.Reconciler.ReconcileKind(ctx, ing)
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	fakeingressclient "knative.dev/networking/pkg/client/injection/client/fake"
+	ingressreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+
+	gatewayapialpha "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileClassChange(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+	withOtherClass := withAnnotation(map[string]string{
+		networking.IngressClassAnnotationKey: "fake-controller",
+	})
+	withAltClass := withAnnotation(map[string]string{
+		networking.IngressClassAnnotationAltKey: gatewayAPIIngressClassName,
+	})
+
+	table := TableTest{{
+		Name: "ingress of another class without our resources",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withOtherClass),
+			gw(defaultListener),
+		},
+	}, {
+		Name: "ingress of another class without our resources keeping our finalizer",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withOtherClass),
+			gw(defaultListener),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+			},
+			Name:  "name",
+			Patch: []byte(`{"metadata":{"finalizers":[],"resourceVersion":""}}`),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}, {
+		Name:                    "ingress moved to another class",
+		Key:                     "ns/name",
+		SkipNamespaceValidation: true,
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withOtherClass, withTLS(secretName)),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			rp(secret(secretName, nsName)),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: nsName,
+				Verb:      "delete",
+				Resource:  gatewayapialpha.SchemeGroupVersion.WithResource("referencegrants"),
+			},
+			Name: secretName + "-" + testNamespace,
+		}, {
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: nsName,
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+			},
+			Name:  "name",
+			Patch: []byte(`{"metadata":{"finalizers":[],"resourceVersion":""}}`),
+		}, gwPatch(t, listenersPatch{
+			{Operation: "test", Path: "/spec/listeners/1/name", Value: secureListenerName("secure.example.com")},
+			{Operation: "remove", Path: "/spec/listeners/1"},
+		})},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}, {
+		Name: "ingress of our class under the alternate key",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withAltClass),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{httpRoute(t, ing(withBasicSpec, withAltClass))},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withAltClass, withGatewayAPIClass, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkLoadBalancerReady(
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: publicSvc,
+					}},
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: privateSvc,
+					}})
+			}),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+			},
+			Name:  "name",
+			Patch: []byte(`{"metadata":{"finalizers":["ingresses.networking.internal.knative.dev"],"resourceVersion":""}}`),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", "Created HTTPRoute \"example.com\""),
+		},
+	}, {
+		Name: "ingress of our class under the alternate key keeps its routes",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withAltClass, makeItReady, withFinalizer),
+			httpRoute(t, ing(withBasicSpec, withAltClass)),
+		}, servicesAndEndpoints...),
+	}, {
+		Name:                    "primary key takes precedence over the alternate key",
+		Key:                     "ns/name",
+		SkipNamespaceValidation: true,
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withAltClass, withOtherClass),
+			gw(defaultListener),
+			httpRoute(t, ing(withBasicSpec, withAltClass)),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: nsName,
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+			},
+			Name:  "name",
+			Patch: []byte(`{"metadata":{"finalizers":[],"resourceVersion":""}}`),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}, {
+		Name: "ingress of our class is handed to the generated reconciler",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, makeItReady, withFinalizer),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		}, servicesAndEndpoints...),
+	}, {
+		Name: "ingress outside of the watched namespaces is ignored",
+		Key:  "ns/name",
+		Ctx: WithNamespaceScope(context.Background(), NamespaceScope{
+			Ingresses: []string{"other"},
+			Gateways:  []string{testNamespace},
+			Secrets:   []string{"other"},
+		}),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass),
+		}, servicesAndEndpoints...),
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		r := newTestReconciler(ctx, listers)
+		createGateways(ctx, tr)
+		return newTestClassChangeReconciler(ctx, listers, r)
+	}))
+}
+
+// newTestClassChangeReconciler wraps the Reconciler in the generated
+// reconciler and the classChangeReconciler, like NewController does.
+func newTestClassChangeReconciler(ctx context.Context, listers *Listers, r *Reconciler) *classChangeReconciler {
+	configStore := &testConfigStore{config: defaultConfig}
+	ingr := ingressreconciler.NewReconciler(ctx, logging.FromContext(ctx), fakeingressclient.Get(ctx),
+		&ingressClassLister{IngressLister: listers.GetIngressLister()}, controller.GetEventRecorder(ctx),
+		&classKeyReconciler{Reconciler: r, lister: listers.GetIngressLister()}, gatewayAPIIngressClassName,
+		controller.Options{
+			ConfigStore: configStore,
+		})
+
+	return &classChangeReconciler{
+		leaderAwareReconciler: ingr.(leaderAwareReconciler),
+		reconciler:            r,
+		lister:                listers.GetIngressLister(),
+		client:                fakeingressclient.Get(ctx),
+		configStore:           configStore,
+		recorder:              controller.GetEventRecorder(ctx),
+		classFilter:           ingressClassFilterFunc(gatewayAPIIngressClassName),
+		finalizerName:         defaultFinalizerName,
+		scope:                 namespaceScopeFromContext(ctx),
+	}
+}
+
+// promotingReconciler stands in for the generated reconciler, enqueuing the
+// given keys on Promote like it does every Ingress.
+type promotingReconciler struct {
+	leaderAwareReconciler
+	keys []types.NamespacedName
+}
+
+func (r *promotingReconciler) Promote(b pkgreconciler.Bucket, enq func(pkgreconciler.Bucket, types.NamespacedName)) error {
+	for _, key := range r.keys {
+		enq(b, key)
+	}
+	return nil
+}
+
+func TestClassChangePromote(t *testing.T) {
+	otherClass := withAnnotation(map[string]string{networking.IngressClassAnnotationKey: "fake-controller"})
+	named := func(name string) IngressOption {
+		return func(i *v1alpha1.Ingress) { i.Name = name }
+	}
+	listers := NewListers([]runtime.Object{
+		ing(named("ours"), withGatewayAPIClass),
+		ing(named("moved"), otherClass, withFinalizer),
+		ing(named("other"), otherClass),
+	})
+
+	var keys []types.NamespacedName
+	for _, name := range []string{"ours", "moved", "other", "gone"} {
+		keys = append(keys, types.NamespacedName{Namespace: "ns", Name: name})
+	}
+	r := &classChangeReconciler{
+		leaderAwareReconciler: &promotingReconciler{keys: keys},
+		lister:                listers.GetIngressLister(),
+		classFilter:           ingressClassFilterFunc(gatewayAPIIngressClassName),
+		finalizerName:         defaultFinalizerName,
+	}
+
+	var got []string
+	if err := r.Promote(pkgreconciler.UniversalBucket(), func(_ pkgreconciler.Bucket, key types.NamespacedName) {
+		got = append(got, key.Name)
+	}); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+	if want := []string{"ours", "moved"}; !cmp.Equal(got, want) {
+		t.Errorf("Promote() enqueued %v, want: %v", got, want)
+	}
+}
+
+func TestIngressClassFilterFunc(t *testing.T) {
+	filter := ingressClassFilterFunc(gatewayAPIIngressClassName)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{{
+		name: "no class",
+	}, {
+		name:        "primary key",
+		annotations: map[string]string{networking.IngressClassAnnotationKey: gatewayAPIIngressClassName},
+		want:        true,
+	}, {
+		name:        "alternate key",
+		annotations: map[string]string{networking.IngressClassAnnotationAltKey: gatewayAPIIngressClassName},
+		want:        true,
+	}, {
+		name: "primary key wins",
+		annotations: map[string]string{
+			networking.IngressClassAnnotationKey:    "fake-controller",
+			networking.IngressClassAnnotationAltKey: gatewayAPIIngressClassName,
+		},
+	}, {
+		name:        "other class",
+		annotations: map[string]string{networking.IngressClassAnnotationAltKey: "fake-controller"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := filter(ing(withAnnotation(test.annotations))); got != test.want {
+				t.Errorf("filter() = %v, want: %v", got, test.want)
+			}
+		})
+	}
+}
@@ -17,11 +17,17 @@ limitations under the License.
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/cache"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
 	"sigs.k8s.io/yaml"
 
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
@@ -34,11 +40,46 @@ const (
 	GatewayConfigName = "config-gateway"
 
 	visibilityConfigKey = "visibility"
+	profilesConfigKey   = "profiles"
+	migrationConfigKey  = "migration"
+	validationConfigKey = "validation"
+
+	// GatewayProfileAnnotationKey is the annotation Ingresses choose a
+	// gateway profile from config-gateway with, instead of the default
+	// gateways of their visibilities.
+	GatewayProfileAnnotationKey = "networking.knative.dev/gateway-profile"
 
 	// defaultGatewayClass is the gatewayclass name for the gateway.
 	defaultGatewayClass = "istio"
 )
 
+// MigrationMode is how HTTPRoutes move when their Gateway changes.
+type MigrationMode string
+
+const (
+	// MigrationImmediate moves HTTPRoutes to the new Gateway right away.
+	MigrationImmediate MigrationMode = "immediate"
+
+	// MigrationBlueGreen attaches HTTPRoutes to both the old and the new
+	// Gateway, and only detaches them from the old one once the new one
+	// accepted them and serves their hosts.
+	MigrationBlueGreen MigrationMode = "blue-green"
+)
+
+// ValidationMode is how Ingresses with fields the HTTPRoutes can't honor are
+// treated.
+type ValidationMode string
+
+const (
+	// ValidationWarn reconciles such Ingresses, and emits a warning event
+	// listing what is dropped.
+	ValidationWarn ValidationMode = "warn"
+
+	// ValidationStrict fails such Ingresses, listing what can't be honored
+	// on their status.
+	ValidationStrict ValidationMode = "strict"
+)
+
 var (
 	// defaultIstioGateway is the default gateway.
 	defaultIstioGateway = &types.NamespacedName{Namespace: "istio-system", Name: "knative-gateway"}
@@ -57,12 +98,57 @@ type GatewayConfig struct {
 	GatewayClass string
 	Gateway      *types.NamespacedName
 	Service      *types.NamespacedName
+
+	// Pool holds the Gateways that TLS listeners are spread across, starting
+	// with Gateway. It only holds more than Gateway when the visibility is
+	// configured with a list of gateways.
+	Pool []types.NamespacedName
+
+	// DefaultListeners are HTTPS listeners the controller puts on every
+	// Gateway of the pool, for the Ingresses whose hosts they match to share.
+	DefaultListeners []DefaultListener
+
+	// Capabilities overrides what the Gateways support, instead of what
+	// the provider usually does.
+	Capabilities *Capabilities
+
+	// Provider is the Gateway API implementation serving the Gateways, when
+	// it isn't the one serving the rest, e.g. for a profile on Gateways of
+	// another implementation.
+	Provider *Provider
+}
+
+// DefaultListener is an HTTPS listener for a hostname, usually a wildcard,
+// serving the certificate held by a Secret.
+type DefaultListener struct {
+	Hostname    string
+	Certificate types.NamespacedName
+}
+
+// GatewayPool returns the Gateways that TLS listeners are spread across.
+func (g *GatewayConfig) GatewayPool() []types.NamespacedName {
+	if len(g.Pool) > 0 {
+		return g.Pool
+	}
+	return []types.NamespacedName{*g.Gateway}
 }
 
 type visibilityValue struct {
-	GatewayClass string `json:"class,omitempty"`
-	Gateway      string `json:"gateway,omitempty"`
-	Service      string `json:"service,omitempty"`
+	GatewayClass string   `json:"class,omitempty"`
+	Gateway      string   `json:"gateway,omitempty"`
+	Gateways     []string `json:"gateways,omitempty"`
+	Service      string   `json:"service,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+
+	Listeners []listenerValue `json:"listeners,omitempty"`
+
+	Filters        *[]gatewayapi.HTTPRouteFilterType `json:"filters,omitempty"`
+	BackendFilters *[]gatewayapi.HTTPRouteFilterType `json:"backendFilters,omitempty"`
+}
+
+type listenerValue struct {
+	Hostname    string `json:"hostname,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
 }
 
 // Gateway maps gateways to routes by matching the gateway's
@@ -73,19 +159,164 @@ type Gateway struct {
 	// corresponding gateway.  If multiple selectors match, we choose
 	// the most specific selector.
 	Gateways map[v1alpha1.IngressVisibility]GatewayConfig
+
+	// Profiles holds named alternatives to Gateways, which Ingresses pick
+	// with the gateway-profile annotation. Visibilities a profile doesn't
+	// configure are taken from Gateways.
+	Profiles map[string]map[v1alpha1.IngressVisibility]GatewayConfig
+
+	// Migration is how HTTPRoutes move between Gateways.
+	Migration MigrationMode
+
+	// Validation is how Ingresses with fields the HTTPRoutes can't honor are
+	// treated.
+	Validation ValidationMode
+
+	// Provider is the Gateway API implementation serving the Gateways.
+	Provider *Provider
+}
+
+// GatewayProvider returns the Gateway API implementation serving the
+// Gateways, Istio unless configured otherwise.
+func (g *Gateway) GatewayProvider() *Provider {
+	if g.Provider != nil {
+		return g.Provider
+	}
+	return providers[ProviderIstio]
+}
+
+// ProviderOf returns the Gateway API implementation serving the Gateways of
+// the given configuration: the one it declares, or else the one serving the
+// rest.
+func (g *Gateway) ProviderOf(gw *GatewayConfig) *Provider {
+	if gw.Provider != nil {
+		return gw.Provider
+	}
+	return g.GatewayProvider()
+}
+
+// CapabilitiesOf returns what the Gateways of the given configuration
+// support: what it declares, or else what its provider does.
+func (g *Gateway) CapabilitiesOf(gw *GatewayConfig) *Capabilities {
+	if gw.Capabilities != nil {
+		return gw.Capabilities
+	}
+	return &g.ProviderOf(gw).Capabilities
+}
+
+// ForProfile returns the gateways of the named profile, or the default ones
+// for the empty name. It returns false if there is no such profile.
+func (g *Gateway) ForProfile(name string) (map[v1alpha1.IngressVisibility]GatewayConfig, bool) {
+	if name == "" {
+		return g.Gateways, true
+	}
+	gateways, ok := g.Profiles[name]
+	return gateways, ok
+}
+
+// AllGatewayPools returns the Gateways of the visibility across the default
+// gateways and all profiles.
+func (g *Gateway) AllGatewayPools(visibility v1alpha1.IngressVisibility) []types.NamespacedName {
+	var all []types.NamespacedName
+	seen := make(map[types.NamespacedName]struct{})
+	add := func(gateways map[v1alpha1.IngressVisibility]GatewayConfig) {
+		gw, ok := gateways[visibility]
+		if !ok {
+			return
+		}
+		for _, name := range gw.GatewayPool() {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				all = append(all, name)
+			}
+		}
+	}
+
+	add(g.Gateways)
+	names := make([]string, 0, len(g.Profiles))
+	for name := range g.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(g.Profiles[name])
+	}
+	return all
+}
+
+type gatewayConfigMapNameKey struct{}
+
+// WithGatewayConfigMapName sets the name of the gateway ConfigMap, so that
+// several controllers can run side by side, each with its own.
+func WithGatewayConfigMapName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, gatewayConfigMapNameKey{}, name)
+}
+
+// GatewayConfigMapName returns the name of the gateway ConfigMap, falling
+// back to GatewayConfigName.
+func GatewayConfigMapName(ctx context.Context) string {
+	if name, ok := ctx.Value(gatewayConfigMapNameKey{}).(string); ok && name != "" {
+		return name
+	}
+	return GatewayConfigName
 }
 
 // NewGatewayFromConfigMap creates a Gateway from the supplied ConfigMap
 func NewGatewayFromConfigMap(configMap *corev1.ConfigMap) (*Gateway, error) {
-	v, ok := configMap.Data[visibilityConfigKey]
+	provider := providers[ProviderIstio]
+	if v, ok := configMap.Data[providerConfigKey]; ok {
+		if provider, ok = providers[strings.TrimSpace(v)]; !ok {
+			return nil, fmt.Errorf("unrecognized provider: %q", v)
+		}
+	}
+
+	gateways, err := parseVisibilities(configMap.Data, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := parseProfiles(configMap.Data, provider, gateways)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := MigrationImmediate
+	if v, ok := configMap.Data[migrationConfigKey]; ok {
+		switch mode := MigrationMode(strings.TrimSpace(v)); mode {
+		case MigrationImmediate, MigrationBlueGreen:
+			migration = mode
+		default:
+			return nil, fmt.Errorf("unrecognized migration mode: %q", v)
+		}
+	}
+
+	validation := ValidationWarn
+	if v, ok := configMap.Data[validationConfigKey]; ok {
+		switch mode := ValidationMode(strings.TrimSpace(v)); mode {
+		case ValidationWarn, ValidationStrict:
+			validation = mode
+		default:
+			return nil, fmt.Errorf("unrecognized validation mode: %q", v)
+		}
+	}
+	return &Gateway{
+		Gateways:   gateways,
+		Profiles:   profiles,
+		Migration:  migration,
+		Validation: validation,
+		Provider:   provider,
+	}, nil
+}
+
+// parseVisibilities parses the default gateways of the visibilities, falling
+// back to those the provider is usually installed with.
+func parseVisibilities(data map[string]string, provider *Provider) (map[v1alpha1.IngressVisibility]GatewayConfig, error) {
+	v, ok := data[visibilityConfigKey]
 	if !ok {
-		// These are the defaults.
-		return &Gateway{
-			Gateways: map[v1alpha1.IngressVisibility]GatewayConfig{
-				v1alpha1.IngressVisibilityExternalIP:   {GatewayClass: defaultGatewayClass, Gateway: defaultIstioGateway, Service: defaultGatewayService},
-				v1alpha1.IngressVisibilityClusterLocal: {GatewayClass: defaultGatewayClass, Gateway: defaultIstioLocalGateway, Service: defaultLocalGatewayService},
-			},
-		}, nil
+		if gateways := providerGateways(provider.Name); gateways != nil {
+			return gateways, nil
+		}
+		return nil, fmt.Errorf("provider %q has no default gateways, %s must be set", provider.Name, visibilityConfigKey)
 	}
 
 	visConfig := make(map[v1alpha1.IngressVisibility]visibilityValue)
@@ -101,7 +332,46 @@ func NewGatewayFromConfigMap(configMap *corev1.ConfigMap) (*Gateway, error) {
 			return nil, fmt.Errorf("visibility %q must not be empty", vis)
 		}
 	}
+	return parseGatewayConfigs(visConfig, provider)
+}
 
+// parseProfiles parses the gateway profiles. Visibilities a profile leaves
+// out are taken from the given default gateways.
+func parseProfiles(data map[string]string, provider *Provider, defaults map[v1alpha1.IngressVisibility]GatewayConfig) (map[string]map[v1alpha1.IngressVisibility]GatewayConfig, error) {
+	v, ok := data[profilesConfigKey]
+	if !ok {
+		return nil, nil
+	}
+
+	profilesConfig := make(map[string]map[v1alpha1.IngressVisibility]visibilityValue)
+	if err := yaml.Unmarshal([]byte(v), &profilesConfig); err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]map[v1alpha1.IngressVisibility]GatewayConfig, len(profilesConfig))
+	for name, visConfig := range profilesConfig {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid profile name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if len(visConfig) == 0 {
+			return nil, fmt.Errorf("profile %q must configure a visibility", name)
+		}
+		gateways, err := parseGatewayConfigs(visConfig, provider)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+		for vis, gw := range defaults {
+			if _, ok := gateways[vis]; !ok {
+				gateways[vis] = gw
+			}
+		}
+		profiles[name] = gateways
+	}
+	return profiles, nil
+}
+
+// parseGatewayConfigs parses the gateway configuration of each visibility.
+func parseGatewayConfigs(visConfig map[v1alpha1.IngressVisibility]visibilityValue, provider *Provider) (map[v1alpha1.IngressVisibility]GatewayConfig, error) {
 	entry := make(map[v1alpha1.IngressVisibility]GatewayConfig)
 	for key, value := range visConfig {
 		// Check that the visibility makes sense.
@@ -113,7 +383,7 @@ func NewGatewayFromConfigMap(configMap *corev1.ConfigMap) (*Gateway, error) {
 		if value.GatewayClass == "" {
 			return nil, fmt.Errorf("visibility %q must set gatewayclass", key)
 		}
-		gateway, err := parseNamespacedName(value.Gateway)
+		pool, err := parseGatewayPool(value)
 		if err != nil {
 			return nil, fmt.Errorf("visibility %q failed to parse gateway: %w", key, err)
 		}
@@ -121,13 +391,125 @@ func NewGatewayFromConfigMap(configMap *corev1.ConfigMap) (*Gateway, error) {
 		if err != nil {
 			return nil, fmt.Errorf("visibility %q failed to parse service: %w", key, err)
 		}
+		listeners, err := parseDefaultListeners(value.Listeners)
+		if err != nil {
+			return nil, fmt.Errorf("visibility %q failed to parse listeners: %w", key, err)
+		}
+		// The Gateways may be served by another provider than the rest.
+		var own *Provider
+		if value.Provider != "" {
+			var ok bool
+			if own, ok = LookupProvider(strings.TrimSpace(value.Provider)); !ok {
+				return nil, fmt.Errorf("visibility %q has an unrecognized provider: %q", key, value.Provider)
+			}
+		}
+		visProvider := provider
+		if own != nil {
+			visProvider = own
+		}
+		capabilities, err := parseCapabilities(value, visProvider)
+		if err != nil {
+			return nil, fmt.Errorf("visibility %q failed to parse filters: %w", key, err)
+		}
 		entry[key] = GatewayConfig{
-			GatewayClass: value.GatewayClass,
-			Gateway:      gateway,
-			Service:      service,
+			GatewayClass:     value.GatewayClass,
+			Gateway:          &pool[0],
+			Service:          service,
+			Pool:             pool,
+			DefaultListeners: listeners,
+			Capabilities:     capabilities,
+			Provider:         own,
+		}
+	}
+	return entry, nil
+}
+
+// parseGatewayPool parses either the single gateway or the list of gateways of
+// a visibility.
+func parseGatewayPool(value visibilityValue) ([]types.NamespacedName, error) {
+	names := value.Gateways
+	switch {
+	case value.Gateway != "" && len(names) > 0:
+		return nil, errors.New("only one of gateway and gateways may be set")
+	case value.Gateway != "":
+		names = []string{value.Gateway}
+	case len(names) == 0:
+		return nil, errors.New("one of gateway and gateways must be set")
+	}
+
+	pool := make([]types.NamespacedName, 0, len(names))
+	seen := make(map[types.NamespacedName]struct{}, len(names))
+	for _, name := range names {
+		gateway, err := parseNamespacedName(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[*gateway]; ok {
+			return nil, fmt.Errorf("duplicate gateway %q", name)
+		}
+		seen[*gateway] = struct{}{}
+		pool = append(pool, *gateway)
+	}
+	return pool, nil
+}
+
+// parseDefaultListeners parses the default listeners of a visibility.
+func parseDefaultListeners(values []listenerValue) ([]DefaultListener, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	listeners := make([]DefaultListener, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		host := strings.TrimPrefix(value.Hostname, "*.")
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid hostname %q: %s", value.Hostname, strings.Join(errs, ", "))
+		}
+		if _, ok := seen[value.Hostname]; ok {
+			return nil, fmt.Errorf("duplicate hostname %q", value.Hostname)
+		}
+		seen[value.Hostname] = struct{}{}
+
+		certificate, err := parseNamespacedName(value.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("hostname %q failed to parse certificate: %w", value.Hostname, err)
+		}
+		listeners = append(listeners, DefaultListener{
+			Hostname:    value.Hostname,
+			Certificate: *certificate,
+		})
+	}
+	return listeners, nil
+}
+
+// parseCapabilities parses the filters a visibility declares support for. The
+// ones it leaves out are those of the provider. It returns nil if the
+// visibility declares none.
+func parseCapabilities(value visibilityValue, provider *Provider) (*Capabilities, error) {
+	if value.Filters == nil && value.BackendFilters == nil {
+		return nil, nil
+	}
+
+	capabilities := provider.Capabilities.DeepCopy()
+	for _, filters := range []struct {
+		value *[]gatewayapi.HTTPRouteFilterType
+		into  *[]gatewayapi.HTTPRouteFilterType
+	}{
+		{value.Filters, &capabilities.Filters},
+		{value.BackendFilters, &capabilities.BackendFilters},
+	} {
+		if filters.value == nil {
+			continue
+		}
+		for _, filter := range *filters.value {
+			if _, ok := filterTypes[filter]; !ok {
+				return nil, fmt.Errorf("unrecognized filter: %q", filter)
+			}
 		}
+		*filters.into = *filters.value
 	}
-	return &Gateway{Gateways: entry}, nil
+	return capabilities, nil
 }
 
 func parseNamespacedName(namespacedName string) (*types.NamespacedName, error) {
@@ -17,8 +17,16 @@ limitations under the License.
 package config
 
 import (
+	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+
 	. "knative.dev/pkg/configmap/testing"
 )
 
@@ -33,3 +41,566 @@ func TestGateway(t *testing.T) {
 		t.Error("NewContourFromConfigMap(example) =", err)
 	}
 }
+
+func TestGatewayConfigMapName(t *testing.T) {
+	ctx := context.Background()
+	if got, want := GatewayConfigMapName(ctx), GatewayConfigName; got != want {
+		t.Errorf("GatewayConfigMapName() = %q, want: %q", got, want)
+	}
+
+	ctx = WithGatewayConfigMapName(ctx, "config-gateway-contour")
+	if got, want := GatewayConfigMapName(ctx), "config-gateway-contour"; got != want {
+		t.Errorf("GatewayConfigMapName() = %q, want: %q", got, want)
+	}
+}
+
+func TestGatewayPool(t *testing.T) {
+	const clusterLocal = `
+ClusterLocal:
+  class: istio
+  gateway: istio-system/knative-local-gateway
+  service: istio-system/knative-local-gateway`
+
+	tests := []struct {
+		name     string
+		external string
+		want     []types.NamespacedName
+		wantErr  bool
+	}{{
+		name: "single gateway",
+		external: `
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway`,
+		want: []types.NamespacedName{{Namespace: "istio-system", Name: "knative-gateway"}},
+	}, {
+		name: "pool of gateways",
+		external: `
+ExternalIP:
+  class: istio
+  gateways:
+  - istio-system/knative-gateway-0
+  - istio-system/knative-gateway-1
+  service: istio-system/istio-ingressgateway`,
+		want: []types.NamespacedName{
+			{Namespace: "istio-system", Name: "knative-gateway-0"},
+			{Namespace: "istio-system", Name: "knative-gateway-1"},
+		},
+	}, {
+		name: "gateway and gateways",
+		external: `
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  gateways:
+  - istio-system/knative-gateway-0
+  service: istio-system/istio-ingressgateway`,
+		wantErr: true,
+	}, {
+		name: "no gateway",
+		external: `
+ExternalIP:
+  class: istio
+  service: istio-system/istio-ingressgateway`,
+		wantErr: true,
+	}, {
+		name: "duplicate gateways",
+		external: `
+ExternalIP:
+  class: istio
+  gateways:
+  - istio-system/knative-gateway-0
+  - istio-system/knative-gateway-0
+  service: istio-system/istio-ingressgateway`,
+		wantErr: true,
+	}, {
+		name: "bad gateway in pool",
+		external: `
+ExternalIP:
+  class: istio
+  gateways:
+  - knative-gateway-0
+  service: istio-system/istio-ingressgateway`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{
+				Data: map[string]string{visibilityConfigKey: test.external + clusterLocal},
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			external := got.Gateways[v1alpha1.IngressVisibilityExternalIP]
+			if diff := cmp.Diff(test.want, external.GatewayPool()); diff != "" {
+				t.Error("GatewayPool() (-want, +got):", diff)
+			}
+			if *external.Gateway != test.want[0] {
+				t.Errorf("Gateway = %v, want: %v", *external.Gateway, test.want[0])
+			}
+		})
+	}
+}
+
+func TestDefaultListeners(t *testing.T) {
+	const prefix = `
+ClusterLocal:
+  class: istio
+  gateway: istio-system/knative-local-gateway
+  service: istio-system/knative-local-gateway
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway
+  listeners:`
+
+	tests := []struct {
+		name      string
+		listeners string
+		want      []DefaultListener
+		wantErr   bool
+	}{{
+		name: "wildcard listener",
+		listeners: `
+  - hostname: "*.example.com"
+    certificate: istio-system/wildcard-example-com`,
+		want: []DefaultListener{{
+			Hostname:    "*.example.com",
+			Certificate: types.NamespacedName{Namespace: "istio-system", Name: "wildcard-example-com"},
+		}},
+	}, {
+		name: "invalid hostname",
+		listeners: `
+  - hostname: "*.Example_com"
+    certificate: istio-system/wildcard-example-com`,
+		wantErr: true,
+	}, {
+		name: "duplicate hostname",
+		listeners: `
+  - hostname: "*.example.com"
+    certificate: istio-system/wildcard-example-com
+  - hostname: "*.example.com"
+    certificate: istio-system/other`,
+		wantErr: true,
+	}, {
+		name: "certificate without namespace",
+		listeners: `
+  - hostname: "*.example.com"
+    certificate: wildcard-example-com`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{
+				Data: map[string]string{visibilityConfigKey: prefix + test.listeners},
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.want, got.Gateways[v1alpha1.IngressVisibilityExternalIP].DefaultListeners); diff != "" {
+				t.Error("DefaultListeners (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestGatewayProfiles(t *testing.T) {
+	const visibility = `
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway
+ClusterLocal:
+  class: istio
+  gateway: istio-system/knative-local-gateway
+  service: istio-system/knative-local-gateway`
+
+	defaultLocal := GatewayConfig{
+		GatewayClass: "istio",
+		Gateway:      &types.NamespacedName{Namespace: "istio-system", Name: "knative-local-gateway"},
+		Service:      &types.NamespacedName{Namespace: "istio-system", Name: "knative-local-gateway"},
+		Pool:         []types.NamespacedName{{Namespace: "istio-system", Name: "knative-local-gateway"}},
+	}
+
+	tests := []struct {
+		name     string
+		profiles string
+		want     map[string]map[v1alpha1.IngressVisibility]GatewayConfig
+		wantErr  bool
+	}{{
+		name: "profile inherits a visibility",
+		profiles: `
+partner:
+  ExternalIP:
+    class: istio
+    gateway: partner/gateway
+    service: partner/ingressgateway`,
+		want: map[string]map[v1alpha1.IngressVisibility]GatewayConfig{
+			"partner": {
+				v1alpha1.IngressVisibilityExternalIP: {
+					GatewayClass: "istio",
+					Gateway:      &types.NamespacedName{Namespace: "partner", Name: "gateway"},
+					Service:      &types.NamespacedName{Namespace: "partner", Name: "ingressgateway"},
+					Pool:         []types.NamespacedName{{Namespace: "partner", Name: "gateway"}},
+				},
+				v1alpha1.IngressVisibilityClusterLocal: defaultLocal,
+			},
+		},
+	}, {
+		name: "profile on gateways of another provider",
+		profiles: `
+partner:
+  ExternalIP:
+    class: contour
+    gateway: partner/gateway
+    service: partner/envoy
+    provider: contour`,
+		want: map[string]map[v1alpha1.IngressVisibility]GatewayConfig{
+			"partner": {
+				v1alpha1.IngressVisibilityExternalIP: {
+					GatewayClass: "contour",
+					Gateway:      &types.NamespacedName{Namespace: "partner", Name: "gateway"},
+					Service:      &types.NamespacedName{Namespace: "partner", Name: "envoy"},
+					Pool:         []types.NamespacedName{{Namespace: "partner", Name: "gateway"}},
+					Provider:     providers[ProviderContour],
+				},
+				v1alpha1.IngressVisibilityClusterLocal: defaultLocal,
+			},
+		},
+	}, {
+		name: "profile of an unknown provider",
+		profiles: `
+partner:
+  ExternalIP:
+    class: nginx
+    gateway: partner/gateway
+    service: partner/ingressgateway
+    provider: nginx`,
+		wantErr: true,
+	}, {
+		name: "invalid profile name",
+		profiles: `
+Partner:
+  ExternalIP:
+    class: istio
+    gateway: partner/gateway
+    service: partner/ingressgateway`,
+		wantErr: true,
+	}, {
+		name: "empty profile",
+		profiles: `
+partner: {}`,
+		wantErr: true,
+	}, {
+		name: "unknown visibility",
+		profiles: `
+partner:
+  Internal:
+    class: istio
+    gateway: partner/gateway
+    service: partner/ingressgateway`,
+		wantErr: true,
+	}, {
+		name: "bad gateway",
+		profiles: `
+partner:
+  ExternalIP:
+    class: istio
+    gateway: gateway
+    service: partner/ingressgateway`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{
+				Data: map[string]string{
+					visibilityConfigKey: visibility,
+					profilesConfigKey:   test.profiles,
+				},
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.want, got.Profiles); diff != "" {
+				t.Error("Profiles (-want, +got):", diff)
+			}
+
+			if _, ok := got.ForProfile("unknown"); ok {
+				t.Error(`ForProfile("unknown") = true, want: false`)
+			}
+			wantPools := []types.NamespacedName{
+				{Namespace: "istio-system", Name: "knative-gateway"},
+				{Namespace: "partner", Name: "gateway"},
+			}
+			if diff := cmp.Diff(wantPools, got.AllGatewayPools(v1alpha1.IngressVisibilityExternalIP)); diff != "" {
+				t.Error("AllGatewayPools() (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestMigrationMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    MigrationMode
+		wantErr bool
+	}{{
+		name: "default",
+		data: map[string]string{},
+		want: MigrationImmediate,
+	}, {
+		name: "blue-green",
+		data: map[string]string{migrationConfigKey: "blue-green"},
+		want: MigrationBlueGreen,
+	}, {
+		name:    "unknown",
+		data:    map[string]string{migrationConfigKey: "canary"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err == nil && got.Migration != test.want {
+				t.Errorf("Migration = %q, want: %q", got.Migration, test.want)
+			}
+		})
+	}
+}
+
+func TestProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         map[string]string
+		wantProvider string
+		wantService  *types.NamespacedName
+		wantErr      bool
+	}{{
+		name:         "default",
+		data:         map[string]string{},
+		wantProvider: ProviderIstio,
+		wantService:  defaultGatewayService,
+	}, {
+		name:         "contour defaults",
+		data:         map[string]string{providerConfigKey: "contour"},
+		wantProvider: ProviderContour,
+		wantService:  &types.NamespacedName{Namespace: "contour-external", Name: "envoy"},
+	}, {
+		name: "envoy gateway with visibility",
+		data: map[string]string{
+			providerConfigKey: "envoy-gateway",
+			visibilityConfigKey: `
+ExternalIP:
+  class: eg
+  gateway: envoy-gateway-system/knative-gateway
+  service: envoy-gateway-system/knative-external
+ClusterLocal:
+  class: eg
+  gateway: envoy-gateway-system/knative-local-gateway
+  service: envoy-gateway-system/knative-local
+`,
+		},
+		wantProvider: ProviderEnvoyGateway,
+		wantService:  &types.NamespacedName{Namespace: "envoy-gateway-system", Name: "knative-external"},
+	}, {
+		name:    "envoy gateway without visibility",
+		data:    map[string]string{providerConfigKey: "envoy-gateway"},
+		wantErr: true,
+	}, {
+		name:    "unknown",
+		data:    map[string]string{providerConfigKey: "nginx"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if name := got.GatewayProvider().Name; name != test.wantProvider {
+				t.Errorf("Provider = %q, want: %q", name, test.wantProvider)
+			}
+			if diff := cmp.Diff(test.wantService, got.Gateways[v1alpha1.IngressVisibilityExternalIP].Service); diff != "" {
+				t.Error("Service (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestProviderCapabilities(t *testing.T) {
+	if got := (&Gateway{}).GatewayProvider().Name; got != ProviderIstio {
+		t.Errorf("GatewayProvider() = %q, want: %q", got, ProviderIstio)
+	}
+
+	contour, ok := LookupProvider(ProviderContour)
+	if !ok {
+		t.Fatal("LookupProvider(contour) = false")
+	}
+	if contour.SupportsFilter(gatewayapi.HTTPRouteFilterURLRewrite) {
+		t.Error("Contour supports URLRewrite, want: unsupported")
+	}
+	if !contour.SupportsFilter(gatewayapi.HTTPRouteFilterRequestHeaderModifier) {
+		t.Error("Contour doesn't support RequestHeaderModifier, want: supported")
+	}
+	if diff := cmp.Diff([]string{"https"}, contour.PortNames("https")); diff != "" {
+		t.Error("PortNames(https) (-want, +got):", diff)
+	}
+	if _, ok := LookupProvider("nginx"); ok {
+		t.Error("LookupProvider(nginx) = true, want: false")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	visibility := func(extra string) string {
+		return `
+ExternalIP:
+  class: eg
+  gateway: envoy-gateway-system/knative-gateway
+  service: envoy-gateway-system/knative-external
+` + extra + `
+ClusterLocal:
+  class: eg
+  gateway: envoy-gateway-system/knative-local-gateway
+  service: envoy-gateway-system/knative-local
+`
+	}
+
+	tests := []struct {
+		name     string
+		data     map[string]string
+		want     *Capabilities
+		wantErr  bool
+		provided bool
+	}{{
+		name:     "provider capabilities",
+		data:     map[string]string{providerConfigKey: "envoy-gateway", visibilityConfigKey: visibility("")},
+		want:     &providers[ProviderEnvoyGateway].Capabilities,
+		provided: true,
+	}, {
+		name: "backend filters declared",
+		data: map[string]string{providerConfigKey: "envoy-gateway", visibilityConfigKey: visibility(`  backendFilters: [RequestHeaderModifier]`)},
+		want: &Capabilities{
+			Filters:        providers[ProviderEnvoyGateway].Filters,
+			BackendFilters: []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+		},
+	}, {
+		name: "no filters at all",
+		data: map[string]string{visibilityConfigKey: visibility(`  filters: []
+  backendFilters: []`)},
+		want: &Capabilities{
+			Filters:        []gatewayapi.HTTPRouteFilterType{},
+			BackendFilters: []gatewayapi.HTTPRouteFilterType{},
+		},
+	}, {
+		name:    "unknown filter",
+		data:    map[string]string{visibilityConfigKey: visibility(`  filters: [Teleport]`)},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			external := got.Gateways[v1alpha1.IngressVisibilityExternalIP]
+			if provided := external.Capabilities == nil; provided != test.provided {
+				t.Errorf("Capabilities taken from the provider = %v, want: %v", provided, test.provided)
+			}
+			if diff := cmp.Diff(test.want, got.CapabilitiesOf(&external)); diff != "" {
+				t.Error("CapabilitiesOf() (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestProviderOf(t *testing.T) {
+	got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		profilesConfigKey: `
+partner:
+  ExternalIP:
+    class: contour
+    gateway: partner/gateway
+    service: partner/envoy
+    provider: contour
+    filters: [RequestHeaderModifier]`,
+	}})
+	if err != nil {
+		t.Fatal("NewGatewayFromConfigMap() =", err)
+	}
+
+	external := got.Gateways[v1alpha1.IngressVisibilityExternalIP]
+	if name := got.ProviderOf(&external).Name; name != ProviderIstio {
+		t.Errorf("ProviderOf(default) = %q, want: %q", name, ProviderIstio)
+	}
+	partner := got.Profiles["partner"][v1alpha1.IngressVisibilityExternalIP]
+	if name := got.ProviderOf(&partner).Name; name != ProviderContour {
+		t.Errorf("ProviderOf(partner) = %q, want: %q", name, ProviderContour)
+	}
+	// The filters it leaves out are those of its own provider.
+	want := &Capabilities{
+		Filters:        []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+		BackendFilters: providers[ProviderContour].BackendFilters,
+	}
+	if diff := cmp.Diff(want, got.CapabilitiesOf(&partner)); diff != "" {
+		t.Error("CapabilitiesOf(partner) (-want, +got):", diff)
+	}
+}
+
+func TestValidationMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    ValidationMode
+		wantErr bool
+	}{{
+		name: "default",
+		data: map[string]string{},
+		want: ValidationWarn,
+	}, {
+		name: "strict",
+		data: map[string]string{validationConfigKey: "strict"},
+		want: ValidationStrict,
+	}, {
+		name:    "unknown",
+		data:    map[string]string{validationConfigKey: "lenient"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewGatewayFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewGatewayFromConfigMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err == nil && got.Validation != test.want {
+				t.Errorf("Validation = %q, want: %q", got.Validation, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	providerConfigKey = "provider"
+
+	// ProviderIstio is the Istio implementation of Gateway API.
+	ProviderIstio = "istio"
+
+	// ProviderContour is the Contour implementation of Gateway API.
+	ProviderContour = "contour"
+
+	// ProviderEnvoyGateway is the Envoy Gateway implementation of Gateway
+	// API.
+	ProviderEnvoyGateway = "envoy-gateway"
+)
+
+// Capabilities are the Extended Gateway API features a Gateway supports.
+type Capabilities struct {
+	// Filters are the HTTPRoute filters supported on route rules.
+	Filters []gatewayapi.HTTPRouteFilterType
+
+	// BackendFilters are the HTTPRoute filters supported on backendRefs.
+	BackendFilters []gatewayapi.HTTPRouteFilterType
+}
+
+// SupportsFilter returns whether the HTTPRoute filter is supported on route
+// rules.
+func (c *Capabilities) SupportsFilter(filter gatewayapi.HTTPRouteFilterType) bool {
+	return hasFilter(c.Filters, filter)
+}
+
+// SupportsBackendFilter returns whether the HTTPRoute filter is supported on
+// backendRefs.
+func (c *Capabilities) SupportsBackendFilter(filter gatewayapi.HTTPRouteFilterType) bool {
+	return hasFilter(c.BackendFilters, filter)
+}
+
+func hasFilter(filters []gatewayapi.HTTPRouteFilterType, filter gatewayapi.HTTPRouteFilterType) bool {
+	for _, f := range filters {
+		if f == filter {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider describes what the controller needs to know about the Gateway API
+// implementation serving the Gateways.
+type Provider struct {
+	// Name is the name of the provider, e.g. istio.
+	Name string
+
+	// HTTPPortNames and HTTPSPortNames are the names the Services in front
+	// of the Gateways give their plain HTTP and HTTPS ports. The prober
+	// picks the port to probe by these.
+	HTTPPortNames  []string
+	HTTPSPortNames []string
+
+	// Capabilities are what the Gateways of the provider support, unless
+	// configured otherwise for a visibility.
+	Capabilities
+}
+
+// PortNames returns the names of the Service ports serving the scheme, http
+// or https.
+func (p *Provider) PortNames(scheme string) []string {
+	if scheme == "https" {
+		return p.HTTPSPortNames
+	}
+	return p.HTTPPortNames
+}
+
+// providers holds the known providers.
+var providers = map[string]*Provider{
+	ProviderIstio: {
+		Name: ProviderIstio,
+		// Istio uses "http2" for the http port.
+		HTTPPortNames:  []string{"http", "http2"},
+		HTTPSPortNames: []string{"https"},
+		Capabilities: Capabilities{
+			Filters: []gatewayapi.HTTPRouteFilterType{
+				gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+				gatewayapi.HTTPRouteFilterRequestRedirect,
+				gatewayapi.HTTPRouteFilterURLRewrite,
+				gatewayapi.HTTPRouteFilterRequestMirror,
+			},
+			BackendFilters: []gatewayapi.HTTPRouteFilterType{
+				gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+			},
+		},
+	},
+	ProviderContour: {
+		Name:           ProviderContour,
+		HTTPPortNames:  []string{"http"},
+		HTTPSPortNames: []string{"https"},
+		Capabilities: Capabilities{
+			Filters: []gatewayapi.HTTPRouteFilterType{
+				gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+				gatewayapi.HTTPRouteFilterRequestRedirect,
+				gatewayapi.HTTPRouteFilterRequestMirror,
+			},
+			BackendFilters: []gatewayapi.HTTPRouteFilterType{
+				gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+			},
+		},
+	},
+	ProviderEnvoyGateway: {
+		Name:           ProviderEnvoyGateway,
+		HTTPPortNames:  []string{"http"},
+		HTTPSPortNames: []string{"https"},
+		// Envoy Gateway doesn't support filters on backendRefs.
+		Capabilities: Capabilities{
+			Filters: []gatewayapi.HTTPRouteFilterType{
+				gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+				gatewayapi.HTTPRouteFilterRequestRedirect,
+				gatewayapi.HTTPRouteFilterURLRewrite,
+				gatewayapi.HTTPRouteFilterRequestMirror,
+			},
+		},
+	},
+}
+
+// filterTypes holds the HTTPRoute filters config-gateway can declare support
+// for.
+var filterTypes = map[gatewayapi.HTTPRouteFilterType]struct{}{
+	gatewayapi.HTTPRouteFilterRequestHeaderModifier: {},
+	gatewayapi.HTTPRouteFilterRequestRedirect:       {},
+	gatewayapi.HTTPRouteFilterURLRewrite:            {},
+	gatewayapi.HTTPRouteFilterRequestMirror:         {},
+	gatewayapi.HTTPRouteFilterExtensionRef:          {},
+}
+
+// LookupProvider returns the provider of the given name, or false if there is
+// no such provider.
+func LookupProvider(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, false
+	}
+	return p.DeepCopy(), true
+}
+
+// providerGateways returns the gateways the provider is usually installed
+// with, used when config-gateway doesn't configure any. Envoy Gateway names
+// the Services of its Gateways after a hash, so it has none.
+func providerGateways(provider string) map[v1alpha1.IngressVisibility]GatewayConfig {
+	switch provider {
+	case ProviderIstio:
+		return map[v1alpha1.IngressVisibility]GatewayConfig{
+			v1alpha1.IngressVisibilityExternalIP: {
+				GatewayClass: defaultGatewayClass,
+				Gateway:      defaultIstioGateway,
+				Service:      defaultGatewayService,
+				Pool:         []types.NamespacedName{*defaultIstioGateway},
+			},
+			v1alpha1.IngressVisibilityClusterLocal: {
+				GatewayClass: defaultGatewayClass,
+				Gateway:      defaultIstioLocalGateway,
+				Service:      defaultLocalGatewayService,
+				Pool:         []types.NamespacedName{*defaultIstioLocalGateway},
+			},
+		}
+	case ProviderContour:
+		external := types.NamespacedName{Namespace: "contour-external", Name: "knative-gateway"}
+		local := types.NamespacedName{Namespace: "contour-internal", Name: "knative-local-gateway"}
+		return map[v1alpha1.IngressVisibility]GatewayConfig{
+			v1alpha1.IngressVisibilityExternalIP: {
+				GatewayClass: ProviderContour,
+				Gateway:      &external,
+				Service:      &types.NamespacedName{Namespace: "contour-external", Name: "envoy"},
+				Pool:         []types.NamespacedName{external},
+			},
+			v1alpha1.IngressVisibilityClusterLocal: {
+				GatewayClass: ProviderContour,
+				Gateway:      &local,
+				Service:      &types.NamespacedName{Namespace: "contour-internal", Name: "envoy"},
+				Pool:         []types.NamespacedName{local},
+			},
+		}
+	}
+	return nil
+}
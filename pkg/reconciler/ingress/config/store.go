@@ -58,6 +58,9 @@ func ToContext(ctx context.Context, c *Config) context.Context {
 // +k8s:deepcopy-gen=false
 type Store struct {
 	*configmap.UntypedStore
+
+	// gatewayConfigName is the name of the gateway ConfigMap.
+	gatewayConfigName string
 }
 
 // NewStore creates a configmap.UntypedStore based config store.
@@ -68,16 +71,20 @@ type Store struct {
 // onAfterStore is a variadic list of callbacks to run
 // after the ConfigMap has been processed and stored.
 //
+// The gateway ConfigMap is the one named in ctx, see WithGatewayConfigMapName.
+//
 // See also: configmap.NewUntypedStore().
 func NewStore(ctx context.Context, onAfterStore ...func(name string, value interface{})) *Store {
 	logger := logging.FromContext(ctx)
+	gatewayConfigName := GatewayConfigMapName(ctx)
 
 	store := &Store{
+		gatewayConfigName: gatewayConfigName,
 		UntypedStore: configmap.NewUntypedStore(
 			"gateway-api",
 			logger,
 			configmap.Constructors{
-				GatewayConfigName:        NewGatewayFromConfigMap,
+				gatewayConfigName:        NewGatewayFromConfigMap,
 				networkcfg.ConfigMapName: network.NewConfigFromConfigMap,
 			},
 			onAfterStore...,
@@ -95,7 +102,7 @@ func (s *Store) ToContext(ctx context.Context) context.Context {
 // Load creates a Config for this store.
 func (s *Store) Load() *Config {
 	config := &Config{
-		Gateway: s.UntypedLoad(GatewayConfigName).(*Gateway).DeepCopy(),
+		Gateway: s.UntypedLoad(s.gatewayConfigName).(*Gateway).DeepCopy(),
 		Network: s.UntypedLoad(networkcfg.ConfigMapName).(*networkcfg.Config).DeepCopy(),
 	}
 	return config
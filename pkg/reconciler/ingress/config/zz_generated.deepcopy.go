@@ -25,8 +25,35 @@ import (
 	types "k8s.io/apimachinery/pkg/types"
 	v1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
 	pkgconfig "knative.dev/networking/pkg/config"
+	v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Capabilities) DeepCopyInto(out *Capabilities) {
+	*out = *in
+	if in.Filters != nil {
+		in, out := &in.Filters, &out.Filters
+		*out = make([]v1beta1.HTTPRouteFilterType, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackendFilters != nil {
+		in, out := &in.BackendFilters, &out.BackendFilters
+		*out = make([]v1beta1.HTTPRouteFilterType, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Capabilities.
+func (in *Capabilities) DeepCopy() *Capabilities {
+	if in == nil {
+		return nil
+	}
+	out := new(Capabilities)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
@@ -53,6 +80,23 @@ func (in *Config) DeepCopy() *Config {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefaultListener) DeepCopyInto(out *DefaultListener) {
+	*out = *in
+	out.Certificate = in.Certificate
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefaultListener.
+func (in *DefaultListener) DeepCopy() *DefaultListener {
+	if in == nil {
+		return nil
+	}
+	out := new(DefaultListener)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Gateway) DeepCopyInto(out *Gateway) {
 	*out = *in
@@ -63,6 +107,28 @@ func (in *Gateway) DeepCopyInto(out *Gateway) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make(map[string]map[v1alpha1.IngressVisibility]GatewayConfig, len(*in))
+		for key, val := range *in {
+			var outVal map[v1alpha1.IngressVisibility]GatewayConfig
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[v1alpha1.IngressVisibility]GatewayConfig, len(*in))
+				for key, val := range *in {
+					(*out)[key] = *val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Provider != nil {
+		in, out := &in.Provider, &out.Provider
+		*out = new(Provider)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -89,6 +155,26 @@ func (in *GatewayConfig) DeepCopyInto(out *GatewayConfig) {
 		*out = new(types.NamespacedName)
 		**out = **in
 	}
+	if in.Pool != nil {
+		in, out := &in.Pool, &out.Pool
+		*out = make([]types.NamespacedName, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultListeners != nil {
+		in, out := &in.DefaultListeners, &out.DefaultListeners
+		*out = make([]DefaultListener, len(*in))
+		copy(*out, *in)
+	}
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = new(Capabilities)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Provider != nil {
+		in, out := &in.Provider, &out.Provider
+		*out = new(Provider)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -101,3 +187,30 @@ func (in *GatewayConfig) DeepCopy() *GatewayConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Provider) DeepCopyInto(out *Provider) {
+	*out = *in
+	if in.HTTPPortNames != nil {
+		in, out := &in.HTTPPortNames, &out.HTTPPortNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HTTPSPortNames != nil {
+		in, out := &in.HTTPSPortNames, &out.HTTPSPortNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Capabilities.DeepCopyInto(&out.Capabilities)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Provider.
+func (in *Provider) DeepCopy() *Provider {
+	if in == nil {
+		return nil
+	}
+	out := new(Provider)
+	in.DeepCopyInto(out)
+	return out
+}
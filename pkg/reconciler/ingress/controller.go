@@ -19,33 +19,82 @@ package ingress
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingclient "knative.dev/networking/pkg/client/injection/client"
 	ingressinformer "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/ingress"
 	ingressreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
 	networkcfg "knative.dev/networking/pkg/config"
 	"knative.dev/networking/pkg/status"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	endpointsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
-	"knative.dev/pkg/reconciler"
+	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracing"
+	tracingconfig "knative.dev/pkg/tracing/config"
 
 	gwapiclient "knative.dev/net-gateway-api/pkg/client/injection/client"
-	referencegrantinformer "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1alpha2/referencegrant"
-	gatewayinformer "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1beta1/gateway"
-	httprouteinformer "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1beta1/httproute"
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
 )
 
 const (
-	// gatewayAPIIngressClassName is the class name to reconcile.
+	// gatewayAPIIngressClassName is the default class name to reconcile.
 	gatewayAPIIngressClassName = "gateway-api.ingress.networking.knative.dev"
+
+	// defaultComponentName is the component name of the controller reconciling
+	// the default ingress class.
+	defaultComponentName = "net-gateway-api-controller"
+
+	// defaultFinalizerName is the finalizer put on Ingresses of the default class.
+	defaultFinalizerName = "ingresses.networking.internal.knative.dev"
 )
 
+type ingressClassKey struct{}
+
+// WithIngressClass sets the ingress class NewController reconciles, so that
+// several controllers can run side by side, one per ingress class.
+func WithIngressClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, ingressClassKey{}, class)
+}
+
+// IngressClassFromContext returns the ingress class to reconcile, falling back
+// to the default class.
+func IngressClassFromContext(ctx context.Context) string {
+	if class, ok := ctx.Value(ingressClassKey{}).(string); ok && class != "" {
+		return class
+	}
+	return gatewayAPIIngressClassName
+}
+
+// ComponentName returns the component name for the controller reconciling the
+// given ingress class. It is used for leader election and as event source.
+func ComponentName(class string) string {
+	if class == gatewayAPIIngressClassName {
+		return defaultComponentName
+	}
+	return class
+}
+
+// finalizerFor returns the finalizer put on Ingresses of the given class. The
+// default class keeps the finalizer it always had, so upgrades don't orphan it.
+func finalizerFor(class string) string {
+	if class == gatewayAPIIngressClassName {
+		return defaultFinalizerName
+	}
+	return class + "/finalizer"
+}
+
 // NewController initializes the controller and is called by the generated code
 // Registers eventhandlers to enqueue events
 func NewController(
@@ -54,22 +103,39 @@ func NewController(
 ) *controller.Impl {
 	logger := logging.FromContext(ctx)
 
+	ingressClass := IngressClassFromContext(ctx)
+	componentName := ComponentName(ingressClass)
+	finalizerName := finalizerFor(ingressClass)
+
+	// The event recorder is shared with the generated reconciler, which only
+	// creates its own when the context doesn't carry one.
+	if controller.GetEventRecorder(ctx) == nil {
+		ctx = controller.WithEventRecorder(ctx, newEventRecorder(ctx, componentName))
+	}
+
 	ingressInformer := ingressinformer.Get(ctx)
-	httprouteInformer := httprouteinformer.Get(ctx)
-	referenceGrantInformer := referencegrantinformer.Get(ctx)
-	gatewayInformer := gatewayinformer.Get(ctx)
+	httprouteInformer := getHTTPRouteInformer(ctx)
+	referenceGrantInformer := getReferenceGrantInformer(ctx)
+	gatewayInformer := getGatewayInformer(ctx)
 	endpointsInformer := endpointsinformer.Get(ctx)
+	secretInformer := getSecretInformer(ctx)
+
+	hostnameRouteInformer := getHostnameRouteInformer(ctx)
 
+	apiVersions := apiVersionsFromContext(ctx)
 	c := &Reconciler{
-		gwapiclient:          gwapiclient.Get(ctx),
+		gwapiclient:          newVersionedClient(gwapiclient.Get(ctx), apiVersions),
 		httprouteLister:      httprouteInformer.Lister(),
 		referenceGrantLister: referenceGrantInformer.Lister(),
 		gatewayLister:        gatewayInformer.Lister(),
+		secrets:              newSecretCache(kubeclient.Get(ctx), secretInformer.GetIndexer()),
+		apiVersions:          apiVersions,
 	}
 
-	filterFunc := reconciler.AnnotationFilterFunc(networking.IngressClassAnnotationKey, gatewayAPIIngressClassName, false)
+	filterFunc := ingressClassFilterFunc(ingressClass)
 
-	impl := ingressreconciler.NewImpl(ctx, c, gatewayAPIIngressClassName, func(impl *controller.Impl) controller.Options {
+	var configStore *config.Store
+	impl := ingressreconciler.NewImpl(ctx, c, ingressClass, func(impl *controller.Impl) controller.Options {
 		configsToResync := []interface{}{
 			&networkcfg.Config{},
 			&config.Gateway{},
@@ -77,14 +143,53 @@ func NewController(
 		resync := configmap.TypeFilter(configsToResync...)(func(string, interface{}) {
 			impl.GlobalResync(ingressInformer.Informer())
 		})
-		configStore := config.NewStore(logging.WithLogger(ctx, logger.Named("config-store")), resync)
+		configStore = config.NewStore(logging.WithLogger(ctx, logger.Named("config-store")), resync)
 		configStore.WatchConfigs(cmw)
-		return controller.Options{
-			ConfigStore:       configStore,
-			PromoteFilterFunc: filterFunc,
-		}
+		return controller.Options{ConfigStore: configStore}
 	})
 
+	// config-tracing belongs to Serving, so it may be missing. Static
+	// watchers can't default it, and tracing stays off with those.
+	if w, ok := cmw.(configmap.DefaultingWatcher); ok {
+		w.WatchWithDefault(corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: tracingconfig.ConfigName, Namespace: system.Namespace()},
+		})
+		tracer, err := tracing.SetupPublishingWithDynamicConfig(logger.Named("tracing"), cmw, componentName, tracingconfig.ConfigName)
+		if err != nil {
+			logger.Fatalw("Failed to set up trace publishing", "error", err)
+		}
+		go func() {
+			<-ctx.Done()
+			if err := tracer.Shutdown(context.Background()); err != nil {
+				logger.Warnw("Failed to flush the traces", "error", err)
+			}
+		}()
+	}
+
+	// NewImpl's own reconciler is replaced: the generated reconciler only honors
+	// networking.IngressClassAnnotationKey, so we hand it a lister that reports
+	// the class under that key whichever key the Ingress carries it under, and
+	// take the key off again before reconciling.
+	generated := ingressreconciler.NewReconciler(ctx, logger, networkingclient.Get(ctx),
+		&ingressClassLister{IngressLister: ingressInformer.Lister()}, controller.GetEventRecorder(ctx),
+		&classKeyReconciler{Reconciler: c, lister: ingressInformer.Lister()}, ingressClass,
+		controller.Options{
+			ConfigStore:   configStore,
+			FinalizerName: finalizerName,
+		})
+
+	impl.Reconciler = &classChangeReconciler{
+		leaderAwareReconciler: generated.(leaderAwareReconciler),
+		reconciler:            c,
+		lister:                ingressInformer.Lister(),
+		client:                networkingclient.Get(ctx),
+		configStore:           configStore,
+		recorder:              controller.GetEventRecorder(ctx),
+		classFilter:           filterFunc,
+		finalizerName:         finalizerName,
+		scope:                 namespaceScopeFromContext(ctx),
+	}
+
 	logger.Info("Setting up Ingress event handlers")
 	ingressHandler := cache.FilteringResourceEventHandler{
 		FilterFunc: filterFunc,
@@ -93,6 +198,16 @@ func NewController(
 
 	ingressInformer.Informer().AddEventHandler(ingressHandler)
 
+	// Ingresses moving to another class are no longer seen by the filter above,
+	// so catch the transition to clean up after them.
+	ingressInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if filterFunc(oldObj) && !filterFunc(newObj) {
+				impl.Enqueue(newObj)
+			}
+		},
+	})
+
 	httprouteInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: filterFunc,
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
@@ -102,14 +217,54 @@ func NewController(
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
 
+	c.tracker = impl.Tracker
+	c.enqueueAfter = impl.EnqueueAfter
+	secretInformer.AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(impl.Tracker.OnChanged, corev1.SchemeGroupVersion.WithKind("Secret"))))
+	secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{DeleteFunc: c.secrets.forget})
+
+	if port := debugPortFromContext(ctx); port > 0 {
+		c.debug = newIngressDebugger()
+		serveDebug(ctx, port, c.debug)
+	}
+
+	c.listeners = newListenerAggregator(logger.Named("listener-aggregator"), c.gwapiclient, c.gatewayLister, impl.EnqueueKey)
+	c.listeners.debug = c.debug
+	c.conflicts = newConflictDetector(hostnameRouteInformer.GetIndexer(), ingressInformer.Lister(), c.gatewayLister, impl.EnqueueKey)
+	gatewayInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.listeners.gatewayChanged,
+		UpdateFunc: func(_, obj interface{}) {
+			c.listeners.gatewayChanged(obj)
+			c.conflicts.retryLosers(obj)
+		},
+	})
+
+	// Ingresses that lost a hostname conflict get another chance whenever
+	// whatever they lost to may have gone away.
+	hostnameRouteInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.conflicts.retryLosers,
+	})
+	ingressInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.conflicts.retryLosers,
+	})
+	c.listeners.Start(ctx.Done())
+
+	var probeTargets status.ProbeTargetLister = NewProbeTargetLister(logger, endpointsInformer.Lister())
+	if c.debug != nil {
+		probeTargets = &debugTargetLister{ProbeTargetLister: probeTargets, debug: c.debug}
+	}
+
 	statusProber := status.NewProber(
 		logger.Named("status-manager"),
-		NewProbeTargetLister(logger, endpointsInformer.Lister()),
+		probeTargets,
 		func(ing *v1alpha1.Ingress) {
 			logger.Debugf("Ready callback triggered for ingress: %s/%s", ing.Namespace, ing.Name)
+			c.traces.annotate(ing, "ProbeReady", "The prober found the Ingress ready")
+			c.debug.probed(ing, true, nil)
 			impl.EnqueueKey(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
 		})
-	c.statusManager = statusProber
+	probes := &probeMetrics{Manager: statusProber}
+	c.statusManager = &probeTracing{Manager: probes}
 	// TODO: Bring up gateway-api community to discuss about probing.
 	// related to https://github.com/knative-sandbox/net-gateway-api/issues/18
 	statusProber.Start(ctx.Done())
@@ -119,5 +274,60 @@ func NewController(
 		DeleteFunc: impl.Tracker.OnDeletedObserver,
 	})
 
+	// Stop the clocks of the time to ready metrics, and drop the traces,
+	// debug state and Secret references, of Ingresses that go away.
+	ingressInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+			ingKey := types.NamespacedName{Namespace: namespace, Name: name}
+			c.readiness.forget(ingKey)
+			probes.probing.forget(ingKey)
+			c.traces.forget(ingKey)
+			c.debug.forget(ingKey)
+			c.secrets.retain(ingKey, nil)
+		},
+	})
+
+	(&managedObjectsReporter{
+		configStore:          configStore,
+		httprouteLister:      c.httprouteLister,
+		referenceGrantLister: c.referenceGrantLister,
+		gatewayLister:        c.gatewayLister,
+	}).Start(ctx)
+
 	return impl
 }
+
+// ingressClassFilterFunc accepts objects of the given ingress class, looking
+// at both ingress class annotation keys with the same precedence as Serving.
+func ingressClassFilterFunc(class string) func(interface{}) bool {
+	return func(obj interface{}) bool {
+		if mo, ok := obj.(metav1.Object); ok {
+			return networking.GetIngressClass(mo.GetAnnotations()) == class
+		}
+		return false
+	}
+}
+
+func newEventRecorder(ctx context.Context, agentName string) record.EventRecorder {
+	logger := logging.FromContext(ctx)
+
+	eventBroadcaster := record.NewBroadcaster()
+	watches := []watch.Interface{
+		eventBroadcaster.StartLogging(logger.Named("event-broadcaster").Infof),
+		eventBroadcaster.StartRecordingToSink(
+			&typedcorev1.EventSinkImpl{Interface: kubeclient.Get(ctx).CoreV1().Events("")}),
+	}
+	go func() {
+		<-ctx.Done()
+		for _, w := range watches {
+			w.Stop()
+		}
+	}()
+
+	return eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: agentName})
+}
@@ -17,26 +17,44 @@ limitations under the License.
 package ingress
 
 import (
+	"context"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakemetadata "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/rest"
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
 	networkcfg "knative.dev/networking/pkg/config"
 	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/injection"
 	"knative.dev/pkg/system"
 
-	_ "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1alpha2/referencegrant/fake"
-	_ "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1beta1/gateway/fake"
-	_ "knative.dev/net-gateway-api/pkg/client/injection/informers/apis/v1beta1/httproute/fake"
+	_ "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/fake"
+	filteredFactory "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/filtered"
+	_ "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/filtered/fake"
 	_ "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/ingress/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints/fake"
 
 	. "knative.dev/pkg/reconciler/testing"
 )
 
+func init() {
+	// These informers are set up by this package rather than by a
+	// generated injection package, so they have no fake to import.
+	injection.Fake.RegisterClient(func(ctx context.Context, _ *rest.Config) context.Context {
+		return context.WithValue(ctx, metadataClientKey{}, fakemetadata.NewSimpleMetadataClient(fakemetadata.NewTestScheme()))
+	})
+	injection.Fake.RegisterInformer(withSecretInformer)
+	injection.Fake.RegisterInformer(withHTTPRouteInformer)
+	injection.Fake.RegisterInformer(withHostnameRouteInformer)
+	injection.Fake.RegisterInformer(withGatewayInformer)
+	injection.Fake.RegisterInformer(withReferenceGrantInformer)
+}
+
 func TestNew(t *testing.T) {
-	ctx, _ := SetupFakeContext(t)
+	ctx, _ := SetupFakeContext(t, withManagedBySelector)
 
 	c := NewController(ctx, configmap.NewStaticWatcher(&corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -54,3 +72,45 @@ func TestNew(t *testing.T) {
 		t.Fatal("Expected NewController to return a non-nil value")
 	}
 }
+
+func TestIngressClass(t *testing.T) {
+	tests := []struct {
+		name          string
+		class         string
+		wantClass     string
+		wantComponent string
+		wantFinalizer string
+	}{{
+		name:          "default class",
+		wantClass:     gatewayAPIIngressClassName,
+		wantComponent: "net-gateway-api-controller",
+		wantFinalizer: "ingresses.networking.internal.knative.dev",
+	}, {
+		name:          "custom class",
+		class:         "contour.gateway-api.ingress.networking.knative.dev",
+		wantClass:     "contour.gateway-api.ingress.networking.knative.dev",
+		wantComponent: "contour.gateway-api.ingress.networking.knative.dev",
+		wantFinalizer: "contour.gateway-api.ingress.networking.knative.dev/finalizer",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class := IngressClassFromContext(WithIngressClass(context.Background(), test.class))
+			if class != test.wantClass {
+				t.Errorf("IngressClassFromContext() = %q, want: %q", class, test.wantClass)
+			}
+			if got := ComponentName(class); got != test.wantComponent {
+				t.Errorf("ComponentName() = %q, want: %q", got, test.wantComponent)
+			}
+			if got := finalizerFor(class); got != test.wantFinalizer {
+				t.Errorf("finalizerFor() = %q, want: %q", got, test.wantFinalizer)
+			}
+		})
+	}
+}
+
+// withManagedBySelector sets up the label selector of the filtered informer
+// factory, as main does.
+func withManagedBySelector(ctx context.Context) context.Context {
+	return filteredFactory.WithSelectors(ctx, resources.ManagedBySelector)
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	nethttp "knative.dev/networking/pkg/http"
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/ingress"
+	"knative.dev/networking/pkg/prober"
+	"knative.dev/networking/pkg/status"
+	"knative.dev/pkg/kmp"
+	"knative.dev/pkg/logging"
+)
+
+// debugPathPrefix is where the debug endpoint serves the state of each
+// Ingress, under /debug/ingress/<namespace>/<name>.
+const debugPathPrefix = "/debug/ingress/"
+
+type debugPortKey struct{}
+
+// WithDebugPort enables the debug endpoint on the given port of localhost.
+// It's off when the port is zero.
+func WithDebugPort(ctx context.Context, port int) context.Context {
+	return context.WithValue(ctx, debugPortKey{}, port)
+}
+
+func debugPortFromContext(ctx context.Context) int {
+	port, _ := ctx.Value(debugPortKey{}).(int)
+	return port
+}
+
+// ingressDebugState is what the controller thinks of an Ingress, as served
+// by the debug endpoint.
+type ingressDebugState struct {
+	Generation   int64     `json:"generation"`
+	ReconciledAt time.Time `json:"reconciledAt"`
+
+	// HTTPRoutes are the desired HTTPRoutes of the last reconcile.
+	HTTPRoutes []*gatewayapi.HTTPRoute `json:"httpRoutes,omitempty"`
+	// Listeners are the desired listeners of the last reconcile, by Gateway.
+	Listeners map[string][]*gatewayapi.Listener `json:"listeners,omitempty"`
+	// LastDiff is the last change the controller applied.
+	LastDiff *appliedDiff `json:"lastDiff,omitempty"`
+	// Probe is the state of the probing of the Ingress.
+	Probe *probeDebugState `json:"probe,omitempty"`
+}
+
+type appliedDiff struct {
+	Kind string    `json:"kind"`
+	Name string    `json:"name"`
+	At   time.Time `json:"at"`
+	Diff string    `json:"diff"`
+}
+
+// probeDebugState is what we know of the probing of an Ingress. The prober
+// only tells whether all the gateway pods serve the Ingress yet, so the
+// state of each pod is probed again whenever the endpoint is read.
+type probeDebugState struct {
+	Ready     bool       `json:"ready"`
+	CheckedAt time.Time  `json:"checkedAt"`
+	ReadyAt   *time.Time `json:"readyAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Targets are the gateway pods probed, by port.
+	Targets []probeTargetDebug `json:"targets,omitempty"`
+
+	generation int64
+	// hash is what the gateway pods answer once they serve the generation.
+	hash string
+}
+
+type probeTargetDebug struct {
+	PodPort string          `json:"podPort"`
+	Port    string          `json:"port"`
+	URLs    []string        `json:"urls"`
+	Pods    []podProbeDebug `json:"pods"`
+
+	podIPs []string
+	urls   []*url.URL
+}
+
+// podProbeDebug is the outcome of probing a gateway pod for each URL of the
+// Ingress.
+type podProbeDebug struct {
+	IP    string `json:"ip"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// podProbeTimeout bounds the probing of each gateway pod when the endpoint
+// is read.
+const podProbeTimeout = 2 * time.Second
+
+// ingressDebugger records the state of each Ingress for the debug endpoint.
+// Its methods do nothing on a nil ingressDebugger, which is what the
+// Reconciler has unless the endpoint is enabled.
+type ingressDebugger struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]*ingressDebugState
+
+	// probePod probes the gateway pod at ip:port for the given URL, expecting
+	// the given hash of the Ingress.
+	probePod func(ctx context.Context, ip, port string, u *url.URL, hash string) error
+}
+
+func newIngressDebugger() *ingressDebugger {
+	return &ingressDebugger{
+		states:   make(map[types.NamespacedName]*ingressDebugState),
+		probePod: probeGatewayPod,
+	}
+}
+
+// stateLocked returns the state of the Ingress. d.mu must be held.
+func (d *ingressDebugger) stateLocked(ing *v1alpha1.Ingress) *ingressDebugState {
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+	state, ok := d.states[key]
+	if !ok {
+		state = &ingressDebugState{Generation: ing.Generation}
+		d.states[key] = state
+	}
+	return state
+}
+
+// probeLocked returns the probe state of the current generation of the
+// Ingress. d.mu must be held.
+func (d *ingressDebugger) probeLocked(ing *v1alpha1.Ingress) *probeDebugState {
+	state := d.stateLocked(ing)
+	if state.Probe == nil || state.Probe.generation != ing.Generation {
+		state.Probe = &probeDebugState{generation: ing.Generation}
+	}
+	return state.Probe
+}
+
+// reconciling starts the record of a reconcile of the Ingress.
+func (d *ingressDebugger) reconciling(ing *v1alpha1.Ingress) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.stateLocked(ing)
+	state.Generation = ing.Generation
+	state.ReconciledAt = time.Now()
+	state.HTTPRoutes = nil
+	state.Listeners = nil
+}
+
+// desiredHTTPRoute records an HTTPRoute the Ingress should have.
+func (d *ingressDebugger) desiredHTTPRoute(ing *v1alpha1.Ingress, route *gatewayapi.HTTPRoute) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.stateLocked(ing)
+	state.HTTPRoutes = append(state.HTTPRoutes, route.DeepCopy())
+}
+
+// desiredListeners records the listeners the Ingress should have on each
+// Gateway.
+func (d *ingressDebugger) desiredListeners(ing *v1alpha1.Ingress, listeners map[types.NamespacedName][]*gatewayapi.Listener) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.stateLocked(ing)
+	state.Listeners = make(map[string][]*gatewayapi.Listener, len(listeners))
+	for gwName, ls := range listeners {
+		for _, l := range ls {
+			state.Listeners[gwName.String()] = append(state.Listeners[gwName.String()], l.DeepCopy())
+		}
+	}
+}
+
+// applied records a change made to an object of the Ingress, from before
+// to after. A nil before stands for a creation.
+func (d *ingressDebugger) applied(ing *v1alpha1.Ingress, kind, name string, before, after interface{}) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stateLocked(ing).LastDiff = makeAppliedDiff(kind, name, before, after)
+}
+
+// appliedTo records a change made on behalf of the Ingress of the given key,
+// like applied, provided the Ingress was reconciled already.
+func (d *ingressDebugger) appliedTo(key types.NamespacedName, kind, name string, before, after interface{}) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state, ok := d.states[key]; ok {
+		state.LastDiff = makeAppliedDiff(kind, name, before, after)
+	}
+}
+
+func makeAppliedDiff(kind, name string, before, after interface{}) *appliedDiff {
+	diff := "created"
+	if before != nil {
+		var err error
+		if diff, err = kmp.SafeDiff(before, after); err != nil {
+			diff = fmt.Sprint("failed to diff: ", err)
+		}
+	}
+	return &appliedDiff{Kind: kind, Name: name, At: time.Now(), Diff: diff}
+}
+
+// probed records the outcome of a readiness check of the Ingress.
+func (d *ingressDebugger) probed(ing *v1alpha1.Ingress, ready bool, err error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	probe := d.probeLocked(ing)
+	now := time.Now()
+	probe.CheckedAt = now
+	probe.Error = ""
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	if ready && !probe.Ready {
+		probe.ReadyAt = &now
+	}
+	probe.Ready = ready
+}
+
+// probeTargets records the gateway pods probed for the Ingress.
+func (d *ingressDebugger) probeTargets(ing *v1alpha1.Ingress, targets []status.ProbeTarget) {
+	if d == nil {
+		return
+	}
+	// This is the hash the prober expects, as ing already carries the probe
+	// annotation.
+	var hash string
+	if bytes, err := ingress.ComputeHash(ing); err == nil {
+		hash = fmt.Sprintf("%x", bytes)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	probe := d.probeLocked(ing)
+	probe.hash = hash
+	probe.Targets = make([]probeTargetDebug, 0, len(targets))
+	for _, target := range targets {
+		urls := make([]string, 0, len(target.URLs))
+		for _, u := range target.URLs {
+			urls = append(urls, u.String())
+		}
+		probe.Targets = append(probe.Targets, probeTargetDebug{
+			PodPort: target.PodPort,
+			Port:    target.Port,
+			URLs:    urls,
+			podIPs:  target.PodIPs.List(),
+			urls:    target.URLs,
+		})
+	}
+}
+
+// probePods probes each gateway pod of the targets for every URL, and fills
+// in the outcome.
+func (d *ingressDebugger) probePods(ctx context.Context, hash string, targets []probeTargetDebug) {
+	var wg sync.WaitGroup
+	for i := range targets {
+		target := &targets[i]
+		target.Pods = make([]podProbeDebug, len(target.podIPs))
+		for j, ip := range target.podIPs {
+			pod := &target.Pods[j]
+			pod.IP = ip
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(ctx, podProbeTimeout)
+				defer cancel()
+				for _, u := range target.urls {
+					if err := d.probePod(ctx, pod.IP, target.PodPort, u, hash); err != nil {
+						pod.Error = fmt.Sprintf("%s: %v", u, err)
+						return
+					}
+				}
+				pod.Ready = true
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// probeGatewayPod probes the gateway pod the way status.Prober does.
+func probeGatewayPod(ctx context.Context, ip, port string, u *url.URL, hash string) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		//nolint:gosec
+		// Like the prober, we only want to know that the Gateway is configured.
+		InsecureSkipVerify: true,
+	}
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+	defer transport.CloseIdleConnections()
+
+	probeURL := *u
+	probeURL.Path = path.Join(probeURL.Path, nethttp.HealthCheckPath)
+	ok, err := prober.Do(ctx, transport, probeURL.String(),
+		prober.WithHeader(header.UserAgentKey, header.IngressReadinessUserAgent),
+		prober.WithHeader(header.ProbeKey, header.ProbeValue),
+		prober.WithHeader(header.HashKey, header.HashValueOverride),
+		prober.ExpectsStatusCodes([]int{http.StatusOK}),
+		prober.ExpectsHeader(header.HashKey, hash))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the pod doesn't serve this generation yet")
+	}
+	return nil
+}
+
+// forget drops the state of an Ingress that went away.
+func (d *ingressDebugger) forget(key types.NamespacedName) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.states, key)
+}
+
+// ServeHTTP serves the state of the Ingress of the path as JSON, with the
+// state of each gateway pod probed.
+func (d *ingressDebugger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, debugPathPrefix), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "expected "+debugPathPrefix+"<namespace>/<name>", http.StatusBadRequest)
+		return
+	}
+	key := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+	// Copy the state, so the pods are probed without holding the lock. The
+	// recording methods replace the fields rather than mutate them, except
+	// for the probe state.
+	d.mu.Lock()
+	var state ingressDebugState
+	stored, ok := d.states[key]
+	if ok {
+		state = *stored
+		if stored.Probe != nil {
+			probe := *stored.Probe
+			probe.Targets = append([]probeTargetDebug(nil), stored.Probe.Targets...)
+			state.Probe = &probe
+		}
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("Ingress %s wasn't reconciled by this controller", key), http.StatusNotFound)
+		return
+	}
+	if state.Probe != nil {
+		d.probePods(r.Context(), state.Probe.hash, state.Probe.Targets)
+	}
+
+	body, err := json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		logging.FromContext(r.Context()).Warnw("Failed to write the debug state of "+key.String(), zap.Error(err))
+	}
+}
+
+// index lists the Ingresses with a state, for lack of a name in the path.
+func (d *ingressDebugger) index(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.states))
+	for key := range d.states {
+		keys = append(keys, key.String())
+	}
+	d.mu.Unlock()
+	sort.Strings(keys)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		logging.FromContext(r.Context()).Warnw("Failed to write the debugged Ingresses", zap.Error(err))
+	}
+}
+
+// serveDebug serves the debug endpoint on localhost until the context is
+// done. It's never exposed beyond the pod: kubectl port-forward gets to it.
+func serveDebug(ctx context.Context, port int, d *ingressDebugger) {
+	logger := logging.FromContext(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(strings.TrimSuffix(debugPathPrefix, "/"), d.index)
+	mux.Handle(debugPathPrefix, d)
+	server := &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		// The handlers log with the logger of the controller.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		logger.Infof("Serving the Ingress debug endpoint on %s%s", server.Addr, debugPathPrefix)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorw("The Ingress debug endpoint failed", zap.Error(err))
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+}
+
+// debugTargetLister records the probe targets of each Ingress.
+type debugTargetLister struct {
+	status.ProbeTargetLister
+	debug *ingressDebugger
+}
+
+// ListProbeTargets implements status.ProbeTargetLister
+func (l *debugTargetLister) ListProbeTargets(ctx context.Context, ing *v1alpha1.Ingress) ([]status.ProbeTarget, error) {
+	targets, err := l.ProbeTargetLister.ListProbeTargets(ctx, ing)
+	if err == nil {
+		l.debug.probeTargets(ing, targets)
+	}
+	return targets, err
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingingress "knative.dev/networking/pkg/ingress"
+	"knative.dev/networking/pkg/status"
+)
+
+func TestIngressDebugger(t *testing.T) {
+	d := newIngressDebugger()
+	var (
+		mu         sync.Mutex
+		probedHash string
+	)
+	d.probePod = func(_ context.Context, ip, port string, _ *url.URL, hash string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		probedHash = hash
+		if ip == "10.0.0.2" {
+			return errors.New("unexpected hash")
+		}
+		return nil
+	}
+	ingress := ing(withBasicSpec, withGatewayAPIClass, func(i *v1alpha1.Ingress) { i.Generation = 2 })
+	route := httpRoute(t, ingress).(*gatewayapi.HTTPRoute)
+	gwName := types.NamespacedName{Namespace: testNamespace, Name: publicName}
+	listener := &gw(defaultListener).Spec.Listeners[0]
+
+	d.reconciling(ingress)
+	d.desiredHTTPRoute(ingress, route)
+	d.desiredListeners(ingress, map[types.NamespacedName][]*gatewayapi.Listener{gwName: {listener}})
+	d.applied(ingress, httpRouteKind, route.Name, nil, route)
+	d.probeTargets(ingress, []status.ProbeTarget{{
+		PodIPs:  sets.NewString("10.0.0.1", "10.0.0.2"),
+		PodPort: "8080",
+		Port:    "80",
+		URLs:    []*url.URL{{Scheme: "http", Host: "example.com"}},
+	}})
+	d.probed(ingress, false, errors.New("no endpoints"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(debugPathPrefix + ingress.Namespace + "/" + ingress.Name)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want: %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var state ingressDebugState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatal("Failed to decode the state:", err)
+	}
+	if state.Generation != 2 {
+		t.Errorf("Generation = %d, want: 2", state.Generation)
+	}
+	if len(state.HTTPRoutes) != 1 || state.HTTPRoutes[0].Name != route.Name {
+		t.Errorf("HTTPRoutes = %v, want: [%s]", state.HTTPRoutes, route.Name)
+	}
+	if got := state.Listeners[gwName.String()]; len(got) != 1 || got[0].Name != listener.Name {
+		t.Errorf("Listeners = %v, want the default listener on %s", state.Listeners, gwName)
+	}
+	if state.LastDiff == nil || state.LastDiff.Diff != "created" {
+		t.Errorf("LastDiff = %v, want the creation of the HTTPRoute", state.LastDiff)
+	}
+	if state.Probe == nil || state.Probe.Ready || state.Probe.Error != "no endpoints" {
+		t.Fatalf("Probe = %v, want not ready with the probe error", state.Probe)
+	}
+	wantPods := []podProbeDebug{
+		{IP: "10.0.0.1", Ready: true},
+		{IP: "10.0.0.2", Error: "http://example.com: unexpected hash"},
+	}
+	if len(state.Probe.Targets) != 1 || !cmp.Equal(state.Probe.Targets[0].Pods, wantPods) {
+		t.Errorf("Probe.Targets = %+v, want pods: %+v", state.Probe.Targets, wantPods)
+	}
+	if bytes, _ := networkingingress.ComputeHash(ingress); probedHash != fmt.Sprintf("%x", bytes) {
+		t.Errorf("Probed hash = %q, want the hash of the Ingress", probedHash)
+	}
+
+	before := []gatewayapi.Listener{}
+	after := []gatewayapi.Listener{*listener}
+	d.appliedTo(types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name}, "Gateway listeners", gwName.String(), before, after)
+	d.appliedTo(types.NamespacedName{Namespace: "ns", Name: "unknown"}, "Gateway listeners", gwName.String(), before, after)
+	state = ingressDebugState{}
+	if err := json.Unmarshal(get(debugPathPrefix+ingress.Namespace+"/"+ingress.Name).Body.Bytes(), &state); err != nil {
+		t.Fatal("Failed to decode the state:", err)
+	}
+	if state.LastDiff == nil || state.LastDiff.Kind != "Gateway listeners" || state.LastDiff.Name != gwName.String() || state.LastDiff.Diff == "created" {
+		t.Errorf("LastDiff = %v, want the patch of the listeners of %s", state.LastDiff, gwName)
+	}
+	if rec := get(debugPathPrefix + "ns/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of an Ingress only patched for = %d, want: %d", rec.Code, http.StatusNotFound)
+	}
+
+	d.probed(ingress, true, nil)
+	state = ingressDebugState{}
+	if err := json.Unmarshal(get(debugPathPrefix+ingress.Namespace+"/"+ingress.Name).Body.Bytes(), &state); err != nil {
+		t.Fatal("Failed to decode the state:", err)
+	}
+	if !state.Probe.Ready || state.Probe.ReadyAt == nil || state.Probe.Error != "" {
+		t.Errorf("Probe = %v, want ready", state.Probe)
+	}
+
+	if rec := get(debugPathPrefix + "ns/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown Ingress = %d, want: %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := get(debugPathPrefix + "ns"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET without a name = %d, want: %d", rec.Code, http.StatusBadRequest)
+	}
+
+	d.forget(types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name})
+	if rec := get(debugPathPrefix + ingress.Namespace + "/" + ingress.Name); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a forgotten Ingress = %d, want: %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestIngressDebuggerNil(t *testing.T) {
+	var d *ingressDebugger
+	ingress := ing(withBasicSpec)
+	// None of these may panic when the endpoint is off.
+	d.reconciling(ingress)
+	d.desiredHTTPRoute(ingress, httpRoute(t, ingress).(*gatewayapi.HTTPRoute))
+	d.applied(ingress, httpRouteKind, "name", nil, nil)
+	d.appliedTo(types.NamespacedName{}, gatewayKind, "name", nil, nil)
+	d.probeTargets(ingress, nil)
+	d.probed(ingress, true, nil)
+	d.forget(types.NamespacedName{})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+)
+
+// defaultListenerPrefix is the prefix of the names of the default listeners
+// configured in config-gateway.
+const defaultListenerPrefix = listenerPrefix + "default-"
+
+// defaultListenerName returns the name of the default listener for the
+// given hostname, e.g. kni-default-wildcard-example-com for *.example.com.
+func defaultListenerName(hostname string) gatewayapi.SectionName {
+	name := strings.NewReplacer("*", "wildcard", ".", "-").Replace(hostname)
+	return gatewayapi.SectionName(defaultListenerPrefix + name)
+}
+
+// isDefaultListener returns whether the listener of the given name is one of
+// the default listeners.
+func isDefaultListener(name gatewayapi.SectionName) bool {
+	return strings.HasPrefix(string(name), defaultListenerPrefix)
+}
+
+// makeDefaultListeners returns the Gateway listeners for the configured
+// default listeners.
+func makeDefaultListeners(defaults []config.DefaultListener) []*gatewayapi.Listener {
+	mode := gatewayapi.TLSModeTerminate
+	from := gatewayapi.NamespacesFromAll
+
+	listeners := make([]*gatewayapi.Listener, 0, len(defaults))
+	for _, d := range defaults {
+		hostname := gatewayapi.Hostname(d.Hostname)
+		namespace := gatewayapi.Namespace(d.Certificate.Namespace)
+		listeners = append(listeners, &gatewayapi.Listener{
+			Name:     defaultListenerName(d.Hostname),
+			Hostname: &hostname,
+			Port:     443,
+			Protocol: gatewayapi.HTTPSProtocolType,
+			TLS: &gatewayapi.GatewayTLSConfig{
+				Mode: &mode,
+				CertificateRefs: []gatewayapi.SecretObjectReference{{
+					Group:     (*gatewayapi.Group)(pointer.String("")),
+					Kind:      (*gatewayapi.Kind)(pointer.String("Secret")),
+					Name:      gatewayapi.ObjectName(d.Certificate.Name),
+					Namespace: &namespace,
+				}},
+			},
+			AllowedRoutes: &gatewayapi.AllowedRoutes{
+				Namespaces: &gatewayapi.RouteNamespaces{
+					From: &from,
+				},
+				Kinds: []gatewayapi.RouteGroupKind{},
+			},
+		})
+	}
+	return listeners
+}
+
+// matchDefaultListeners returns the default listeners serving the given
+// hosts, and whether every host is served by one of them.
+func matchDefaultListeners(defaults []*gatewayapi.Listener, hosts []string) ([]*gatewayapi.Listener, bool) {
+	var matched []*gatewayapi.Listener
+	seen := make(map[gatewayapi.SectionName]struct{}, len(defaults))
+	for _, host := range hosts {
+		found := false
+		for _, l := range defaults {
+			if !hostnameMatches(string(*l.Hostname), host) {
+				continue
+			}
+			found = true
+			if _, ok := seen[l.Name]; !ok {
+				seen[l.Name] = struct{}{}
+				matched = append(matched, l)
+			}
+			break
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return matched, len(matched) > 0
+}
+
+// hostnameMatches returns whether a listener for the given hostname serves
+// the host. As in Gateway API, a wildcard matches one or more labels.
+func hostnameMatches(hostname, host string) bool {
+	if suffix := strings.TrimPrefix(hostname, "*"); suffix != hostname {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return hostname == host
+}
+
+// httpSections returns the names of the plain HTTP listeners of the Gateway.
+// Routes attaching to a default listener through its sectionName have to
+// name these too, or they'd stop serving plain HTTP.
+func (c *Reconciler) httpSections(gwName types.NamespacedName) ([]gatewayapi.SectionName, error) {
+	gw, err := c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+	if err != nil {
+		return nil, err
+	}
+	var sections []gatewayapi.SectionName
+	for _, l := range gw.Spec.Listeners {
+		if l.Protocol == gatewayapi.HTTPProtocolType {
+			sections = append(sections, l.Name)
+		}
+	}
+	return sections, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/ingress"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+var wildcardListener = config.DefaultListener{
+	Hostname:    "*.example.com",
+	Certificate: types.NamespacedName{Namespace: testNamespace, Name: "wildcard-example-com"},
+}
+
+func TestReconcileDefaultListeners(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	external := cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP]
+	external.DefaultListeners = []config.DefaultListener{wildcardListener}
+	cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP] = external
+
+	withHost := func(i *v1alpha1.Ingress) {
+		i.Spec.Rules[0].Hosts = []string{"www.example.com"}
+	}
+	withWildcardListener := func(g *gatewayapi.Gateway) {
+		g.Spec.Listeners = append(g.Spec.Listeners, *makeDefaultListeners(external.DefaultListeners)[0])
+	}
+
+	table := TableTest{{
+		Name: "routes attach to the default listener",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withHost),
+			gw(defaultListener, withWildcardListener),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost), "http", defaultListenerName("*.example.com")),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withHost, readyStatus),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "www.example.com"`),
+		},
+	}, {
+		Name: "default listener not on the Gateway yet",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withHost, withFinalizer),
+			gw(defaultListener),
+			sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost), "http", defaultListenerName("*.example.com")),
+		}, servicesAndEndpoints...),
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "add",
+			Path:      "/spec/listeners/-",
+			Value:     makeDefaultListeners(external.DefaultListeners)[0],
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withHost, withFinalizer, readyStatus),
+		}},
+	}, {
+		Name: "TLS hosts served by the default listener",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			// There's no Secret, and we don't need one.
+			ing(withBasicSpec, withGatewayAPIClass, withTLS("secret"), withFinalizer),
+			gw(defaultListener, withWildcardListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS("secret"))),
+		}, servicesAndEndpoints...),
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS("secret"), withFinalizer, readyStatus),
+		}},
+	}}
+
+	table.Test(t, reconcilerFactory(cfg))
+}
+
+func TestMatchDefaultListeners(t *testing.T) {
+	defaults := makeDefaultListeners([]config.DefaultListener{wildcardListener, {
+		Hostname:    "api.example.org",
+		Certificate: types.NamespacedName{Namespace: testNamespace, Name: "api-example-org"},
+	}})
+
+	tests := []struct {
+		name   string
+		hosts  []string
+		want   []gatewayapi.SectionName
+		wantOK bool
+	}{{
+		name:   "wildcard",
+		hosts:  []string{"www.example.com", "foo.bar.example.com"},
+		want:   []gatewayapi.SectionName{"kni-default-wildcard-example-com"},
+		wantOK: true,
+	}, {
+		name:   "wildcard and exact hostname",
+		hosts:  []string{"www.example.com", "api.example.org"},
+		want:   []gatewayapi.SectionName{"kni-default-wildcard-example-com", "kni-default-api-example-org"},
+		wantOK: true,
+	}, {
+		name:  "the wildcard doesn't match the domain itself",
+		hosts: []string{"www.example.com", "example.com"},
+	}, {
+		name:  "no hosts",
+		hosts: []string{},
+	}, {
+		name:  "partial label",
+		hosts: []string{"wwwexample.com"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := matchDefaultListeners(defaults, test.hosts)
+			if ok != test.wantOK {
+				t.Errorf("matchDefaultListeners() = %v, want: %v", ok, test.wantOK)
+			}
+			var names []gatewayapi.SectionName
+			for _, l := range got {
+				names = append(names, l.Name)
+			}
+			if diff := cmp.Diff(test.want, names); diff != "" {
+				t.Error("Matched listeners (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestListenerAggregatorPrunesDefaults(t *testing.T) {
+	ctx := context.Background()
+	stale := testListener("kni-default-wildcard-example-org", "*.example.org")
+	agg, client, _ := newTestAggregator(t, gw(defaultListener, func(g *gatewayapi.Gateway) {
+		g.Spec.Listeners = append(g.Spec.Listeners, *stale)
+	}))
+
+	wanted := makeDefaultListeners([]config.DefaultListener{wildcardListener})
+	agg.setDefaults(gwKey, wanted)
+	if err := agg.syncGateway(ctx, gwKey); err != nil {
+		t.Fatal("syncGateway() =", err)
+	}
+
+	patches := patchActions(client.Actions())
+	if len(patches) != 1 {
+		t.Fatalf("Got %d patches, want: 1", len(patches))
+	}
+	want := gwPatch(t, listenersPatch{
+		{Operation: "test", Path: "/spec/listeners/1/name", Value: stale.Name},
+		{Operation: "remove", Path: "/spec/listeners/1"},
+		{Operation: "add", Path: "/spec/listeners/-", Value: wanted[0]},
+	})
+	if got := string(patches[0].GetPatch()); got != string(want.Patch) {
+		t.Errorf("Patch = %s, want: %s", got, want.Patch)
+	}
+}
+
+// sectionRoute returns the HTTPRoute for the first rule of the Ingress,
+// attached to the given listeners of its Gateway.
+func sectionRoute(t *testing.T, cfg *config.Config, i *v1alpha1.Ingress, sections ...gatewayapi.SectionName) runtime.Object {
+	t.Helper()
+	ingress.InsertProbe(i)
+	ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
+	rule := &i.Spec.Rules[0]
+	route, err := resources.MakeHTTPRoute(ctx, i, rule, *cfg.Gateway.Gateways[rule.Visibility].Gateway, nil, sections...)
+	if err != nil {
+		t.Fatal("MakeHTTPRoute() =", err)
+	}
+	return route
+}
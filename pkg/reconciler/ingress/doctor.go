@@ -0,0 +1,398 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/dump"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkinglisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+	networkcfg "knative.dev/networking/pkg/config"
+	"knative.dev/pkg/logging"
+)
+
+// Problem is something wrong with an object, as found by Diagnose.
+type Problem struct {
+	// Object is the kind and the namespace/name of the object.
+	Object  string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Object + ": " + p.Message
+}
+
+// Diagnose looks for what keeps the KIngresses of the class of the context
+// from working in a dump of a cluster, by the same rules as the Reconciler.
+// The configuration comes from the context, as for the Reconciler. The
+// problems are ordered by object.
+func Diagnose(ctx context.Context, objs *dump.Objects) ([]Problem, error) {
+	d, err := newDoctor(ctx, objs)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := ingressClassFilterFunc(IngressClassFromContext(ctx))
+	for _, ing := range objs.Ingresses {
+		if filter(ing) {
+			if err := d.checkIngress(ing); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, gw := range objs.Gateways {
+		d.checkGateway(gw)
+	}
+	for _, grant := range objs.ReferenceGrants {
+		d.checkReferenceGrant(grant)
+	}
+	d.checkGatewayServices()
+
+	sort.SliceStable(d.problems, func(i, j int) bool {
+		return d.problems[i].Object < d.problems[j].Object
+	})
+	return d.problems, nil
+}
+
+type doctor struct {
+	ctx  context.Context
+	objs *dump.Objects
+
+	routes          cache.Indexer
+	ingressLister   networkinglisters.IngressLister
+	gatewayLister   gatewaylisters.GatewayLister
+	endpointsLister corev1listers.EndpointsLister
+	grants          map[types.NamespacedName]*gatewayv1alpha2.ReferenceGrant
+	conflicts       *conflictDetector
+
+	problems []Problem
+}
+
+func newDoctor(ctx context.Context, objs *dump.Objects) (*doctor, error) {
+	routes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{httpRouteHostnameIndex: indexHTTPRouteHostnames})
+	ingresses := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	gateways := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	endpoints := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, route := range objs.HTTPRoutes {
+		if err := routes.Add(route); err != nil {
+			return nil, err
+		}
+	}
+	for _, ing := range objs.Ingresses {
+		if err := ingresses.Add(ing); err != nil {
+			return nil, err
+		}
+	}
+	for _, gw := range objs.Gateways {
+		if err := gateways.Add(gw); err != nil {
+			return nil, err
+		}
+	}
+	for _, eps := range objs.Endpoints {
+		if err := endpoints.Add(eps); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &doctor{
+		ctx:             ctx,
+		objs:            objs,
+		routes:          routes,
+		ingressLister:   networkinglisters.NewIngressLister(ingresses),
+		gatewayLister:   gatewaylisters.NewGatewayLister(gateways),
+		endpointsLister: corev1listers.NewEndpointsLister(endpoints),
+		grants:          make(map[types.NamespacedName]*gatewayv1alpha2.ReferenceGrant, len(objs.ReferenceGrants)),
+	}
+	d.conflicts = newConflictDetector(routes, d.ingressLister, d.gatewayLister, func(types.NamespacedName) {})
+	for _, grant := range objs.ReferenceGrants {
+		d.grants[types.NamespacedName{Namespace: grant.Namespace, Name: grant.Name}] = grant
+	}
+	return d, nil
+}
+
+func (d *doctor) report(kind string, obj metav1.Object, format string, args ...interface{}) {
+	d.problems = append(d.problems, Problem{
+		Object:  fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName()),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *doctor) checkIngress(ing *v1alpha1.Ingress) error {
+	if ing.Status.ObservedGeneration != ing.Generation {
+		d.report("KIngress", ing, "generation %d isn't reconciled yet, the status is of generation %d",
+			ing.Generation, ing.Status.ObservedGeneration)
+	}
+	if c := ing.Status.GetCondition(v1alpha1.IngressConditionReady); c == nil {
+		d.report("KIngress", ing, "it has no Ready condition")
+	} else if !c.IsTrue() {
+		d.report("KIngress", ing, "it isn't ready: %s: %s", c.Reason, c.Message)
+	}
+
+	gateways, err := gatewaysFor(d.ctx, ing)
+	if err != nil {
+		d.report("KIngress", ing, "%v", err)
+		return nil
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		name := resources.LongestHost(rule.Hosts)
+		obj, ok, err := d.routes.GetByKey(ing.Namespace + "/" + name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			d.report("KIngress", ing, "its HTTPRoute %s is missing", name)
+			continue
+		}
+		route := obj.(*gatewayapi.HTTPRoute)
+		if !metav1.IsControlledBy(route, ing) {
+			d.report("KIngress", ing, "its HTTPRoute %s is controlled by something else", name)
+			continue
+		}
+		d.checkHTTPRoute(route)
+	}
+
+	pools := make(map[v1alpha1.IngressVisibility][]types.NamespacedName, len(gateways))
+	for visibility, gw := range gateways {
+		gw := gw
+		pools[visibility] = gw.GatewayPool()
+	}
+	if conflict, err := d.conflicts.find(ing, pools); err != nil {
+		return err
+	} else if conflict != nil {
+		d.report("KIngress", ing, "it loses a hostname conflict: %s", conflict.message())
+	}
+
+	d.checkTLS(ing, gateways[v1alpha1.IngressVisibilityExternalIP])
+	return nil
+}
+
+// checkHTTPRoute reports why the HTTPRoute isn't accepted by its Gateways,
+// as far as they say.
+func (d *doctor) checkHTTPRoute(route *gatewayapi.HTTPRoute) {
+	if isHTTPRouteReady(route) {
+		return
+	}
+	if len(route.Status.Parents) == 0 {
+		d.report("HTTPRoute", route, "no Gateway reports on it, is the controller of the GatewayClass running?")
+		return
+	}
+	for _, parent := range route.Status.Parents {
+		if isGatewayAdmitted(parent) {
+			continue
+		}
+		gwName, _ := parentGateway(parent.ParentRef, route.Namespace)
+		found := false
+		for _, c := range parent.Conditions {
+			if c.Status != metav1.ConditionTrue && (c.Type == string(gatewayapi.RouteConditionAccepted) ||
+				c.Type == string(gatewayapi.RouteConditionResolvedRefs)) {
+				found = true
+				d.report("HTTPRoute", route, "Gateway %s reports it %s %s: %s: %s", gwName, c.Type, c.Status, c.Reason, c.Message)
+			}
+		}
+		if !found {
+			d.report("HTTPRoute", route, "Gateway %s hasn't accepted it yet", gwName)
+		}
+	}
+}
+
+// checkTLS reports the TLS listeners and ReferenceGrants missing for the
+// Ingress.
+func (d *doctor) checkTLS(ing *v1alpha1.Ingress, external config.GatewayConfig) {
+	defaults := makeDefaultListeners(external.DefaultListeners)
+
+	for _, tls := range ing.Spec.TLS {
+		tls := tls
+		if _, ok := matchDefaultListeners(defaults, tls.Hosts); ok {
+			continue
+		}
+
+		var holder *types.NamespacedName
+		for _, host := range tls.Hosts {
+			listenerName := tlsListenerName(ing, host)
+			found := false
+			for _, gwName := range external.GatewayPool() {
+				gwName := gwName
+				gw, err := d.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+				if err != nil {
+					continue
+				}
+				for _, l := range gw.Spec.Listeners {
+					if l.Name == listenerName {
+						holder, found = &gwName, true
+					}
+				}
+			}
+			if !found {
+				d.report("KIngress", ing, "no Gateway of %v has its listener %s for %s", external.GatewayPool(), listenerName, host)
+			}
+		}
+		if holder == nil {
+			continue
+		}
+
+		want := makeTLSReferenceGrant(d.ctx, ing, &tls, *holder)
+		grant, ok := d.grants[types.NamespacedName{Namespace: want.Namespace, Name: want.Name}]
+		switch {
+		case !ok:
+			d.report("KIngress", ing, "the ReferenceGrant %s/%s letting Gateway %s use Secret %s/%s is missing",
+				want.Namespace, want.Name, holder, tls.SecretNamespace, tls.SecretName)
+		case !metav1.IsControlledBy(grant, ing):
+			d.report("ReferenceGrant", grant, "it isn't controlled by KIngress %s/%s, which needs it", ing.Namespace, ing.Name)
+		case !equality.Semantic.DeepEqual(grant.Spec, want.Spec):
+			d.report("ReferenceGrant", grant, "it doesn't let Gateway %s use Secret %s/%s", holder, tls.SecretNamespace, tls.SecretName)
+		}
+	}
+}
+
+// checkGateway reports the listeners of the Gateway with problems, and ours
+// that nothing needs.
+func (d *doctor) checkGateway(gw *gatewayapi.Gateway) {
+	// The conditions of listeners with a problem, and the status they have
+	// then.
+	bad := map[string]metav1.ConditionStatus{
+		string(gatewayapi.ListenerConditionResolvedRefs): metav1.ConditionFalse,
+		string(gatewayapi.ListenerConditionReady):        metav1.ConditionFalse,
+		string(gatewayapi.ListenerConditionConflicted):   metav1.ConditionTrue,
+		string(gatewayapi.ListenerConditionDetached):     metav1.ConditionTrue,
+	}
+	for _, status := range gw.Status.Listeners {
+		for _, c := range status.Conditions {
+			if s, ok := bad[c.Type]; ok && c.Status == s {
+				d.report("Gateway", gw, "listener %s is %s %s: %s: %s", status.Name, c.Type, c.Status, c.Reason, c.Message)
+			}
+		}
+	}
+
+	uids := make(map[types.UID]struct{}, len(d.objs.Ingresses))
+	for _, ing := range d.objs.Ingresses {
+		uids[ing.UID] = struct{}{}
+	}
+	defaults := d.defaultListenerNames(types.NamespacedName{Namespace: gw.Namespace, Name: gw.Name})
+	for _, l := range gw.Spec.Listeners {
+		name := string(l.Name)
+		switch {
+		case strings.HasPrefix(name, defaultListenerPrefix):
+			if _, ok := defaults[l.Name]; !ok {
+				d.report("Gateway", gw, "default listener %s isn't configured for it anymore", name)
+			}
+		case strings.HasPrefix(name, listenerPrefix):
+			uid, _ := listenerOwner(l.Name)
+			if _, ok := uids[uid]; !ok {
+				d.report("Gateway", gw, "listener %s is of no KIngress", name)
+			}
+		}
+	}
+}
+
+// defaultListenerNames returns the names of the default listeners configured
+// for the Gateway.
+func (d *doctor) defaultListenerNames(gwName types.NamespacedName) map[gatewayapi.SectionName]struct{} {
+	names := make(map[gatewayapi.SectionName]struct{})
+	d.eachGatewayConfig(func(_ v1alpha1.IngressVisibility, gw *config.GatewayConfig) {
+		for _, pooled := range gw.GatewayPool() {
+			if pooled != gwName {
+				continue
+			}
+			for _, l := range makeDefaultListeners(gw.DefaultListeners) {
+				names[l.Name] = struct{}{}
+			}
+		}
+	})
+	return names
+}
+
+// checkReferenceGrant reports our ReferenceGrants whose KIngress is gone.
+func (d *doctor) checkReferenceGrant(grant *gatewayv1alpha2.ReferenceGrant) {
+	if grant.Labels[resources.ManagedByLabelKey] != resources.ManagedByLabelValue {
+		return
+	}
+	ref := metav1.GetControllerOf(grant)
+	if ref == nil {
+		d.report("ReferenceGrant", grant, "it has no controlling KIngress")
+		return
+	}
+	if _, err := d.ingressLister.Ingresses(grant.Namespace).Get(ref.Name); err != nil {
+		d.report("ReferenceGrant", grant, "its KIngress %s/%s is gone", grant.Namespace, ref.Name)
+	}
+}
+
+// checkGatewayServices reports the Services of the Gateways that have no
+// ready pods to probe, as the prober finds them.
+func (d *doctor) checkGatewayServices() {
+	lister := &gatewayPodTargetLister{logger: logging.FromContext(d.ctx), endpointsLister: d.endpointsLister}
+	seen := make(map[types.NamespacedName]struct{})
+	d.eachGatewayConfig(func(visibility v1alpha1.IngressVisibility, gw *config.GatewayConfig) {
+		if gw.Service == nil {
+			return
+		}
+		if _, ok := seen[*gw.Service]; ok {
+			return
+		}
+		seen[*gw.Service] = struct{}{}
+
+		rule := v1alpha1.IngressRule{Visibility: visibility}
+		if _, err := lister.getRuleProbes(d.ctx, rule, gw, v1alpha1.HTTPOptionEnabled, networkcfg.HTTPEnabled); err != nil {
+			d.problems = append(d.problems, Problem{
+				Object:  "Service " + gw.Service.String(),
+				Message: fmt.Sprintf("the Gateways of %s can't be probed: %v", visibility, err),
+			})
+		}
+	})
+}
+
+// eachGatewayConfig calls f with the configuration of every visibility, of
+// the default gateways and of the profiles, in a stable order.
+func (d *doctor) eachGatewayConfig(f func(v1alpha1.IngressVisibility, *config.GatewayConfig)) {
+	cfg := config.FromContext(d.ctx).Gateway
+	each := func(gateways map[v1alpha1.IngressVisibility]config.GatewayConfig) {
+		visibilities := make([]string, 0, len(gateways))
+		for visibility := range gateways {
+			visibilities = append(visibilities, string(visibility))
+		}
+		sort.Strings(visibilities)
+		for _, visibility := range visibilities {
+			gw := gateways[v1alpha1.IngressVisibility(visibility)]
+			f(v1alpha1.IngressVisibility(visibility), &gw)
+		}
+	}
+
+	each(cfg.Gateways)
+	profiles := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		each(cfg.Profiles[name])
+	}
+}
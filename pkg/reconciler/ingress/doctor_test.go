@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/dump"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestDiagnose(t *testing.T) {
+	const secretName = "name-WE-STICK-A-LONG-UID-HERE"
+	readyIngress := ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+		i.Generation = 1
+		i.Status.InitializeConditions()
+		i.Status.MarkNetworkConfigured()
+		i.Status.MarkLoadBalancerReady(nil, nil)
+		i.Status.ObservedGeneration = 1
+	})
+	acceptedRoute := func(status metav1.ConditionStatus, reason string) *gatewayapi.HTTPRoute {
+		route := httpRoute(t, readyIngress).(*gatewayapi.HTTPRoute)
+		route.Status.Parents = []gatewayapi.RouteParentStatus{{
+			ParentRef: route.Spec.ParentRefs[0],
+			Conditions: []metav1.Condition{{
+				Type:    string(gatewayapi.RouteConditionAccepted),
+				Status:  status,
+				Reason:  reason,
+				Message: "because",
+			}},
+		}}
+		return route
+	}
+	endpoints := func(name string, ips ...string) *corev1.Endpoints {
+		subset := corev1.EndpointSubset{Ports: []corev1.EndpointPort{{Name: "http2", Port: 8080}}}
+		for _, ip := range ips {
+			subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip})
+		}
+		return &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name},
+			Subsets:    []corev1.EndpointSubset{subset},
+		}
+	}
+
+	tests := []struct {
+		name string
+		objs dump.Objects
+		want []Problem
+	}{{
+		name: "healthy",
+		objs: dump.Objects{
+			Ingresses:       []*v1alpha1.Ingress{readyIngress},
+			HTTPRoutes:      []*gatewayapi.HTTPRoute{acceptedRoute(metav1.ConditionTrue, "Accepted")},
+			Gateways:        []*gatewayapi.Gateway{gw(defaultListener, tlsListener("secure.example.com", "ns", secretName))},
+			ReferenceGrants: []*gatewayv1alpha2.ReferenceGrant{rp(secret(secretName, "ns"))},
+			Endpoints:       []*corev1.Endpoints{endpoints(publicName, "1.2.3.4"), endpoints(privateName, "1.2.3.5")},
+		},
+	}, {
+		name: "broken",
+		objs: dump.Objects{
+			Ingresses:  []*v1alpha1.Ingress{readyIngress},
+			HTTPRoutes: []*gatewayapi.HTTPRoute{acceptedRoute(metav1.ConditionFalse, "NotAllowedByListeners")},
+			Gateways: []*gatewayapi.Gateway{gw(defaultListener, tlsListener("secure.example.com", "ns", secretName), func(g *gatewayapi.Gateway) {
+				g.Spec.Listeners = append(g.Spec.Listeners, gatewayapi.Listener{Name: "kni-gone", Port: 443})
+			})},
+			Endpoints: []*corev1.Endpoints{endpoints(publicName)},
+		},
+		want: []Problem{{
+			Object:  "Gateway istio-system/istio-gateway",
+			Message: "listener kni-gone is of no KIngress",
+		}, {
+			Object:  "HTTPRoute ns/example.com",
+			Message: "Gateway istio-system/istio-gateway reports it Accepted False: NotAllowedByListeners: because",
+		}, {
+			Object:  "KIngress ns/name",
+			Message: "the ReferenceGrant ns/name-WE-STICK-A-LONG-UID-HERE-istio-system letting Gateway istio-system/istio-gateway use Secret ns/name-WE-STICK-A-LONG-UID-HERE is missing",
+		}, {
+			Object:  "Service istio-system/istio-gateway",
+			Message: "the Gateways of ExternalIP can't be probed: no gateway pods available",
+		}, {
+			Object:  "Service istio-system/knative-local-gateway",
+			Message: `the Gateways of ClusterLocal can't be probed: failed to get endpoints: endpoints "knative-local-gateway" not found`,
+		}},
+	}, {
+		name: "orphaned ReferenceGrant",
+		objs: dump.Objects{
+			ReferenceGrants: []*gatewayv1alpha2.ReferenceGrant{rp(secret(secretName, "ns"))},
+			Endpoints:       []*corev1.Endpoints{endpoints(publicName, "1.2.3.4"), endpoints(privateName, "1.2.3.5")},
+		},
+		want: []Problem{{
+			Object:  "ReferenceGrant ns/name-WE-STICK-A-LONG-UID-HERE-istio-system",
+			Message: "its KIngress ns/name is gone",
+		}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := config.ToContext(context.Background(), defaultConfig)
+			got, err := Diagnose(ctx, &test.objs)
+			if err != nil {
+				t.Fatal("Diagnose() =", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error("Diagnose() (-want, +got):", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// unknownGatewayProfileReason is the reason set on Ingresses that choose a
+// gateway profile config-gateway doesn't have.
+const unknownGatewayProfileReason = "UnknownGatewayProfile"
+
+// gatewaysFor returns the gateways of the profile chosen by the Ingress
+// through its gateway-profile annotation, or the default ones.
+func gatewaysFor(ctx context.Context, ing *v1alpha1.Ingress) (map[v1alpha1.IngressVisibility]config.GatewayConfig, error) {
+	name := ing.Annotations[config.GatewayProfileAnnotationKey]
+	gateways, ok := config.FromContext(ctx).Gateway.ForProfile(name)
+	if !ok {
+		return nil, fmt.Errorf("gateway profile %q does not exist in %s", name, config.GatewayConfigMapName(ctx))
+	}
+	return gateways, nil
+}
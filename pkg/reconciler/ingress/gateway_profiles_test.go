@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/ingress"
+	"knative.dev/pkg/network"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+var (
+	partnerGateway = types.NamespacedName{Namespace: "partner", Name: "gateway"}
+	partnerService = types.NamespacedName{Namespace: "partner", Name: "ingressgateway"}
+)
+
+func TestReconcileGatewayProfiles(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	cfg.Gateway.Profiles = map[string]map[v1alpha1.IngressVisibility]config.GatewayConfig{
+		"partner": {
+			v1alpha1.IngressVisibilityExternalIP: {
+				Gateway: &partnerGateway,
+				Service: &partnerService,
+			},
+			v1alpha1.IngressVisibilityClusterLocal: cfg.Gateway.Gateways[v1alpha1.IngressVisibilityClusterLocal],
+		},
+	}
+
+	partnerReady := func(i *v1alpha1.Ingress) {
+		i.Status.InitializeConditions()
+		i.Status.MarkLoadBalancerReady(
+			[]v1alpha1.LoadBalancerIngressStatus{{
+				DomainInternal: network.GetServiceHostname(partnerService.Name, partnerService.Namespace),
+			}},
+			[]v1alpha1.LoadBalancerIngressStatus{{
+				DomainInternal: privateSvc,
+			}})
+	}
+
+	table := TableTest{{
+		Name: "route attaches to the Gateway of the profile",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withProfile("partner")),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			profileRoute(t, ing(withBasicSpec, withGatewayAPIClass, withProfile("partner")), partnerGateway),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withProfile("partner"), partnerReady),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "listeners left on the Gateway of the default profile",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withProfile("partner"), withFinalizer),
+			gw(defaultListener, tlsListener("secure.example.com", "ns", "secret")),
+			profileRoute(t, ing(withBasicSpec, withGatewayAPIClass, withProfile("partner")), partnerGateway),
+		}, servicesAndEndpoints...),
+		WantPatches: []clientgotesting.PatchActionImpl{
+			gwPatch(t, listenersPatch{
+				{Operation: "test", Path: "/spec/listeners/1/name", Value: secureListenerName("secure.example.com")},
+				{Operation: "remove", Path: "/spec/listeners/1"},
+			}),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withProfile("partner"), withFinalizer, partnerReady),
+		}},
+	}, {
+		Name: "unknown profile",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withProfile("nope"), withFinalizer),
+		}, servicesAndEndpoints...),
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withProfile("nope"), withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(unknownGatewayProfileReason, `gateway profile "nope" does not exist in config-gateway`)
+			}),
+		}},
+	}}
+
+	table.Test(t, reconcilerFactory(cfg))
+}
+
+func withProfile(name string) IngressOption {
+	return withAnnotation(map[string]string{
+		config.GatewayProfileAnnotationKey: name,
+	})
+}
+
+// profileRoute returns the HTTPRoute for the first rule of the Ingress,
+// attached to the given Gateway.
+func profileRoute(t *testing.T, i *v1alpha1.Ingress, gateway types.NamespacedName) runtime.Object {
+	t.Helper()
+	ingress.InsertProbe(i)
+	route, err := resources.MakeHTTPRoute(context.Background(), i, &i.Spec.Rules[0], gateway, nil)
+	if err != nil {
+		t.Fatal("MakeHTTPRoute() =", err)
+	}
+	return route
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/client/injection/informers/factory"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkinglisters "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// hostnameConflictReason is the reason set on Ingresses that lose a
+	// hostname to another route or listener.
+	hostnameConflictReason = "HostnameConflict"
+
+	// httpRouteHostnameIndex is the name of the HTTPRoute informer index
+	// keyed by hostname.
+	httpRouteHostnameIndex = "hostname"
+)
+
+func init() {
+	injection.Default.RegisterInformer(withHostnameRouteInformer)
+}
+
+type hostnameRouteInformerKey struct{}
+
+// withHostnameRouteInformer sets up the informer the conflictDetector looks
+// up the HTTPRoutes of a hostname with. Unlike the one of
+// withHTTPRouteInformer, it isn't limited to the HTTPRoutes we created, as
+// those created by others claim hostnames just the same. Only what conflicts
+// are decided by is kept of them.
+func withHostnameRouteInformer(ctx context.Context) (context.Context, controller.Informer) {
+	f := factory.Get(ctx)
+	inf := f.Gateway().V1beta1().HTTPRoutes().Informer()
+	transform := trimHTTPRoute
+	if apiVersionsFromContext(ctx).HTTPRoute == resources.V1alpha2 {
+		inf = f.Gateway().V1alpha2().HTTPRoutes().Informer()
+		transform = func(obj interface{}) (interface{}, error) {
+			route, err := httpRouteFromV1alpha2(obj)
+			if err != nil {
+				return nil, err
+			}
+			return trimHTTPRoute(route)
+		}
+	}
+	if err := inf.SetTransform(transform); err != nil {
+		logging.FromContext(ctx).Panicw("Failed to set the HTTPRoute transform", zap.Error(err))
+	}
+	if err := inf.AddIndexers(cache.Indexers{httpRouteHostnameIndex: indexHTTPRouteHostnames}); err != nil {
+		logging.FromContext(ctx).Panicw("Failed to add the HTTPRoute hostname index", zap.Error(err))
+	}
+	return context.WithValue(ctx, hostnameRouteInformerKey{}, inf), inf
+}
+
+func getHostnameRouteInformer(ctx context.Context) cache.SharedIndexInformer {
+	untyped := ctx.Value(hostnameRouteInformerKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the hostname HTTPRoute informer from context.")
+	}
+	return untyped.(cache.SharedIndexInformer)
+}
+
+// trimHTTPRoute drops everything of the HTTPRoute but its hostnames, the
+// Gateways it attaches to, the paths it matches and who owns it.
+func trimHTTPRoute(obj interface{}) (interface{}, error) {
+	route, ok := obj.(*gatewayapi.HTTPRoute)
+	if !ok {
+		return obj, nil
+	}
+	trimmed := &gatewayapi.HTTPRoute{
+		TypeMeta: route.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         route.Namespace,
+			Name:              route.Name,
+			UID:               route.UID,
+			ResourceVersion:   route.ResourceVersion,
+			CreationTimestamp: route.CreationTimestamp,
+			OwnerReferences:   route.OwnerReferences,
+		},
+		Spec: gatewayapi.HTTPRouteSpec{
+			CommonRouteSpec: route.Spec.CommonRouteSpec,
+			Hostnames:       route.Spec.Hostnames,
+		},
+	}
+	for _, rule := range route.Spec.Rules {
+		trimmed.Spec.Rules = append(trimmed.Spec.Rules, gatewayapi.HTTPRouteRule{Matches: rule.Matches})
+	}
+	return trimmed, nil
+}
+
+// indexHTTPRouteHostnames indexes HTTPRoutes by the hostnames they serve.
+func indexHTTPRouteHostnames(obj interface{}) ([]string, error) {
+	route, ok := obj.(*gatewayapi.HTTPRoute)
+	if !ok {
+		return nil, nil
+	}
+	hosts := make([]string, 0, len(route.Spec.Hostnames))
+	for _, h := range route.Spec.Hostnames {
+		hosts = append(hosts, string(h))
+	}
+	return hosts, nil
+}
+
+// hostnameConflict describes a hostname an Ingress lost to someone else.
+type hostnameConflict struct {
+	host  string
+	owner string
+}
+
+func (hc *hostnameConflict) message() string {
+	return fmt.Sprintf("Hostname %q is already in use by %s", hc.host, hc.owner)
+}
+
+// conflictDetector finds the hostnames an Ingress shares with other
+// HTTPRoutes and TLS listeners on its Gateways. Conflicts are decided by
+// age: the oldest claim wins, so the outcome doesn't depend on the order
+// things get reconciled in. HTTPRoutes created by others count as well.
+type conflictDetector struct {
+	routeIndexer  cache.Indexer
+	ingressLister networkinglisters.IngressLister
+	gatewayLister gatewaylisters.GatewayLister
+
+	// enqueueIngress is called for the Ingresses that lost a conflict when
+	// the claim they lost to may have gone away.
+	enqueueIngress func(types.NamespacedName)
+
+	mu     sync.Mutex
+	losers map[types.NamespacedName]struct{}
+}
+
+func newConflictDetector(routeIndexer cache.Indexer, ingressLister networkinglisters.IngressLister,
+	gatewayLister gatewaylisters.GatewayLister, enqueueIngress func(types.NamespacedName)) *conflictDetector {
+	return &conflictDetector{
+		routeIndexer:   routeIndexer,
+		ingressLister:  ingressLister,
+		gatewayLister:  gatewayLister,
+		enqueueIngress: enqueueIngress,
+		losers:         make(map[types.NamespacedName]struct{}),
+	}
+}
+
+// find returns the first conflict the Ingress loses, if any. The pools hold
+// the Gateways the routes and listeners of each visibility attach to.
+func (d *conflictDetector) find(ing *v1alpha1.Ingress, pools map[v1alpha1.IngressVisibility][]types.NamespacedName) (*hostnameConflict, error) {
+	for _, rule := range ing.Spec.Rules {
+		paths := ingressRulePaths(&rule)
+		for _, host := range rule.Hosts {
+			objs, err := d.routeIndexer.ByIndex(httpRouteHostnameIndex, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range objs {
+				route := obj.(*gatewayapi.HTTPRoute)
+				if metav1.IsControlledBy(route, ing) || !attachedTo(route, pools[rule.Visibility]) || !sharesPath(route, paths) {
+					continue
+				}
+				owner, claimant := d.routeClaimant(route)
+				if olderThan(claimant, ing) {
+					return &hostnameConflict{host: host, owner: owner}, nil
+				}
+			}
+		}
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			for _, gwName := range pools[v1alpha1.IngressVisibilityExternalIP] {
+				gw, err := d.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+				if apierrs.IsNotFound(err) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				for _, l := range gw.Spec.Listeners {
+					if l.Port != 443 || l.Hostname == nil || string(*l.Hostname) != host {
+						continue
+					}
+					if uid, ok := listenerOwner(l.Name); ok && uid == ing.UID {
+						// The listener of this Ingress.
+						continue
+					}
+					if strings.HasPrefix(string(l.Name), defaultListenerPrefix) {
+						// Default listeners are shared by all Ingresses.
+						continue
+					}
+					owner, claimant, err := d.listenerClaimant(gw, l.Name)
+					if err != nil {
+						return nil, err
+					}
+					// Listeners we didn't write are part of the Gateway's
+					// own configuration and always win.
+					if claimant == nil || olderThan(claimant, ing) {
+						return &hostnameConflict{host: host, owner: owner}, nil
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// routeClaimant returns who claims the hostnames of the HTTPRoute: the
+// Ingress controlling it if there is one, or else the route itself.
+func (d *conflictDetector) routeClaimant(route *gatewayapi.HTTPRoute) (string, metav1.Object) {
+	if ref := metav1.GetControllerOf(route); ref != nil && ref.Kind == "Ingress" &&
+		ref.APIVersion == v1alpha1.SchemeGroupVersion.String() {
+		if owner, err := d.ingressLister.Ingresses(route.Namespace).Get(ref.Name); err == nil {
+			return fmt.Sprintf("Ingress %s/%s", owner.Namespace, owner.Name), owner
+		}
+	}
+	return fmt.Sprintf("HTTPRoute %s/%s", route.Namespace, route.Name), route
+}
+
+// listenerClaimant returns who claims the hostname of the named listener: the
+// Ingress it was written for, or nil if it isn't one of ours.
+func (d *conflictDetector) listenerClaimant(gw *gatewayapi.Gateway, name gatewayapi.SectionName) (string, metav1.Object, error) {
+	owner := fmt.Sprintf("listener %q of Gateway %s/%s", name, gw.Namespace, gw.Name)
+	uid, ok := listenerOwner(name)
+	if !ok {
+		return owner, nil, nil
+	}
+
+	ings, err := d.ingressLister.List(labels.Everything())
+	if err != nil {
+		return "", nil, err
+	}
+	for _, ing := range ings {
+		if ing.UID == uid {
+			return fmt.Sprintf("Ingress %s/%s", ing.Namespace, ing.Name), ing, nil
+		}
+	}
+	// The Ingress is gone, and its listener will be soon.
+	return owner, nil, nil
+}
+
+// markLoser remembers that the Ingress lost a conflict.
+func (d *conflictDetector) markLoser(ing types.NamespacedName) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.losers[ing] = struct{}{}
+}
+
+// forget drops the Ingress from the losers.
+func (d *conflictDetector) forget(ing types.NamespacedName) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.losers, ing)
+}
+
+// retryLosers enqueues the Ingresses that lost a conflict, so they can take
+// over hostnames that have been freed up. It can be used as event handler.
+func (d *conflictDetector) retryLosers(interface{}) {
+	d.mu.Lock()
+	losers := make([]types.NamespacedName, 0, len(d.losers))
+	for ing := range d.losers {
+		losers = append(losers, ing)
+	}
+	d.mu.Unlock()
+
+	for _, ing := range losers {
+		d.enqueueIngress(ing)
+	}
+}
+
+// olderThan returns whether a claimed its hostnames before b. Ties are broken
+// by namespace and name.
+func olderThan(a, b metav1.Object) bool {
+	ta, tb := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !ta.Equal(&tb) {
+		return ta.Before(&tb)
+	}
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+	return a.GetName() < b.GetName()
+}
+
+// attachedTo returns whether the HTTPRoute attaches to any of the Gateways.
+func attachedTo(route *gatewayapi.HTTPRoute, gateways []types.NamespacedName) bool {
+	for _, ref := range route.Spec.ParentRefs {
+		parent, ok := parentGateway(ref, route.Namespace)
+		if !ok {
+			continue
+		}
+		for _, gw := range gateways {
+			if gw == parent {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sharesPath returns whether the HTTPRoute matches any of the given paths.
+func sharesPath(route *gatewayapi.HTTPRoute, paths map[string]struct{}) bool {
+	for _, rule := range route.Spec.Rules {
+		if len(rule.Matches) == 0 {
+			// No matches mean a prefix match on "/".
+			if _, ok := paths["/"]; ok {
+				return true
+			}
+		}
+		for _, match := range rule.Matches {
+			path := "/"
+			if match.Path != nil && match.Path.Value != nil {
+				path = *match.Path.Value
+			}
+			if _, ok := paths[path]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ingressRulePaths returns the paths the rule routes, as MakeHTTPRoute
+// matches them.
+func ingressRulePaths(rule *v1alpha1.IngressRule) map[string]struct{} {
+	paths := make(map[string]struct{})
+	if rule.HTTP == nil {
+		return paths
+	}
+	for _, path := range rule.HTTP.Paths {
+		if path.Path == "" {
+			paths["/"] = struct{}{}
+		} else {
+			paths[path.Path] = struct{}{}
+		}
+	}
+	return paths
+}
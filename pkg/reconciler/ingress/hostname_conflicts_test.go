@@ -0,0 +1,298 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	fakegwapiclientset "knative.dev/net-gateway-api/pkg/client/injection/client/fake"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileHostnameConflicts(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	older, newer := now.Add(-time.Hour), now.Add(time.Hour)
+
+	ours := func(opts ...IngressOption) *v1alpha1.Ingress {
+		return ing(append([]IngressOption{withBasicSpec, withGatewayAPIClass, withUID("ours"), createdAt(now)}, opts...)...)
+	}
+	other := ing(withBasicSpec, withGatewayAPIClass, withUID("other"), createdAt(older), func(i *v1alpha1.Ingress) {
+		i.Namespace = "other-ns"
+		i.Name = "other"
+	})
+
+	table := TableTest{{
+		Name: "older Ingress holds the hostname",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(),
+			other,
+			httpRoute(t, other),
+		}, servicesAndEndpoints...),
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason, `Hostname "example.com" is already in use by Ingress other-ns/other`)
+			}),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}, {
+		Name: "losing Ingress removes its HTTPRoute",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(withFinalizer),
+			httpRoute(t, ours()),
+			other,
+			httpRoute(t, other),
+		}, servicesAndEndpoints...),
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason, `Hostname "example.com" is already in use by Ingress other-ns/other`)
+			}),
+		}},
+	}, {
+		Name: "newer HTTPRoute loses the hostname",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(),
+			userRoute("/", newer),
+		}, servicesAndEndpoints...),
+		WantCreates:       []runtime.Object{httpRoute(t, ours())},
+		WantPatches:       []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{Object: ours(readyStatus)}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "older HTTPRoute of a user holds the hostname",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(),
+			userRoute("/", older),
+		}, servicesAndEndpoints...),
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason, `Hostname "example.com" is already in use by HTTPRoute user-ns/user`)
+			}),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}, {
+		Name: "older HTTPRoute on another path",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(),
+			userRoute("/api", older),
+		}, servicesAndEndpoints...),
+		WantCreates:       []runtime.Object{httpRoute(t, ours())},
+		WantPatches:       []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{Object: ours(readyStatus)}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "older HTTPRoute on another Gateway",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(),
+			userRoute("/", older, func(r *gatewayapi.HTTPRoute) {
+				r.Spec.ParentRefs[0].Name = "elsewhere"
+			}),
+		}, servicesAndEndpoints...),
+		WantCreates:       []runtime.Object{httpRoute(t, ours())},
+		WantPatches:       []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{Object: ours(readyStatus)}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "static listener holds the TLS hostname",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(withTLS("secret")),
+			gw(defaultListener, func(g *gatewayapi.Gateway) {
+				hostname := gatewayapi.Hostname("secure.example.com")
+				g.Spec.Listeners = append(g.Spec.Listeners, gatewayapi.Listener{
+					Name:     "static",
+					Hostname: &hostname,
+					Port:     443,
+					Protocol: gatewayapi.HTTPSProtocolType,
+				})
+			}),
+		}, servicesAndEndpoints...),
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(withTLS("secret"), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason,
+					`Hostname "secure.example.com" is already in use by listener "static" of Gateway istio-system/istio-gateway`)
+			}),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}}
+
+	table.Test(t, reconcilerFactory(defaultConfig))
+
+	table = TableTest{{
+		Name: "losing Ingress doesn't wait for its listeners to go",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(withFinalizer, withTLS("secret")),
+			httpRoute(t, ours(withTLS("secret"))),
+			gw(defaultListener, tlsListener("secure.example.com", "ns", "secret"), func(g *gatewayapi.Gateway) {
+				g.Spec.Listeners[1].Name = tlsListenerName(ours(), "secure.example.com")
+			}),
+			other,
+			httpRoute(t, other),
+		}, servicesAndEndpoints...),
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(withFinalizer, withTLS("secret"), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason, `Hostname "example.com" is already in use by Ingress other-ns/other`)
+			}),
+		}},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, _ configmap.Watcher, tr *TableRow) controller.Reconciler {
+		createGateways(ctx, tr)
+		r := newTestReconciler(ctx, listers)
+		// Nothing processes the Gateway queue, so the listeners stay put.
+		r.listeners = newListenerAggregator(logging.FromContext(ctx), fakegwapiclientset.Get(ctx),
+			listers.GetGatewayLister(), func(types.NamespacedName) {})
+		t.Cleanup(r.listeners.queue.ShutDown)
+		return newTestIngressReconciler(ctx, listers, r, defaultConfig)
+	}))
+}
+
+func TestOlderThan(t *testing.T) {
+	now := time.Now()
+	a := ing(createdAt(now), func(i *v1alpha1.Ingress) { i.Name = "a" })
+	b := ing(createdAt(now), func(i *v1alpha1.Ingress) { i.Name = "b" })
+	c := ing(createdAt(now.Add(-time.Minute)), func(i *v1alpha1.Ingress) { i.Name = "c" })
+
+	if !olderThan(c, a) || olderThan(a, c) {
+		t.Error("Expected the earlier creation timestamp to win")
+	}
+	if !olderThan(a, b) || olderThan(b, a) {
+		t.Error("Expected the name to break ties")
+	}
+}
+
+func userRoute(path string, created time.Time, opts ...HTTPRouteOption) *gatewayapi.HTTPRoute {
+	r := &gatewayapi.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "user",
+			Namespace:         "user-ns",
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: gatewayapi.HTTPRouteSpec{
+			CommonRouteSpec: gatewayapi.CommonRouteSpec{
+				ParentRefs: []gatewayapi.ParentReference{{
+					Namespace: (*gatewayapi.Namespace)(pointer.String(testNamespace)),
+					Name:      gatewayapi.ObjectName(publicName),
+				}},
+			},
+			Hostnames: []gatewayapi.Hostname{"example.com"},
+			Rules: []gatewayapi.HTTPRouteRule{{
+				Matches: []gatewayapi.HTTPRouteMatch{{
+					Path: &gatewayapi.HTTPPathMatch{Value: pointer.String(path)},
+				}},
+			}},
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func withUID(uid string) IngressOption {
+	return func(i *v1alpha1.Ingress) {
+		i.UID = types.UID(uid)
+	}
+}
+
+func createdAt(t time.Time) IngressOption {
+	return func(i *v1alpha1.Ingress) {
+		i.CreationTimestamp = metav1.NewTime(t)
+	}
+}
+
+func readyStatus(i *v1alpha1.Ingress) {
+	i.Status.InitializeConditions()
+	i.Status.MarkLoadBalancerReady(
+		[]v1alpha1.LoadBalancerIngressStatus{{
+			DomainInternal: publicSvc,
+		}},
+		[]v1alpha1.LoadBalancerIngressStatus{{
+			DomainInternal: privateSvc,
+		}})
+}
+
+func finalizerPatch() clientgotesting.PatchActionImpl {
+	return clientgotesting.PatchActionImpl{
+		ActionImpl: clientgotesting.ActionImpl{
+			Namespace: "ns",
+		},
+		Name:  "name",
+		Patch: []byte(`{"metadata":{"finalizers":["ingresses.networking.internal.knative.dev"],"resourceVersion":""}}`),
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkcfg "knative.dev/networking/pkg/config"
+)
+
+// httpDisabledReason is the reason set on Ingresses with plain HTTP disabled
+// that have hosts nothing serves over HTTPS.
+const httpDisabledReason = "HTTPDisabled"
+
+// httpProtocol returns how the external hosts of the Ingress treat plain
+// HTTP: as set by its annotation, or else by config-network.
+func httpProtocol(ctx context.Context, ing *v1alpha1.Ingress) networkcfg.HTTPProtocol {
+	switch p := networkcfg.HTTPProtocol(strings.ToLower(networking.HTTPProtocolAnnotation.Value(ing.Annotations))); p {
+	case networkcfg.HTTPEnabled, networkcfg.HTTPDisabled, networkcfg.HTTPRedirected:
+		return p
+	}
+	if cfg := config.FromContext(ctx); cfg != nil && cfg.Network != nil && cfg.Network.HTTPProtocol != "" {
+		return cfg.Network.HTTPProtocol
+	}
+	return networkcfg.HTTPEnabled
+}
+
+// httpsSections returns the names of the HTTPS listeners serving the hosts of
+// the rule: the default listeners, and the listener of the Ingress for the
+// hosts of its TLS sections. If a host isn't served by any, it is returned
+// instead.
+func httpsSections(ing *v1alpha1.Ingress, rule *v1alpha1.IngressRule, defaults []*gatewayapi.Listener) ([]gatewayapi.SectionName, string) {
+	tlsHosts := make(map[string]struct{})
+	for _, tls := range ing.Spec.TLS {
+		if _, ok := matchDefaultListeners(defaults, tls.Hosts); ok {
+			// These get no listener of their own.
+			continue
+		}
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = struct{}{}
+		}
+	}
+
+	var sections []gatewayapi.SectionName
+	seen := make(map[gatewayapi.SectionName]struct{})
+	add := func(name gatewayapi.SectionName) {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			sections = append(sections, name)
+		}
+	}
+	for _, host := range rule.Hosts {
+		if shared, ok := matchDefaultListeners(defaults, []string{host}); ok {
+			add(shared[0].Name)
+		} else if _, ok := tlsHosts[host]; ok {
+			add(tlsListenerName(ing, host))
+		} else {
+			return nil, host
+		}
+	}
+	return sections, ""
+}
+
+// disableHTTP tears down the external HTTPRoutes of an Ingress with plain
+// HTTP disabled, which would have to serve plain HTTP as the host has no TLS
+// configuration, and reports it.
+func (c *Reconciler) disableHTTP(ctx context.Context, ing *v1alpha1.Ingress, host string) error {
+	routes, err := c.ownedHTTPRoutes(ctx, ing)
+	if err != nil {
+		return err
+	}
+	external := make([]*gatewayapi.HTTPRoute, 0, len(routes))
+	for _, route := range routes {
+		if route.Labels[networking.VisibilityLabelKey] == "" {
+			external = append(external, route)
+		}
+	}
+	if err := c.deleteHTTPRoutes(ctx, ing, external); err != nil {
+		return err
+	}
+	markHTTPDisabled(ing, host)
+	return nil
+}
+
+// markHTTPDisabled reports that the host would have to serve plain HTTP.
+func markHTTPDisabled(ing *v1alpha1.Ingress, host string) {
+	ing.Status.MarkIngressNotReady(httpDisabledReason,
+		fmt.Sprintf("HTTP is disabled, but host %q has no TLS configuration", host))
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkcfg "knative.dev/networking/pkg/config"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileHTTPDisabled(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+
+	cfg := defaultConfig.DeepCopy()
+	cfg.Network.HTTPProtocol = networkcfg.HTTPDisabled
+	external := cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP]
+	external.DefaultListeners = []config.DefaultListener{{
+		Hostname:    "*.example.org",
+		Certificate: types.NamespacedName{Namespace: testNamespace, Name: "wildcard-example-org"},
+	}}
+	cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP] = external
+
+	withHost := func(host string) IngressOption {
+		return func(i *v1alpha1.Ingress) {
+			i.Spec.Rules[0].Hosts = []string{host}
+		}
+	}
+	withWildcardListener := func(g *gatewayapi.Gateway) {
+		g.Spec.Listeners = append(g.Spec.Listeners, *makeDefaultListeners(external.DefaultListeners)[0])
+	}
+
+	table := TableTest{{
+		Name: "host without TLS",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withFinalizer),
+			gw(defaultListener, withWildcardListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		}, servicesAndEndpoints...),
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(httpDisabledReason, `HTTP is disabled, but host "example.com" has no TLS configuration`)
+			}),
+		}},
+	}, {
+		Name: "host served by a default listener",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withHost("www.example.org")),
+			gw(defaultListener, withWildcardListener),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost("www.example.org")), defaultListenerName("*.example.org")),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{finalizerPatch()},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withHost("www.example.org"), readyStatus),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "www.example.org"`),
+		},
+	}, {
+		Name: "host served by the listener of the Ingress",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withHost("secure.example.com"), withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			gw(defaultListener, withWildcardListener, tlsListener("secure.example.com", nsName, secretName)),
+			rp(secret(secretName, nsName)),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost("secure.example.com"), withTLS(secretName)), secureListenerName("secure.example.com")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withHost("secure.example.com"), withTLS(secretName), withFinalizer, readyStatus),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "secure.example.com"`),
+		},
+	}, {
+		Name: "annotation enables HTTP",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withFinalizer, withAnnotation(map[string]string{
+				networking.HTTPProtocolAnnotationKey: "enabled",
+			})),
+			gw(defaultListener, withWildcardListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withAnnotation(map[string]string{
+				networking.HTTPProtocolAnnotationKey: "enabled",
+			}))),
+		}, servicesAndEndpoints...),
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withFinalizer, withAnnotation(map[string]string{
+				networking.HTTPProtocolAnnotationKey: "enabled",
+			}), readyStatus),
+		}},
+	}}
+
+	table.Test(t, reconcilerFactory(cfg))
+}
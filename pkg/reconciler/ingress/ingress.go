@@ -18,18 +18,30 @@ package ingress
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
+	"go.opencensus.io/trace"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	ingressreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
+	networkcfg "knative.dev/networking/pkg/config"
 	"knative.dev/networking/pkg/ingress"
 	"knative.dev/networking/pkg/status"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
 	"knative.dev/pkg/network"
 	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/tracker"
 
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
 	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 	gatewayalphalisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha2"
@@ -53,6 +65,41 @@ type Reconciler struct {
 	referenceGrantLister gatewayalphalisters.ReferenceGrantLister
 
 	gatewayLister gatewaylisters.GatewayLister
+
+	// secrets fetches the Secrets referenced by the TLS sections.
+	secrets *secretCache
+
+	// tracker re-enqueues Ingresses when the Secrets they reference change.
+	tracker tracker.Interface
+
+	// enqueueAfter re-enqueues Ingresses when their certificates expire.
+	enqueueAfter func(obj interface{}, after time.Duration)
+
+	// listeners batches the writes of Gateway listeners across Ingresses.
+	listeners *listenerAggregator
+
+	// conflicts detects hostnames claimed by more than one route or listener.
+	conflicts *conflictDetector
+
+	// capabilities remembers what Gateways turned out not to support.
+	capabilities capabilityTracker
+
+	// apiVersions are the Gateway API versions served by the cluster.
+	apiVersions resources.APIVersions
+
+	// policyMigrations remembers the ReferenceGrants whose ReferencePolicy
+	// predecessors are already gone.
+	policyMigrations referencePolicyMigrations
+
+	// readiness measures the time it takes Ingresses to become ready.
+	readiness readinessTracker
+
+	// traces ties the reconciles of an Ingress generation together.
+	traces generationTraces
+
+	// debug records the state of each Ingress for the debug endpoint. It's
+	// nil unless the endpoint is enabled.
+	debug *ingressDebugger
 }
 
 var (
@@ -60,23 +107,77 @@ var (
 )
 
 // ReconcileKind implements Interface.ReconcileKind.
-func (c *Reconciler) ReconcileKind(ctx context.Context, ingress *v1alpha1.Ingress) pkgreconciler.Event {
-	reconcileErr := c.reconcileIngress(ctx, ingress)
+func (c *Reconciler) ReconcileKind(ctx context.Context, ingress *v1alpha1.Ingress) (err pkgreconciler.Event) {
+	ctx, span := c.traces.startReconcile(ctx, ingress)
+	defer func() { endSpan(span, err) }()
+	c.observeGeneration(ingress)
+	c.debug.reconciling(ingress)
 
-	if reconcileErr != nil {
+	if reconcileErr := c.reconcileIngress(ctx, ingress); reconcileErr != nil {
 		ingress.Status.MarkIngressNotReady(notReconciledReason, notReconciledMessage)
 		return reconcileErr
 	}
 
+	c.recordTimeToReady(ctx, ingress)
 	return nil
 }
 
 // FinalizeKind implements Interface.FinalizeKind
 func (c *Reconciler) FinalizeKind(ctx context.Context, ingress *v1alpha1.Ingress) pkgreconciler.Event {
+	c.conflicts.forget(types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name})
+	c.secrets.retain(types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name}, nil)
+
+	done, err := c.clearAllGatewayListeners(ctx, ingress)
+	if err != nil {
+		return err
+	}
+	if !done {
+		// Keep our finalizer until the listeners are off the Gateways. The
+		// listener aggregator enqueues us once they are.
+		return controller.NewRequeueAfter(listenerBatchDelay)
+	}
+	return nil
+}
+
+// clearAllGatewayListeners has the listeners of the Ingress removed from
+// every Gateway that may hold them. It returns whether they are all gone;
+// the listener aggregator enqueues the Ingress once they are.
+func (c *Reconciler) clearAllGatewayListeners(ctx context.Context, ingress *v1alpha1.Ingress) (bool, error) {
 	gatewayConfig := config.FromContext(ctx).Gateway
 
-	// We currently only support TLS on the external IP
-	return c.clearGatewayListeners(ctx, ingress, gatewayConfig.Gateways[v1alpha1.IngressVisibilityExternalIP].Gateway)
+	c.listeners.retain(types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name}, nil)
+
+	// We currently only support TLS on the external IP. We don't know which
+	// Gateway holds the listeners, so clear all of them, along with those
+	// the HTTPRoutes may still be migrating off.
+	gateways := gatewayConfig.AllGatewayPools(v1alpha1.IngressVisibilityExternalIP)
+	routes, err := c.ownedHTTPRoutes(ctx, ingress)
+	if err != nil {
+		return false, err
+	}
+	for _, route := range routes {
+		for _, ref := range route.Spec.ParentRefs {
+			if gwName, ok := parentGateway(ref, route.Namespace); ok {
+				gateways = append(gateways, gwName)
+			}
+		}
+	}
+
+	cleared := make(map[types.NamespacedName]struct{}, len(gateways))
+	allDone := true
+	for _, gwName := range gateways {
+		gwName := gwName
+		if _, ok := cleared[gwName]; ok {
+			continue
+		}
+		cleared[gwName] = struct{}{}
+		done, err := c.clearGatewayListeners(ctx, ingress, &gwName)
+		if err != nil {
+			return false, err
+		}
+		allDone = allDone && done
+	}
+	return allDone, nil
 }
 
 func (c *Reconciler) reconcileIngress(ctx context.Context, ing *v1alpha1.Ingress) error {
@@ -89,16 +190,137 @@ func (c *Reconciler) reconcileIngress(ctx context.Context, ing *v1alpha1.Ingress
 	ing.SetDefaults(ctx)
 	before := ing.DeepCopy()
 
+	secrets := make([]types.NamespacedName, 0, len(ing.Spec.TLS))
+	for _, tls := range ing.Spec.TLS {
+		secrets = append(secrets, types.NamespacedName{Namespace: tls.SecretNamespace, Name: tls.SecretName})
+	}
+	c.secrets.retain(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}, secrets)
+
 	ing.Status.InitializeConditions()
 
+	// Before the probe paths go in, which would list each problem twice.
+	if validateIngress(ctx, ing) {
+		// Leave the routes alone, as for an unknown profile.
+		return nil
+	}
+
 	if _, err := ingress.InsertProbe(ing); err != nil {
 		return fmt.Errorf("failed to add knative probe header: %w", err)
 	}
 
-	for _, rule := range ing.Spec.Rules {
+	gateways, err := gatewaysFor(ctx, ing)
+	if err != nil {
+		// Leave the routes alone until the profile shows up, or the
+		// Ingress picks another one.
+		ing.Status.MarkIngressNotReady(unknownGatewayProfileReason, err.Error())
+		return nil
+	}
+
+	pools := make(map[v1alpha1.IngressVisibility][]types.NamespacedName, len(gateways))
+	for visibility, gw := range gateways {
+		gw := gw
+		pools[visibility] = gw.GatewayPool()
+	}
+	if conflict, err := c.conflicts.find(ing, pools); err != nil {
+		return err
+	} else if conflict != nil {
+		return c.yieldHostnames(ctx, ing, conflict)
+	}
+	c.conflicts.forget(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+
+	// For now, we only reconcile the external visibility, because there's
+	// no way to provide TLS for internal listeners.
+	externalConfig := gateways[v1alpha1.IngressVisibilityExternalIP]
+	externalGw := *externalConfig.Gateway
+	if len(ing.Spec.TLS) > 0 {
+		gwName, err := c.placeListeners(ctx, ing, externalConfig.GatewayPool())
+		if err != nil {
+			return err
+		}
+		externalGw = gwName
+	}
+
+	// ruleGateways holds the Gateway the HTTPRoute of each rule attaches to,
+	// and retiring the parents it had before, if it was moved since.
+	ruleGateways := make([]types.NamespacedName, len(ing.Spec.Rules))
+	retiring := make([][]gatewayapi.ParentReference, len(ing.Spec.Rules))
+	retiringGateways := make(map[types.NamespacedName]v1alpha1.IngressVisibility)
+	for i, rule := range ing.Spec.Rules {
 		rule := rule
+		ruleGateways[i] = *gateways[rule.Visibility].Gateway
+		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
+			ruleGateways[i] = externalGw
+		}
 
-		httproutes, err := c.reconcileHTTPRoute(ctx, ing, &rule)
+		parents, err := c.retiringParents(ing, &rule, ruleGateways[i])
+		if err != nil {
+			return err
+		}
+		retiring[i] = parents
+		for _, ref := range parents {
+			if gwName, ok := parentGateway(ref, ing.Namespace); ok {
+				retiringGateways[gwName] = rule.Visibility
+			}
+		}
+	}
+
+	// In blue/green mode, the HTTPRoutes stay on the Gateways they're moving
+	// off, along with the listeners of the Ingress, until the new Gateways
+	// took over.
+	migrating := false
+	if len(retiringGateways) > 0 && gatewayConfig.Migration == config.MigrationBlueGreen {
+		done, err := c.migrationDone(ctx, before, ruleGateways)
+		if err != nil {
+			return fmt.Errorf("failed to probe Ingress: %w", err)
+		}
+		migrating = !done
+	}
+
+	// The listeners of the Ingress may be left on Gateways of another
+	// profile it used before.
+	for _, gwName := range gatewayConfig.AllGatewayPools(v1alpha1.IngressVisibilityExternalIP) {
+		gwName := gwName
+		if _, ok := retiringGateways[gwName]; gwName == externalGw || (migrating && ok) {
+			continue
+		}
+		if _, err := c.clearGatewayListeners(ctx, ing, &gwName); err != nil {
+			return err
+		}
+	}
+
+	d, err := c.desired(ctx, ing, gateways, externalGw)
+	if err != nil {
+		return err
+	}
+
+	// The default listeners go on every Gateway of their visibility, whether
+	// this Ingress uses them or not.
+	for visibility, gw := range gateways {
+		gw := gw
+		for _, gwName := range gw.GatewayPool() {
+			c.listeners.setDefaults(gwName, d.defaults[visibility])
+		}
+	}
+
+	if d.httpsMissing != "" {
+		return c.disableHTTP(ctx, ing, d.httpsMissing)
+	}
+	if d.unsupported != nil {
+		// Leave the HTTPRoutes as they are, until the Gateway or the
+		// Ingress change.
+		ing.Status.MarkIngressNotReady(unsupportedFeatureReason, d.unsupported.Error())
+		return nil
+	}
+
+	// gatewayListeners holds the listeners this Ingress needs on each Gateway.
+	gatewayListeners := d.listeners
+
+	for i, desired := range d.routes {
+		var keep []gatewayapi.ParentReference
+		if migrating {
+			keep = retiring[i]
+		}
+		httproutes, err := c.reconcileHTTPRoute(ctx, ing, desired, ruleGateways[i], keep)
 		if err != nil {
 			return err
 		}
@@ -107,44 +329,106 @@ func (c *Reconciler) reconcileIngress(ctx context.Context, ing *v1alpha1.Ingress
 			ing.Status.MarkNetworkConfigured()
 		} else {
 			ing.Status.MarkIngressNotReady("HTTPRouteNotReady", "Waiting for HTTPRoute becomes Ready.")
+			trace.FromContext(ctx).Annotatef(nil, "HTTPRoute %q is not accepted by its Gateway yet", httproutes.Name)
 		}
 	}
 
-	listeners := make([]*gatewayapi.Listener, 0, len(ing.Spec.TLS))
-	for _, tls := range ing.Spec.TLS {
-		tls := tls
+	if !migrating {
+		// The HTTPRoutes are off the Gateways they were moving off, so the
+		// listeners can go too.
+		for gwName := range retiringGateways {
+			gwName := gwName
+			if gwName == externalGw {
+				continue
+			}
+			if _, err := c.clearGatewayListeners(ctx, ing, &gwName); err != nil {
+				return err
+			}
+		}
+	}
 
-		l, err := c.reconcileTLS(ctx, &tls, ing)
-		if err != nil {
+	// The listeners also stay on the Gateways the HTTPRoutes are moving off
+	// while migrating.
+	var tlsRetiring []types.NamespacedName
+	if migrating {
+		for gwName, visibility := range retiringGateways {
+			if visibility == v1alpha1.IngressVisibilityExternalIP {
+				tlsRetiring = append(tlsRetiring, gwName)
+			}
+		}
+		sort.Slice(tlsRetiring, func(i, j int) bool {
+			return tlsRetiring[i].String() < tlsRetiring[j].String()
+		})
+	}
+
+	for i, tls := range d.tls {
+		if reason, message, err := c.checkTLSSecret(ctx, ing, tls); err != nil {
+			return err
+		} else if reason != "" {
+			// Leave the listeners alone, a Gateway serving a stale
+			// certificate beats one serving none.
+			ing.Status.MarkIngressNotReady(reason, message)
+			return nil
+		}
+
+		if err := c.reconcileTLS(ctx, tls, ing, externalGw, d.grants[i]); err != nil {
+			return err
+		}
+		for _, gwName := range tlsRetiring {
+			if err := c.reconcileTLS(ctx, tls, ing, gwName, makeTLSReferenceGrant(ctx, ing, tls, gwName)); err != nil {
+				return err
+			}
+			gatewayListeners[gwName] = append(gatewayListeners[gwName], makeTLSListeners(ing, tls)...)
+		}
+	}
+
+	// The listener of an Ingress whose TLS sections were removed goes too.
+	if _, ok := gatewayListeners[externalGw]; !ok {
+		if _, err := c.clearGatewayListeners(ctx, ing, &externalGw); err != nil {
 			return err
 		}
-		listeners = append(listeners, l...)
 	}
 
-	if len(listeners) > 0 {
-		// For now, we only reconcile the external visibility, because there's
-		// no way to provide TLS for internal listeners.
-		err := c.reconcileGatewayListeners(
-			ctx, listeners, ing, *gatewayConfig.Gateways[v1alpha1.IngressVisibilityExternalIP].Gateway)
+	c.debug.desiredListeners(ing, gatewayListeners)
+
+	listenerGateways := make([]types.NamespacedName, 0, len(gatewayListeners))
+	for gwName := range gatewayListeners {
+		listenerGateways = append(listenerGateways, gwName)
+	}
+	sort.Slice(listenerGateways, func(i, j int) bool {
+		return listenerGateways[i].String() < listenerGateways[j].String()
+	})
+	c.listeners.retain(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}, listenerGateways)
+
+	listenersReady := true
+	for _, gwName := range listenerGateways {
+		ready, err := c.reconcileGatewayListeners(ctx, gatewayListeners[gwName], ing, gwName)
 		if err != nil {
 			return err
 		}
+		listenersReady = listenersReady && ready
+	}
+	if !listenersReady {
+		// We get re-enqueued once the listeners are on the Gateways.
+		ing.Status.MarkLoadBalancerNotReady()
+		return nil
 	}
 
 	// TODO: check Gateway readiness before reporting Ingress ready
 
 	ready, err := c.statusManager.IsReady(ctx, before)
+	c.debug.probed(ing, ready, err)
 	if err != nil {
 		return fmt.Errorf("failed to probe Ingress: %w", err)
 	}
 
 	if ready {
-		namespacedNameService := gatewayConfig.Gateways[v1alpha1.IngressVisibilityExternalIP].Service
+		namespacedNameService := gateways[v1alpha1.IngressVisibilityExternalIP].Service
 		publicLbs := []v1alpha1.LoadBalancerIngressStatus{
 			{DomainInternal: network.GetServiceHostname(namespacedNameService.Name, namespacedNameService.Namespace)},
 		}
 
-		namespacedNameLocalService := gatewayConfig.Gateways[v1alpha1.IngressVisibilityClusterLocal].Service
+		namespacedNameLocalService := gateways[v1alpha1.IngressVisibilityClusterLocal].Service
 		privateLbs := []v1alpha1.LoadBalancerIngressStatus{
 			{DomainInternal: network.GetServiceHostname(namespacedNameLocalService.Name, namespacedNameLocalService.Namespace)},
 		}
@@ -157,6 +441,149 @@ func (c *Reconciler) reconcileIngress(ctx context.Context, ing *v1alpha1.Ingress
 	return nil
 }
 
+// desiredIngress is what an Ingress needs from the Gateway API.
+type desiredIngress struct {
+	// defaults holds the default listeners of each visibility, which go on
+	// every Gateway of its pool.
+	defaults map[v1alpha1.IngressVisibility][]*gatewayapi.Listener
+
+	// httpsMissing is an external host without HTTPS, when plain HTTP is
+	// disabled. Nothing else is computed then.
+	httpsMissing string
+	// unsupported is set when a rule needs a feature its Gateway lacks. The
+	// HTTPRoutes aren't computed then.
+	unsupported *resources.UnsupportedFeatureError
+
+	// routes holds the HTTPRoute of each rule.
+	routes []*gatewayapi.HTTPRoute
+	// tls holds the TLS sections that need listeners of their own, and
+	// grants the ReferenceGrant that lets the external Gateway use the
+	// Secret of each.
+	tls    []*v1alpha1.IngressTLS
+	grants []*gatewayv1alpha2.ReferenceGrant
+	// listeners holds the listeners the Ingress needs on each Gateway.
+	listeners map[types.NamespacedName][]*gatewayapi.Listener
+}
+
+// desired computes what the Ingress needs from the Gateway API, given the
+// Gateways of its profile and the one holding its external listeners. It
+// writes nothing, so that Render can share it.
+func (c *Reconciler) desired(ctx context.Context, ing *v1alpha1.Ingress,
+	gateways map[v1alpha1.IngressVisibility]config.GatewayConfig, externalGw types.NamespacedName) (*desiredIngress, error) {
+	gatewayConfig := config.FromContext(ctx).Gateway
+
+	d := &desiredIngress{
+		defaults:  make(map[v1alpha1.IngressVisibility][]*gatewayapi.Listener, len(gateways)),
+		listeners: make(map[types.NamespacedName][]*gatewayapi.Listener),
+	}
+	for visibility, gw := range gateways {
+		d.defaults[visibility] = makeDefaultListeners(gw.DefaultListeners)
+	}
+
+	httpDisabled := httpProtocol(ctx, ing) == networkcfg.HTTPDisabled
+	if httpDisabled {
+		for _, rule := range ing.Spec.Rules {
+			rule := rule
+			if rule.Visibility != v1alpha1.IngressVisibilityExternalIP {
+				continue
+			}
+			if _, host := httpsSections(ing, &rule, d.defaults[rule.Visibility]); host != "" {
+				d.httpsMissing = host
+				return d, nil
+			}
+		}
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		rule := rule
+
+		// HTTPRoutes attach to the Gateway holding the listeners of their hosts.
+		gwName := *gateways[rule.Visibility].Gateway
+		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
+			gwName = externalGw
+		}
+
+		var sections []gatewayapi.SectionName
+		if httpDisabled && rule.Visibility == v1alpha1.IngressVisibilityExternalIP {
+			// Only attach to HTTPS listeners, so nothing serves plain HTTP.
+			// We made sure above there's one for every host.
+			sections, _ = httpsSections(ing, &rule, d.defaults[rule.Visibility])
+			for _, l := range d.defaults[rule.Visibility] {
+				for _, section := range sections {
+					if l.Name == section {
+						d.listeners[gwName] = append(d.listeners[gwName], l)
+					}
+				}
+			}
+		} else if shared, ok := matchDefaultListeners(d.defaults[rule.Visibility], rule.Hosts); ok {
+			httpSections, err := c.httpSections(gwName)
+			if err != nil && !apierrs.IsNotFound(err) {
+				return nil, err
+			} else if err == nil {
+				// Attach to the default listeners by name, along with the
+				// plain HTTP listeners we'd otherwise attach to implicitly.
+				sections = httpSections
+				for _, l := range shared {
+					sections = append(sections, l.Name)
+				}
+			}
+			d.listeners[gwName] = append(d.listeners[gwName], shared...)
+		}
+
+		// Only use what the Gateway supports: the capabilities of its
+		// config, less what it rejected before.
+		if route, err := c.httprouteLister.HTTPRoutes(ing.Namespace).Get(resources.LongestHost(rule.Hosts)); err == nil && metav1.IsControlledBy(route, ing) {
+			c.capabilities.observe(route, gwName)
+		}
+		gwConfig := gateways[rule.Visibility]
+		caps := c.capabilities.restrict(gwName, gatewayConfig.CapabilitiesOf(&gwConfig))
+
+		route, err := resources.MakeHTTPRoute(ctx, ing, &rule, gwName, caps, sections...)
+		if errors.As(err, &d.unsupported) {
+			d.routes = nil
+			return d, nil
+		} else if err != nil {
+			return nil, err
+		}
+		d.routes = append(d.routes, route)
+	}
+
+	// For now, only the external Gateways get TLS listeners, because there's
+	// no way to provide TLS for internal listeners.
+	for i := range ing.Spec.TLS {
+		tls := &ing.Spec.TLS[i]
+		if _, ok := matchDefaultListeners(d.defaults[v1alpha1.IngressVisibilityExternalIP], tls.Hosts); ok {
+			// The hosts are served by default listeners already.
+			continue
+		}
+		d.tls = append(d.tls, tls)
+		d.grants = append(d.grants, makeTLSReferenceGrant(ctx, ing, tls, externalGw))
+		d.listeners[externalGw] = append(d.listeners[externalGw], makeTLSListeners(ing, tls)...)
+	}
+	return d, nil
+}
+
+// yieldHostnames removes the routes and listeners of an Ingress that lost a
+// hostname conflict, and reports the conflict on its status.
+func (c *Reconciler) yieldHostnames(ctx context.Context, ing *v1alpha1.Ingress, conflict *hostnameConflict) error {
+	logging.FromContext(ctx).Infof("Ingress lost a hostname conflict: %s", conflict.message())
+
+	ing.Status.MarkIngressNotReady(hostnameConflictReason, conflict.message())
+	c.conflicts.markLoser(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+
+	// The routes go without waiting for the listeners to be off the
+	// Gateways, which the listener aggregator does in the background.
+	if _, err := c.clearAllGatewayListeners(ctx, ing); err != nil {
+		return err
+	}
+
+	routes, err := c.ownedHTTPRoutes(ctx, ing)
+	if err != nil {
+		return err
+	}
+	return c.deleteHTTPRoutes(ctx, ing, routes)
+}
+
 // isHTTPRouteReady will check the status conditions of the ingress and return true if
 // all gateways have been admitted.
 func isHTTPRouteReady(r *gatewayapi.HTTPRoute) bool {
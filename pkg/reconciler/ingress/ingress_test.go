@@ -18,6 +18,7 @@ package ingress
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"testing"
@@ -28,6 +29,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/pointer"
 
 	fakegwapiclientset "knative.dev/net-gateway-api/pkg/client/injection/client/fake"
@@ -39,6 +43,7 @@ import (
 	ingressreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/ingress"
 	networkcfg "knative.dev/networking/pkg/config"
 	"knative.dev/networking/pkg/ingress"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
@@ -200,6 +205,11 @@ func TestReconcile(t *testing.T) {
 			// Listers index properties about resources
 			httprouteLister: listers.GetHTTPRouteLister(),
 			gatewayLister:   listers.GetGatewayLister(),
+			listeners:       fakeListenerAggregator(ctx, listers),
+			conflicts:       fakeConflictDetector(listers),
+			secrets:         newSecretCache(fakekubeclient.Get(ctx), listers.GetSecretMetadataIndexer()),
+			tracker:         &NullTracker{},
+			enqueueAfter:    func(interface{}, time.Duration) {},
 			statusManager: &fakeStatusManager{
 				FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
 					return true, nil
@@ -251,6 +261,11 @@ func TestReconcileProberNotReady(t *testing.T) {
 			// Listers index properties about resources
 			httprouteLister: listers.GetHTTPRouteLister(),
 			gatewayLister:   listers.GetGatewayLister(),
+			listeners:       fakeListenerAggregator(ctx, listers),
+			conflicts:       fakeConflictDetector(listers),
+			secrets:         newSecretCache(fakekubeclient.Get(ctx), listers.GetSecretMetadataIndexer()),
+			tracker:         &NullTracker{},
+			enqueueAfter:    func(interface{}, time.Duration) {},
 			statusManager: &fakeStatusManager{
 				FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
 					return false, nil
@@ -283,16 +298,17 @@ func TestReconcileTLS(t *testing.T) {
 			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
 			rp(secret(secretName, nsName)),
 		},
-		WantUpdates: []clientgotesting.UpdateActionImpl{{
-			Object: gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
-		}},
 		WantPatches: []clientgotesting.PatchActionImpl{{
 			ActionImpl: clientgotesting.ActionImpl{
 				Namespace: "ns",
 			},
 			Name:  "name",
 			Patch: []byte(`{"metadata":{"finalizers":["ingresses.networking.internal.knative.dev"],"resourceVersion":""}}`),
-		}},
+		}, gwPatch(t, listenersPatch{{
+			Operation: "add",
+			Path:      "/spec/listeners/-",
+			Value:     &gw(tlsListener("secure.example.com", nsName, secretName)).Spec.Listeners[0],
+		}})},
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
 				i.Status.InitializeConditions()
@@ -337,6 +353,104 @@ func TestReconcileTLS(t *testing.T) {
 		WantEvents: []string{
 			// None
 		},
+	}, {
+		Name: "Outdated Listener",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			secret(secretName, nsName),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, "old-secret")),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			rp(secret(secretName, nsName)),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "test",
+			Path:      "/spec/listeners/1/name",
+			Value:     secureListenerName("secure.example.com"),
+		}, {
+			Operation: "replace",
+			Path:      "/spec/listeners/1",
+			Value:     &gw(tlsListener("secure.example.com", nsName, secretName)).Spec.Listeners[0],
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkLoadBalancerReady(
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: publicSvc,
+					}},
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: privateSvc,
+					}})
+			}),
+		}},
+	}, {
+		Name: "Multi-host TLS",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), withTLSHost("secure.example.org")),
+			func() *corev1.Secret {
+				s := secret(secretName, nsName)
+				s.Data[corev1.TLSCertKey] = testCertificate(time.Now().Add(time.Hour), "secure.example.com", "secure.example.org")
+				return s
+			}(),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withTLSHost("secure.example.org"))),
+			rp(secret(secretName, nsName)),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "add",
+			Path:      "/spec/listeners/-",
+			Value:     &gw(tlsListener("secure.example.org", nsName, secretName)).Spec.Listeners[0],
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), withTLSHost("secure.example.org"), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkLoadBalancerReady(
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: publicSvc,
+					}},
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: privateSvc,
+					}})
+			}),
+		}},
+	}, {
+		Name: "Listener named after the Ingress alone",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			secret(secretName, nsName),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName), func(g *gatewayapi.Gateway) {
+				g.Spec.Listeners[1].Name = listenerPrefix
+			}),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			rp(secret(secretName, nsName)),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "test",
+			Path:      "/spec/listeners/1/name",
+			Value:     listenerPrefix,
+		}, {
+			Operation: "remove",
+			Path:      "/spec/listeners/1",
+		}, {
+			Operation: "add",
+			Path:      "/spec/listeners/-",
+			Value:     &gw(tlsListener("secure.example.com", nsName, secretName)).Spec.Listeners[0],
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkLoadBalancerReady(
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: publicSvc,
+					}},
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: privateSvc,
+					}})
+			}),
+		}},
 	}, {
 		Name:                    "Cleanup Listener",
 		Key:                     "ns/name",
@@ -352,8 +466,41 @@ func TestReconcileTLS(t *testing.T) {
 			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
 			rp(secret(secretName, nsName)),
 		},
-		WantUpdates: []clientgotesting.UpdateActionImpl{{
-			Object: gw(defaultListener),
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "test",
+			Path:      "/spec/listeners/1/name",
+			Value:     secureListenerName("secure.example.com"),
+		}, {
+			Operation: "remove",
+			Path:      "/spec/listeners/1",
+		}})},
+	}, {
+		Name: "Listener of removed TLS",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "test",
+			Path:      "/spec/listeners/1/name",
+			Value:     secureListenerName("secure.example.com"),
+		}, {
+			Operation: "remove",
+			Path:      "/spec/listeners/1",
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkLoadBalancerReady(
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: publicSvc,
+					}},
+					[]v1alpha1.LoadBalancerIngressStatus{{
+						DomainInternal: privateSvc,
+					}})
+			}),
 		}},
 	}, {
 		Name:    "No Gateway",
@@ -391,38 +538,131 @@ func TestReconcileTLS(t *testing.T) {
 		},
 	}}
 
-	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
-		r := &Reconciler{
-			gwapiclient:          fakegwapiclientset.Get(ctx),
-			httprouteLister:      listers.GetHTTPRouteLister(),
-			referenceGrantLister: listers.GetReferenceGrantLister(),
-			gatewayLister:        listers.GetGatewayLister(),
-			statusManager: &fakeStatusManager{FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
-				return true, nil
-			}},
-		}
-		// The fake tracker's `Add` method incorrectly pluralizes "gatewaies" using UnsafeGuessKindToResource,
-		// so create this via explicit call (per note in client-go/testing/fixture.go in tracker.Add)
-		fakeCreates := []runtime.Object{}
-		for _, x := range tr.Objects {
-			myGw, ok := x.(*gatewayapi.Gateway)
-			if ok {
-				fakegwapiclientset.Get(ctx).GatewayV1beta1().Gateways(myGw.Namespace).Create(ctx, myGw, metav1.CreateOptions{})
-				tr.SkipNamespaceValidation = true
-				fakeCreates = append(fakeCreates, myGw)
-			}
-		}
-		tr.WantCreates = append(fakeCreates, tr.WantCreates...)
+	table.Test(t, reconcilerFactory(defaultConfig))
+}
 
-		ingr := ingressreconciler.NewReconciler(ctx, logging.FromContext(ctx), fakeingressclient.Get(ctx),
-			listers.GetIngressLister(), controller.GetEventRecorder(ctx), r, gatewayAPIIngressClassName,
-			controller.Options{
-				ConfigStore: &testConfigStore{
-					config: defaultConfig,
-				}})
+func TestPlaceListeners(t *testing.T) {
+	pool := []types.NamespacedName{
+		{Namespace: testNamespace, Name: "gateway-0"},
+		{Namespace: testNamespace, Name: "gateway-1"},
+	}
+	ingWithTLS := ing(withBasicSpec, withGatewayAPIClass, withTLS("secret"))
+	other := types.NamespacedName{Namespace: "ns", Name: "other"}
 
-		return ingr
-	}))
+	tests := []struct {
+		name       string
+		pool       []types.NamespacedName
+		ing        *v1alpha1.Ingress
+		objects    []runtime.Object
+		pending    map[types.NamespacedName]int
+		want       types.NamespacedName
+		wantErr    bool
+		wantEvents int
+	}{{
+		name: "single gateway",
+		pool: pool[:1],
+		want: pool[0],
+	}, {
+		name: "no gateways",
+		pool: pool,
+		want: pool[0],
+	}, {
+		name: "least loaded",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(3)),
+			gw(named("gateway-1"), withListeners(1)),
+		},
+		want: pool[1],
+	}, {
+		name: "ties go to the first gateway",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(2)),
+			gw(named("gateway-1"), withListeners(2)),
+		},
+		want: pool[0],
+	}, {
+		name: "pending listeners count",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(3)),
+			gw(named("gateway-1"), withListeners(1)),
+		},
+		pending: map[types.NamespacedName]int{pool[1]: 5},
+		want:    pool[0],
+	}, {
+		name: "stays on the gateway holding its listeners",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(1)),
+			gw(named("gateway-1"), withListeners(10), tlsListener("secure.example.com", "ns", "secret")),
+		},
+		want: pool[1],
+	}, {
+		name: "full gateways are skipped",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(maxGatewayListeners)),
+			gw(named("gateway-1"), withListeners(50)),
+		},
+		want: pool[1],
+	}, {
+		name: "all gateways full",
+		pool: pool,
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(maxGatewayListeners)),
+			gw(named("gateway-1"), withListeners(maxGatewayListeners)),
+		},
+		wantErr:    true,
+		wantEvents: 1,
+	}, {
+		name: "every TLS host takes a listener",
+		pool: pool,
+		ing:  ing(withBasicSpec, withGatewayAPIClass, withTLS("secret"), withTLSHost("a.example.com"), withTLSHost("b.example.com")),
+		objects: []runtime.Object{
+			gw(named("gateway-0"), withListeners(maxGatewayListeners-2)),
+			gw(named("gateway-1"), withListeners(maxGatewayListeners-1)),
+		},
+		wantErr:    true,
+		wantEvents: 1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t, withManagedBySelector)
+			recorder := record.NewFakeRecorder(10)
+			ctx = controller.WithEventRecorder(ctx, recorder)
+
+			listers := NewListers(test.objects)
+			r := &Reconciler{
+				gatewayLister: listers.GetGatewayLister(),
+				listeners:     fakeListenerAggregator(ctx, &listers),
+			}
+			for gwName, n := range test.pending {
+				listeners := make([]*gatewayapi.Listener, 0, n)
+				for i := 0; i < n; i++ {
+					listeners = append(listeners, testListener(fmt.Sprint("pending-", i), "other.example.com"))
+				}
+				r.listeners.set(other, gwName, "other", listeners)
+			}
+
+			ing := ingWithTLS
+			if test.ing != nil {
+				ing = test.ing
+			}
+			got, err := r.placeListeners(ctx, ing, test.pool)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("placeListeners() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("placeListeners() = %v, want: %v", got, test.want)
+			}
+			if got := len(recorder.Events); got != test.wantEvents {
+				t.Errorf("Got %d events, want: %d", got, test.wantEvents)
+			}
+		})
+	}
 }
 
 func TestReconcileProbeError(t *testing.T) {
@@ -462,6 +702,11 @@ func TestReconcileProbeError(t *testing.T) {
 			// Listers index properties about resources
 			httprouteLister: listers.GetHTTPRouteLister(),
 			gatewayLister:   listers.GetGatewayLister(),
+			listeners:       fakeListenerAggregator(ctx, listers),
+			conflicts:       fakeConflictDetector(listers),
+			secrets:         newSecretCache(fakekubeclient.Get(ctx), listers.GetSecretMetadataIndexer()),
+			tracker:         &NullTracker{},
+			enqueueAfter:    func(interface{}, time.Duration) {},
 
 			statusManager: &fakeStatusManager{
 				FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
@@ -494,7 +739,8 @@ func httpRoute(t *testing.T, i *v1alpha1.Ingress, opts ...HTTPRouteOption) runti
 	t.Helper()
 	ingress.InsertProbe(i)
 	ctx := (&testConfigStore{config: defaultConfig}).ToContext(context.Background())
-	httpRoute, _ := resources.MakeHTTPRoute(ctx, i, &i.Spec.Rules[0])
+	rule := &i.Spec.Rules[0]
+	httpRoute, _ := resources.MakeHTTPRoute(ctx, i, rule, *defaultConfig.Gateway.Gateways[rule.Visibility].Gateway, nil)
 	for _, opt := range opts {
 		opt(httpRoute)
 	}
@@ -517,6 +763,102 @@ func (m *fakeStatusManager) IsReady(ctx context.Context, ing *v1alpha1.Ingress)
 	return m.FakeIsReady(ctx, ing)
 }
 
+func fakeListenerAggregator(ctx context.Context, listers *Listers) *listenerAggregator {
+	agg := newListenerAggregator(logging.FromContext(ctx), fakegwapiclientset.Get(ctx),
+		listers.GetGatewayLister(), func(types.NamespacedName) {})
+	agg.queue = &syncQueue{RateLimitingInterface: agg.queue, ctx: ctx, agg: agg}
+	return agg
+}
+
+// syncQueue writes the listeners of a Gateway as soon as it is queued, and
+// hands the result to the aggregator as the Gateway informer would, so that
+// the writes and their outcome show within a single reconcile.
+type syncQueue struct {
+	workqueue.RateLimitingInterface
+	ctx context.Context
+	agg *listenerAggregator
+}
+
+func (q *syncQueue) AddAfter(item interface{}, _ time.Duration) {
+	gwName := item.(types.NamespacedName)
+	if err := q.agg.syncGateway(q.ctx, gwName); err != nil {
+		return
+	}
+	if gw, err := q.agg.gwapiclient.GatewayV1beta1().Gateways(gwName.Namespace).Get(q.ctx, gwName.Name, metav1.GetOptions{}); err == nil {
+		q.agg.gatewayChanged(gw)
+	}
+}
+
+func fakeConflictDetector(listers *Listers) *conflictDetector {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		httpRouteHostnameIndex: indexHTTPRouteHostnames,
+	})
+	for _, obj := range listers.GetGatewayAPIObjects() {
+		if route, ok := obj.(*gatewayapi.HTTPRoute); ok {
+			// As the informer caches them.
+			trimmed, _ := trimHTTPRoute(route.DeepCopy())
+			indexer.Add(trimmed)
+		}
+	}
+	return newConflictDetector(indexer, listers.GetIngressLister(), listers.GetGatewayLister(), func(types.NamespacedName) {})
+}
+
+// newTestReconciler returns a Reconciler reading from the listers of a table
+// test, to which every Ingress is ready.
+func newTestReconciler(ctx context.Context, listers *Listers) *Reconciler {
+	return &Reconciler{
+		gwapiclient:          fakegwapiclientset.Get(ctx),
+		httprouteLister:      listers.GetHTTPRouteLister(),
+		referenceGrantLister: listers.GetReferenceGrantLister(),
+		gatewayLister:        listers.GetGatewayLister(),
+		listeners:            fakeListenerAggregator(ctx, listers),
+		conflicts:            fakeConflictDetector(listers),
+		secrets:              newSecretCache(fakekubeclient.Get(ctx), listers.GetSecretMetadataIndexer()),
+		tracker:              &NullTracker{},
+		enqueueAfter:         func(interface{}, time.Duration) {},
+		statusManager: &fakeStatusManager{FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
+			return true, nil
+		}},
+	}
+}
+
+// newTestIngressReconciler wraps the Reconciler in the generated one, running
+// with the given configuration.
+func newTestIngressReconciler(ctx context.Context, listers *Listers, r *Reconciler, cfg *config.Config) controller.Reconciler {
+	return ingressreconciler.NewReconciler(ctx, logging.FromContext(ctx), fakeingressclient.Get(ctx),
+		listers.GetIngressLister(), controller.GetEventRecorder(ctx), r, gatewayAPIIngressClassName,
+		controller.Options{
+			ConfigStore: &testConfigStore{
+				config: cfg,
+			}})
+}
+
+// reconcilerFactory returns the Factory of table tests running the Reconciler
+// of newTestReconciler with the given configuration.
+func reconcilerFactory(cfg *config.Config) Factory {
+	return GatewayFactory(func(ctx context.Context, listers *Listers, _ configmap.Watcher, tr *TableRow) controller.Reconciler {
+		createGateways(ctx, tr)
+		return newTestIngressReconciler(ctx, listers, newTestReconciler(ctx, listers), cfg)
+	})
+}
+
+// createGateways creates the Gateways of the row through the fake client, and
+// expects their creation. The fake tracker's `Add` method incorrectly
+// pluralizes "gatewaies" using UnsafeGuessKindToResource, so they have to be
+// created via explicit call (per note in client-go/testing/fixture.go in
+// tracker.Add)
+func createGateways(ctx context.Context, tr *TableRow) {
+	fakeCreates := []runtime.Object{}
+	for _, x := range tr.Objects {
+		if myGw, ok := x.(*gatewayapi.Gateway); ok {
+			fakegwapiclientset.Get(ctx).GatewayV1beta1().Gateways(myGw.Namespace).Create(ctx, myGw, metav1.CreateOptions{})
+			tr.SkipNamespaceValidation = true
+			fakeCreates = append(fakeCreates, myGw)
+		}
+	}
+	tr.WantCreates = append(fakeCreates, tr.WantCreates...)
+}
+
 type testConfigStore struct {
 	config *config.Config
 }
@@ -555,6 +897,39 @@ func gw(opts ...GatewayOption) *gatewayapi.Gateway {
 	return g
 }
 
+func gwPatch(t *testing.T, patch listenersPatch) clientgotesting.PatchActionImpl {
+	t.Helper()
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal("Failed to marshal Gateway patch:", err)
+	}
+	return clientgotesting.PatchActionImpl{
+		ActionImpl: clientgotesting.ActionImpl{
+			Namespace: testNamespace,
+		},
+		Name:  publicName,
+		Patch: data,
+	}
+}
+
+func named(name string) GatewayOption {
+	return func(g *gatewayapi.Gateway) {
+		g.Name = name
+	}
+}
+
+func withListeners(n int) GatewayOption {
+	return func(g *gatewayapi.Gateway) {
+		for i := 0; i < n; i++ {
+			g.Spec.Listeners = append(g.Spec.Listeners, gatewayapi.Listener{
+				Name:     gatewayapi.SectionName(fmt.Sprint("listener-", i)),
+				Port:     443,
+				Protocol: "HTTPS",
+			})
+		}
+	}
+}
+
 func defaultListener(g *gatewayapi.Gateway) {
 	g.Spec.Listeners = append(g.Spec.Listeners, gatewayapi.Listener{
 		Name:     "http",
@@ -563,10 +938,16 @@ func defaultListener(g *gatewayapi.Gateway) {
 	})
 }
 
+// secureListenerName returns the name of the listener of the TLS host of the
+// test Ingresses.
+func secureListenerName(host string) gatewayapi.SectionName {
+	return tlsListenerName(ing(), host)
+}
+
 func tlsListener(hostname, nsName, secretName string) GatewayOption {
 	return func(g *gatewayapi.Gateway) {
 		g.Spec.Listeners = append(g.Spec.Listeners, gatewayapi.Listener{
-			Name:     gatewayapi.SectionName("kni-"),
+			Name:     secureListenerName(hostname),
 			Hostname: (*gatewayapi.Hostname)(&hostname),
 			Port:     443,
 			Protocol: "HTTPS",
@@ -609,17 +990,25 @@ func withTLS(secret string) IngressOption {
 	}
 }
 
+// withTLSHost adds the host to the last TLS section of the Ingress.
+func withTLSHost(host string) IngressOption {
+	return func(i *v1alpha1.Ingress) {
+		tls := &i.Spec.TLS[len(i.Spec.TLS)-1]
+		tls.Hosts = append(tls.Hosts, host)
+	}
+}
+
 func secret(name, ns string) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: ns,
 		},
-		StringData: map[string]string{
-			"ca.crt": "signed thing",
-			"ca.key": "private thing",
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       testCertificate(time.Now().Add(time.Hour), "secure.example.com"),
+			corev1.TLSPrivateKeyKey: []byte("private thing"),
 		},
-		Type: "kubernetes.io/tls",
+		Type: corev1.SecretTypeTLS,
 	}
 }
 
@@ -629,6 +1018,9 @@ func rp(to *corev1.Secret) *gatewayapialpha.ReferenceGrant {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      to.Name + "-" + testNamespace,
 			Namespace: to.Namespace,
+			Labels: map[string]string{
+				resources.ManagedByLabelKey: resources.ManagedByLabelValue,
+			},
 			OwnerReferences: []metav1.OwnerReference{{
 				APIVersion:         "networking.internal.knative.dev/v1alpha1",
 				Kind:               "Ingress",
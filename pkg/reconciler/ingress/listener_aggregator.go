@@ -0,0 +1,511 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+)
+
+// listenerBatchDelay is how long listener changes are collected before they
+// are written to a Gateway.
+const listenerBatchDelay = time.Second
+
+// listenerAggregator collects the listeners every Ingress wants on each
+// Gateway and writes them out on a per-Gateway workqueue, so that a resync of
+// many Ingresses results in a handful of Gateway writes instead of one each.
+type listenerAggregator struct {
+	logger        *zap.SugaredLogger
+	gwapiclient   gatewayclientset.Interface
+	gatewayLister gatewaylisters.GatewayLister
+
+	// enqueueIngress is called for Ingresses whose listeners were written, or
+	// failed to be written, to their Gateway.
+	enqueueIngress func(types.NamespacedName)
+
+	// debug records the listener patches for the debug endpoint, if enabled.
+	debug *ingressDebugger
+
+	queue workqueue.RateLimitingInterface
+
+	mu       sync.Mutex
+	gateways map[types.NamespacedName]*gatewayListeners
+}
+
+// gatewayListeners is the state the aggregator keeps for a single Gateway.
+type gatewayListeners struct {
+	// desired holds the listeners each Ingress wants on the Gateway.
+	desired map[types.NamespacedName][]*gatewayapi.Listener
+	// pending holds the Ingresses whose listeners aren't on the Gateway yet.
+	pending map[types.NamespacedName]struct{}
+	// removing holds the Ingresses that want their listeners off the Gateway.
+	removing map[types.NamespacedName]struct{}
+	// owners holds the UID of the Ingresses in desired or removing, after
+	// which their listeners are named.
+	owners map[types.NamespacedName]types.UID
+	// errs holds the error of the last write covering an Ingress.
+	errs map[types.NamespacedName]error
+
+	// defaults holds the default listeners configured for the Gateway, if
+	// hasDefaults is set. Other default listeners are pruned from it.
+	defaults    []*gatewayapi.Listener
+	hasDefaults bool
+}
+
+func newListenerAggregator(logger *zap.SugaredLogger, gwapiclient gatewayclientset.Interface,
+	gatewayLister gatewaylisters.GatewayLister, enqueueIngress func(types.NamespacedName)) *listenerAggregator {
+	return &listenerAggregator{
+		logger:         logger,
+		gwapiclient:    gwapiclient,
+		gatewayLister:  gatewayLister,
+		enqueueIngress: enqueueIngress,
+		queue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "GatewayListeners"),
+		gateways:       make(map[types.NamespacedName]*gatewayListeners),
+	}
+}
+
+// Start processes the Gateway queue until the given channel is closed.
+func (a *listenerAggregator) Start(done <-chan struct{}) {
+	go func() {
+		<-done
+		a.queue.ShutDown()
+	}()
+	go func() {
+		for a.processNextGateway() {
+		}
+	}()
+}
+
+// set registers the listeners an Ingress wants on the given Gateway. Its
+// other listeners there, named after the given UID, are removed. It returns
+// whether they are already in place, and the error of the last write of
+// them, if that failed.
+func (a *listenerAggregator) set(ing, gwName types.NamespacedName, uid types.UID, listeners []*gatewayapi.Listener) (bool, error) {
+	if a.register(ing, gwName, uid, listeners) {
+		return true, nil
+	}
+	// The queue is added to without holding the lock, so that it may write
+	// right away.
+	a.queue.AddAfter(gwName, listenerBatchDelay)
+	return a.result(ing, gwName, func(gl *gatewayListeners) bool {
+		_, ok := gl.pending[ing]
+		return !ok
+	})
+}
+
+// register records the listeners an Ingress wants on the given Gateway, and
+// returns whether they are already in place.
+func (a *listenerAggregator) register(ing, gwName types.NamespacedName, uid types.UID, listeners []*gatewayapi.Listener) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gl := a.gatewayListeners(gwName)
+	gl.desired[ing] = listeners
+	gl.owners[ing] = uid
+	delete(gl.removing, ing)
+
+	if gw, err := a.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name); err == nil && listenersInPlace(gw, uid, listeners) {
+		delete(gl.pending, ing)
+		delete(gl.errs, ing)
+		return true
+	}
+	gl.pending[ing] = struct{}{}
+	return false
+}
+
+// remove drops the listeners of an Ingress from the given Gateway's state,
+// and has its listeners, named after the given UID, removed from the
+// Gateway. It returns whether they are gone, and the error of the last write
+// removing them, if that failed.
+func (a *listenerAggregator) remove(ing, gwName types.NamespacedName, uid types.UID) (bool, error) {
+	if done, err := a.registerRemoval(ing, gwName, uid); done || err != nil {
+		return done, err
+	}
+	a.queue.AddAfter(gwName, listenerBatchDelay)
+	return a.result(ing, gwName, func(gl *gatewayListeners) bool {
+		_, ok := gl.removing[ing]
+		return !ok
+	})
+}
+
+// registerRemoval records that an Ingress wants its listeners off the given
+// Gateway, and returns whether they are already gone.
+func (a *listenerAggregator) registerRemoval(ing, gwName types.NamespacedName, uid types.UID) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gl := a.gatewayListeners(gwName)
+	delete(gl.desired, ing)
+	delete(gl.pending, ing)
+
+	gw, err := a.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return false, err
+	}
+	if err != nil || listenersInPlace(gw, uid, nil) {
+		// Nothing to clean up, all done!
+		delete(gl.removing, ing)
+		delete(gl.owners, ing)
+		delete(gl.errs, ing)
+		a.gcLocked(gwName, gl)
+		return true, nil
+	}
+	gl.removing[ing] = struct{}{}
+	gl.owners[ing] = uid
+	return false, nil
+}
+
+// result returns whether the write of an Ingress to the given Gateway is
+// done, and the error of the last attempt at it.
+func (a *listenerAggregator) result(ing, gwName types.NamespacedName, done func(*gatewayListeners) bool) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gl, ok := a.gateways[gwName]
+	if !ok {
+		return true, nil
+	}
+	if done(gl) {
+		return true, nil
+	}
+	return false, gl.errs[ing]
+}
+
+// retain drops the listeners of an Ingress from all Gateways but the given
+// ones, e.g. when it was placed on another Gateway of the pool before its
+// listeners got written.
+func (a *listenerAggregator) retain(ing types.NamespacedName, gateways []types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keep := make(map[types.NamespacedName]struct{}, len(gateways))
+	for _, gwName := range gateways {
+		keep[gwName] = struct{}{}
+	}
+	for gwName, gl := range a.gateways {
+		if _, ok := keep[gwName]; !ok {
+			a.forgetLocked(ing, gwName, gl)
+		}
+	}
+}
+
+// forgetLocked drops the listeners of an Ingress from the given Gateway's
+// state. It doesn't remove them from the Gateway itself.
+func (a *listenerAggregator) forgetLocked(ing, gwName types.NamespacedName, gl *gatewayListeners) {
+	delete(gl.desired, ing)
+	delete(gl.pending, ing)
+	if _, ok := gl.removing[ing]; !ok {
+		delete(gl.owners, ing)
+		delete(gl.errs, ing)
+	}
+	a.gcLocked(gwName, gl)
+}
+
+// gcLocked drops the state of a Gateway once there is nothing left to write.
+func (a *listenerAggregator) gcLocked(gwName types.NamespacedName, gl *gatewayListeners) {
+	if len(gl.desired) == 0 && len(gl.removing) == 0 && !gl.hasDefaults {
+		delete(a.gateways, gwName)
+	}
+}
+
+// setDefaults registers the default listeners configured for the given
+// Gateway. Default listeners that are no longer configured get removed.
+func (a *listenerAggregator) setDefaults(gwName types.NamespacedName, listeners []*gatewayapi.Listener) {
+	if a.registerDefaults(gwName, listeners) {
+		return
+	}
+	a.queue.AddAfter(gwName, listenerBatchDelay)
+}
+
+// registerDefaults records the default listeners of the given Gateway, and
+// returns whether they are already in place.
+func (a *listenerAggregator) registerDefaults(gwName types.NamespacedName, listeners []*gatewayapi.Listener) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gl := a.gatewayListeners(gwName)
+	gl.defaults = listeners
+	gl.hasDefaults = true
+
+	gw, err := a.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+	return err == nil && len(makeListenersPatch(gw, listeners, isDefaultListener)) == 0
+}
+
+// pendingLoad returns the number of listeners waiting to be written to the
+// given Gateway for Ingresses other than the given one.
+func (a *listenerAggregator) pendingLoad(gwName, except types.NamespacedName) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	gl, ok := a.gateways[gwName]
+	if !ok {
+		return 0
+	}
+	load := 0
+	for ing := range gl.pending {
+		if ing != except {
+			load += len(gl.desired[ing])
+		}
+	}
+	return load
+}
+
+// gatewayChanged enqueues the pending Ingresses whose listeners have made it
+// onto the given Gateway, and those whose listener has left it.
+func (a *listenerAggregator) gatewayChanged(obj interface{}) {
+	gw, ok := obj.(*gatewayapi.Gateway)
+	if !ok {
+		return
+	}
+
+	var ready []types.NamespacedName
+	func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		gwName := types.NamespacedName{Namespace: gw.Namespace, Name: gw.Name}
+		gl, ok := a.gateways[gwName]
+		if !ok {
+			return
+		}
+		for ing := range gl.pending {
+			if listenersInPlace(gw, gl.owners[ing], gl.desired[ing]) {
+				delete(gl.pending, ing)
+				delete(gl.errs, ing)
+				ready = append(ready, ing)
+			}
+		}
+		for ing := range gl.removing {
+			if listenersInPlace(gw, gl.owners[ing], nil) {
+				delete(gl.removing, ing)
+				delete(gl.owners, ing)
+				delete(gl.errs, ing)
+				ready = append(ready, ing)
+			}
+		}
+		a.gcLocked(gwName, gl)
+	}()
+
+	for _, ing := range ready {
+		a.enqueueIngress(ing)
+	}
+}
+
+func (a *listenerAggregator) gatewayListeners(gwName types.NamespacedName) *gatewayListeners {
+	gl, ok := a.gateways[gwName]
+	if !ok {
+		gl = &gatewayListeners{
+			desired:  make(map[types.NamespacedName][]*gatewayapi.Listener),
+			pending:  make(map[types.NamespacedName]struct{}),
+			removing: make(map[types.NamespacedName]struct{}),
+			owners:   make(map[types.NamespacedName]types.UID),
+			errs:     make(map[types.NamespacedName]error),
+		}
+		a.gateways[gwName] = gl
+	}
+	return gl
+}
+
+func (a *listenerAggregator) processNextGateway() bool {
+	item, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer a.queue.Done(item)
+
+	gwName := item.(types.NamespacedName)
+	if err := a.syncGateway(context.Background(), gwName); err != nil {
+		a.logger.Errorw("Failed to write Gateway listeners", zap.String("gateway", gwName.String()), zap.Error(err))
+		a.queue.AddRateLimited(item)
+		return true
+	}
+	a.queue.Forget(item)
+	return true
+}
+
+// syncGateway writes the listeners of all pending Ingresses to the Gateway,
+// and removes those they, or the Ingresses that no longer want any, don't
+// want, in a single patch.
+func (a *listenerAggregator) syncGateway(ctx context.Context, gwName types.NamespacedName) error {
+	var (
+		pending   []types.NamespacedName
+		listeners []*gatewayapi.Listener
+		owners    = make(map[types.UID]struct{})
+		prune     bool
+	)
+	func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		gl, ok := a.gateways[gwName]
+		if !ok {
+			return
+		}
+		for ing := range gl.pending {
+			pending = append(pending, ing)
+			listeners = append(listeners, gl.desired[ing]...)
+			owners[gl.owners[ing]] = struct{}{}
+		}
+		for ing := range gl.removing {
+			pending = append(pending, ing)
+			owners[gl.owners[ing]] = struct{}{}
+		}
+		listeners = append(listeners, gl.defaults...)
+		prune = gl.hasDefaults
+	}()
+	if len(pending) == 0 && !prune {
+		return nil
+	}
+
+	// Read the Gateway from the API server rather than the lister, so that the
+	// patch is built on top of our previous batch.
+	gw, err := a.gwapiclient.GatewayV1beta1().Gateways(gwName.Namespace).Get(ctx, gwName.Name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		// The Ingresses report the missing Gateway themselves.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	patch := makeListenersPatch(gw, listeners, func(name gatewayapi.SectionName) bool {
+		if uid, ok := listenerOwner(name); ok {
+			_, owned := owners[uid]
+			return owned
+		}
+		return prune && isDefaultListener(name)
+	})
+	if len(patch) > 0 {
+		var patched *gatewayapi.Gateway
+		if patched, err = patchGatewayListeners(ctx, a.gwapiclient, gw, patch); err == nil {
+			for _, ing := range pending {
+				a.debug.appliedTo(ing, gatewayKind+" listeners", gwName.String(), gw.Spec.Listeners, patched.Spec.Listeners)
+			}
+		}
+	}
+
+	func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		gl, ok := a.gateways[gwName]
+		if !ok {
+			return
+		}
+		for _, ing := range pending {
+			_, adding := gl.pending[ing]
+			_, removing := gl.removing[ing]
+			if !adding && !removing {
+				continue
+			}
+			if err != nil {
+				gl.errs[ing] = err
+			} else {
+				delete(gl.errs, ing)
+			}
+		}
+	}()
+
+	if err != nil {
+		// Let the Ingresses report the failure.
+		for _, ing := range pending {
+			a.enqueueIngress(ing)
+		}
+		return err
+	}
+	if len(patch) == 0 {
+		// Everything is already in place, we're only waiting for the informer.
+		a.gatewayChanged(gw)
+	}
+	return nil
+}
+
+// makeListenersPatch returns the patch that puts the given listeners on the
+// Gateway, and removes the listeners not among them that prune, if set,
+// returns true for.
+// The Gateway is shared with the gateway implementation, other controllers
+// and operators, so we only touch the listeners we own.
+func makeListenersPatch(gw *gatewayapi.Gateway, listeners []*gatewayapi.Listener, prune func(gatewayapi.SectionName) bool) listenersPatch {
+	lmap := map[string]*gatewayapi.Listener{}
+	for _, l := range listeners {
+		lmap[string(l.Name)] = l
+	}
+	wanted := make(map[gatewayapi.SectionName]struct{}, len(lmap))
+	for name := range lmap {
+		wanted[gatewayapi.SectionName(name)] = struct{}{}
+	}
+
+	var patch listenersPatch
+	for i, l := range gw.Spec.Listeners {
+		l := l
+		desired, ok := lmap[string(l.Name)]
+		if !ok {
+			// This listener doesn't match any that we control.
+			continue
+		}
+		delete(lmap, string(l.Name))
+		if equality.Semantic.DeepEqual(&l, desired) {
+			// Already present and correct
+			continue
+		}
+		patch.replace(i, l.Name, desired)
+	}
+
+	if prune != nil {
+		// Removals go backwards, after the replacements, so that they don't
+		// shift the positions the other operations refer to.
+		for i := len(gw.Spec.Listeners) - 1; i >= 0; i-- {
+			name := gw.Spec.Listeners[i].Name
+			if _, ok := wanted[name]; !ok && prune(name) {
+				patch.remove(i, name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(lmap))
+	for name := range lmap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	added := make([]*gatewayapi.Listener, 0, len(names))
+	for _, name := range names {
+		// Add all remaining listeners
+		added = append(added, lmap[name])
+	}
+	patch.add(gw.ResourceVersion, added...)
+	return patch
+}
+
+// listenersInPlace returns whether all the given listeners are on the
+// Gateway, and no other listener of the Ingress of the given UID.
+func listenersInPlace(gw *gatewayapi.Gateway, uid types.UID, listeners []*gatewayapi.Listener) bool {
+	return len(makeListenersPatch(gw, listeners, func(name gatewayapi.SectionName) bool {
+		owner, ok := listenerOwner(name)
+		return ok && owner == uid
+	})) == 0
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	logtesting "knative.dev/pkg/logging/testing"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	fakegatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+)
+
+var (
+	gwKey   = types.NamespacedName{Namespace: testNamespace, Name: publicName}
+	ingKeyA = types.NamespacedName{Namespace: "ns", Name: "a"}
+	ingKeyB = types.NamespacedName{Namespace: "ns", Name: "b"}
+)
+
+func TestListenerAggregatorBatchesWrites(t *testing.T) {
+	ctx := context.Background()
+	agg, client, enqueued := newTestAggregator(t, gw(defaultListener))
+	agg.debug = newIngressDebugger()
+	agg.debug.reconciling(&v1alpha1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: ingKeyA.Namespace, Name: ingKeyA.Name}})
+
+	listenerA, listenerB := testListener("kni-a", "a.example.com"), testListener("kni-b", "b.example.com")
+	if ready, err := agg.set(ingKeyA, gwKey, "a", []*gatewayapi.Listener{listenerA}); ready || err != nil {
+		t.Fatalf("set(a) = %v, %v, want: false, nil", ready, err)
+	}
+	if ready, err := agg.set(ingKeyB, gwKey, "b", []*gatewayapi.Listener{listenerB}); ready || err != nil {
+		t.Fatalf("set(b) = %v, %v, want: false, nil", ready, err)
+	}
+
+	if err := agg.syncGateway(ctx, gwKey); err != nil {
+		t.Fatal("syncGateway() =", err)
+	}
+
+	patches := patchActions(client.Actions())
+	want := gwPatch(t, listenersPatch{
+		{Operation: "add", Path: "/spec/listeners/-", Value: listenerA},
+		{Operation: "add", Path: "/spec/listeners/-", Value: listenerB},
+	})
+	if len(patches) != 1 {
+		t.Fatalf("Got %d patches, want: 1", len(patches))
+	}
+	if got := string(patches[0].GetPatch()); got != string(want.Patch) {
+		t.Errorf("Patch = %s, want: %s", got, want.Patch)
+	}
+	if got := agg.debug.states[ingKeyA].LastDiff; got == nil || got.Name != gwKey.String() || got.Diff == "" {
+		t.Errorf("LastDiff = %v, want the patch of %s", got, gwKey)
+	}
+
+	// Nothing is enqueued until the Gateway informer sees the listeners.
+	if len(*enqueued) != 0 {
+		t.Errorf("Enqueued = %v, want: none", *enqueued)
+	}
+	updated, err := client.GatewayV1beta1().Gateways(gwKey.Namespace).Get(ctx, gwKey.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get Gateway:", err)
+	}
+	agg.gatewayChanged(updated)
+	if got, want := len(*enqueued), 2; got != want {
+		t.Errorf("Enqueued %d Ingresses, want: %d", got, want)
+	}
+
+	// A second batch has nothing left to write.
+	if err := agg.syncGateway(ctx, gwKey); err != nil {
+		t.Fatal("syncGateway() =", err)
+	}
+	if got := len(patchActions(client.Actions())); got != 1 {
+		t.Errorf("Got %d patches, want: 1", got)
+	}
+}
+
+func TestListenerAggregatorReportsErrors(t *testing.T) {
+	ctx := context.Background()
+	agg, client, enqueued := newTestAggregator(t, gw(defaultListener))
+
+	theError := errors.New("conflict")
+	client.PrependReactor("patch", "gateways", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		return true, nil, theError
+	})
+
+	listeners := []*gatewayapi.Listener{testListener("kni-a", "a.example.com")}
+	agg.set(ingKeyA, gwKey, "a", listeners)
+
+	if err := agg.syncGateway(ctx, gwKey); !errors.Is(err, theError) {
+		t.Fatalf("syncGateway() = %v, want: %v", err, theError)
+	}
+	if diff := cmp.Diff([]types.NamespacedName{ingKeyA}, *enqueued); diff != "" {
+		t.Error("Enqueued (-want, +got):", diff)
+	}
+	if ready, err := agg.set(ingKeyA, gwKey, "a", listeners); ready || !errors.Is(err, theError) {
+		t.Errorf("set() = %v, %v, want: false, %v", ready, err, theError)
+	}
+
+	// Removing the listener, which never made it, drops its pending write.
+	if done, err := agg.remove(ingKeyA, gwKey, "a"); !done || err != nil {
+		t.Errorf("remove() = %v, %v, want: true, nil", done, err)
+	}
+	if err := agg.syncGateway(ctx, gwKey); err != nil {
+		t.Error("syncGateway() =", err)
+	}
+}
+
+func TestListenerAggregatorRemoves(t *testing.T) {
+	ctx := context.Background()
+	listener := testListener("kni-a", "a.example.com")
+	agg, client, enqueued := newTestAggregator(t, gw(defaultListener, func(g *gatewayapi.Gateway) {
+		g.Spec.Listeners = append(g.Spec.Listeners, *listener)
+	}))
+
+	if done, err := agg.remove(ingKeyA, gwKey, "a"); done || err != nil {
+		t.Fatalf("remove() = %v, %v, want: false, nil", done, err)
+	}
+	if err := agg.syncGateway(ctx, gwKey); err != nil {
+		t.Fatal("syncGateway() =", err)
+	}
+
+	patches := patchActions(client.Actions())
+	want := gwPatch(t, listenersPatch{
+		{Operation: "test", Path: "/spec/listeners/1/name", Value: listener.Name},
+		{Operation: "remove", Path: "/spec/listeners/1"},
+	})
+	if len(patches) != 1 {
+		t.Fatalf("Got %d patches, want: 1", len(patches))
+	}
+	if got := string(patches[0].GetPatch()); got != string(want.Patch) {
+		t.Errorf("Patch = %s, want: %s", got, want.Patch)
+	}
+
+	// The Ingress is enqueued once the Gateway informer sees the listener go.
+	updated, err := client.GatewayV1beta1().Gateways(gwKey.Namespace).Get(ctx, gwKey.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get Gateway:", err)
+	}
+	agg.gatewayChanged(updated)
+	if diff := cmp.Diff([]types.NamespacedName{ingKeyA}, *enqueued); diff != "" {
+		t.Error("Enqueued (-want, +got):", diff)
+	}
+	if _, ok := agg.gateways[gwKey]; ok {
+		t.Error("The state of the Gateway is kept, want it dropped")
+	}
+}
+
+func TestListenerAggregatorInSync(t *testing.T) {
+	listener := testListener("kni-a", "a.example.com")
+	agg, _, _ := newTestAggregator(t, gw(defaultListener, func(g *gatewayapi.Gateway) {
+		g.Spec.Listeners = append(g.Spec.Listeners, *listener)
+	}))
+
+	if ready, err := agg.set(ingKeyA, gwKey, "a", []*gatewayapi.Listener{listener}); !ready || err != nil {
+		t.Errorf("set() = %v, %v, want: true, nil", ready, err)
+	}
+	if got := agg.queue.Len(); got != 0 {
+		t.Errorf("Queue length = %d, want: 0", got)
+	}
+}
+
+func newTestAggregator(t *testing.T, g *gatewayapi.Gateway) (*listenerAggregator, *fakegatewayclientset.Clientset, *[]types.NamespacedName) {
+	t.Helper()
+
+	client := fakegatewayclientset.NewSimpleClientset()
+	// See createGateways for why the Gateway is created explicitly.
+	if _, err := client.GatewayV1beta1().Gateways(g.Namespace).Create(context.Background(), g, metav1.CreateOptions{}); err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+	listers := NewListers([]runtime.Object{g})
+
+	enqueued := &[]types.NamespacedName{}
+	agg := newListenerAggregator(logtesting.TestLogger(t), client, listers.GetGatewayLister(), func(ing types.NamespacedName) {
+		*enqueued = append(*enqueued, ing)
+	})
+	t.Cleanup(agg.queue.ShutDown)
+	return agg, client, enqueued
+}
+
+func TestListenersPatchGuardsAppend(t *testing.T) {
+	ctx := context.Background()
+	g := gw(defaultListener, func(g *gatewayapi.Gateway) {
+		g.ResourceVersion = "1"
+	})
+	client := fakegatewayclientset.NewSimpleClientset()
+	if _, err := client.GatewayV1beta1().Gateways(g.Namespace).Create(ctx, g, metav1.CreateOptions{}); err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	// Another writer appends its listener once we read the Gateway.
+	patch := makeListenersPatch(g, []*gatewayapi.Listener{testListener("kni-a", "a.example.com")}, nil)
+	concurrent := g.DeepCopy()
+	concurrent.Spec.Listeners = append(concurrent.Spec.Listeners, *testListener("kni-a", "a.example.com"))
+	concurrent.ResourceVersion = "2"
+	if _, err := client.GatewayV1beta1().Gateways(g.Namespace).Update(ctx, concurrent, metav1.UpdateOptions{}); err != nil {
+		t.Fatal("Failed to update Gateway:", err)
+	}
+
+	if _, err := patchGatewayListeners(ctx, client, g, patch); err == nil {
+		t.Error("patchGatewayListeners() = nil, want the append to fail its test")
+	}
+	got, err := client.GatewayV1beta1().Gateways(g.Namespace).Get(ctx, g.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get Gateway:", err)
+	}
+	if len(got.Spec.Listeners) != 2 {
+		t.Errorf("Listeners = %v, want no duplicate", got.Spec.Listeners)
+	}
+}
+
+func testListener(name, host string) *gatewayapi.Listener {
+	hostname := gatewayapi.Hostname(host)
+	return &gatewayapi.Listener{
+		Name:     gatewayapi.SectionName(name),
+		Hostname: &hostname,
+		Port:     443,
+		Protocol: gatewayapi.HTTPSProtocolType,
+	}
+}
+
+func patchActions(actions []clientgotesting.Action) []clientgotesting.PatchAction {
+	var patches []clientgotesting.PatchAction
+	for _, action := range actions {
+		if patch, ok := action.(clientgotesting.PatchAction); ok {
+			patches = append(patches, patch)
+		}
+	}
+	return patches
+}
@@ -25,10 +25,11 @@ import (
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/util/sets"
 	corev1listers "k8s.io/client-go/listers/core/v1"
-	"knative.dev/networking/pkg/apis/networking/v1alpha1"
-	"knative.dev/networking/pkg/status"
 
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkcfg "knative.dev/networking/pkg/config"
+	"knative.dev/networking/pkg/status"
 )
 
 func NewProbeTargetLister(logger *zap.SugaredLogger, endpointsLister corev1listers.EndpointsLister) status.ProbeTargetLister {
@@ -44,9 +45,15 @@ type gatewayPodTargetLister struct {
 }
 
 func (l *gatewayPodTargetLister) ListProbeTargets(ctx context.Context, ing *v1alpha1.Ingress) ([]status.ProbeTarget, error) {
+	gateways, err := gatewaysFor(ctx, ing)
+	if err != nil {
+		return nil, err
+	}
+
 	result := make([]status.ProbeTarget, 0, len(ing.Spec.Rules))
 	for _, rule := range ing.Spec.Rules {
-		eps, err := l.getRuleProbes(ctx, rule, ing.Spec.HTTPOption)
+		gw := gateways[rule.Visibility]
+		eps, err := l.getRuleProbes(ctx, rule, &gw, ing.Spec.HTTPOption, httpProtocol(ctx, ing))
 		if err != nil {
 			return nil, err
 		}
@@ -55,10 +62,12 @@ func (l *gatewayPodTargetLister) ListProbeTargets(ctx context.Context, ing *v1al
 	return result, nil
 }
 
-func (l *gatewayPodTargetLister) getRuleProbes(ctx context.Context, rule v1alpha1.IngressRule, sslOpt v1alpha1.HTTPOption) ([]status.ProbeTarget, error) {
-	gatewayConfig := config.FromContext(ctx).Gateway
-	service := gatewayConfig.Gateways[rule.Visibility].Service
-
+func (l *gatewayPodTargetLister) getRuleProbes(ctx context.Context, rule v1alpha1.IngressRule, gw *config.GatewayConfig,
+	sslOpt v1alpha1.HTTPOption, protocol networkcfg.HTTPProtocol) ([]status.ProbeTarget, error) {
+	// The Gateways of a profile may be of another provider, naming their
+	// ports otherwise.
+	provider := config.FromContext(ctx).Gateway.ProviderOf(gw)
+	service := gw.Service
 	eps, err := l.endpointsLister.Endpoints(service.Namespace).Get(service.Name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get endpoints: %w", err)
@@ -68,12 +77,12 @@ func (l *gatewayPodTargetLister) getRuleProbes(ctx context.Context, rule v1alpha
 	foundTargets := 0
 	for _, sub := range eps.Subsets {
 		scheme := "http"
-		// Istio uses "http2" for the http port
-		matchSchemes := sets.NewString("http", "http2")
-		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP && sslOpt == v1alpha1.HTTPOptionRedirected {
+		// Without plain HTTP, external hosts can only be probed over HTTPS.
+		if rule.Visibility == v1alpha1.IngressVisibilityExternalIP &&
+			(sslOpt == v1alpha1.HTTPOptionRedirected || protocol == networkcfg.HTTPDisabled) {
 			scheme = "https"
-			matchSchemes = sets.NewString("https")
 		}
+		matchSchemes := sets.NewString(provider.PortNames(scheme)...)
 		pt := status.ProbeTarget{PodIPs: sets.NewString()}
 
 		portNumber := sub.Ports[0].Port
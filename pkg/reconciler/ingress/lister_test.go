@@ -33,6 +33,8 @@ import (
 	"knative.dev/networking/pkg/status"
 	"knative.dev/pkg/kmeta"
 
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+
 	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
 )
 
@@ -44,11 +46,14 @@ var (
 
 func TestListProbeTargets(t *testing.T) {
 	tests := []struct {
-		name    string
-		ing     *v1alpha1.Ingress
-		objects []runtime.Object
-		want    []status.ProbeTarget
-		wantErr error
+		name     string
+		ing      *v1alpha1.Ingress
+		provider string
+		// gatewayProvider serves the Gateways, in place of provider.
+		gatewayProvider string
+		objects         []runtime.Object
+		want            []status.ProbeTarget
+		wantErr         error
 	}{{
 		name: "single address to probe",
 		objects: []runtime.Object{
@@ -81,6 +86,14 @@ func TestListProbeTargets(t *testing.T) {
 		},
 		ing:     ing(withBasicSpec, withGatewayAPIClass),
 		wantErr: fmt.Errorf("failed to get endpoints: endpoints %q not found", "istio-gateway"),
+	}, {
+		name: "unknown gateway profile",
+		objects: []runtime.Object{
+			privateEndpointsOneAddr,
+			publicEndpointsOneAddr,
+		},
+		ing:     ing(withBasicSpec, withGatewayAPIClass, withProfile("nope")),
+		wantErr: fmt.Errorf(`gateway profile "nope" does not exist in config-gateway`),
 	}, {
 		name: "local endpoint without address to probe",
 		objects: []runtime.Object{
@@ -113,6 +126,74 @@ func TestListProbeTargets(t *testing.T) {
 				Path:   "/",
 			}},
 		}},
+	}, {
+		name: "endpoint with single address to probe (http disabled)",
+		objects: []runtime.Object{
+			privateEndpointsOneAddr,
+			publicSslEndpointsOneAddr,
+		},
+		ing: ing(withBasicSpec, withGatewayAPIClass, withAnnotation(map[string]string{
+			networking.HTTPProtocolAnnotationKey: "disabled",
+		})),
+		want: []status.ProbeTarget{{
+			PodIPs:  sets.NewString("1.2.3.4"),
+			PodPort: "8443",
+			URLs: []*url.URL{{
+				Scheme: "https",
+				Host:   "example.com",
+				Path:   "/",
+			}},
+		}},
+	}, {
+		name: "istio probes its http2 port",
+		objects: []runtime.Object{
+			privateEndpointsOneAddr,
+			publicEndpointsHTTP2,
+		},
+		ing: ing(withBasicSpec, withGatewayAPIClass),
+		want: []status.ProbeTarget{{
+			PodIPs:  sets.NewString("1.2.3.4"),
+			PodPort: "8082",
+			URLs: []*url.URL{{
+				Scheme: "http",
+				Host:   "example.com",
+				Path:   "/",
+			}},
+		}},
+	}, {
+		name:     "contour probes its http port",
+		provider: config.ProviderContour,
+		objects: []runtime.Object{
+			privateEndpointsOneAddr,
+			publicEndpointsHTTP2,
+		},
+		ing: ing(withBasicSpec, withGatewayAPIClass),
+		want: []status.ProbeTarget{{
+			PodIPs:  sets.NewString("1.2.3.4"),
+			PodPort: "8080",
+			URLs: []*url.URL{{
+				Scheme: "http",
+				Host:   "example.com",
+				Path:   "/",
+			}},
+		}},
+	}, {
+		name:            "gateways of another provider probe its http port",
+		gatewayProvider: config.ProviderContour,
+		objects: []runtime.Object{
+			privateEndpointsOneAddr,
+			publicEndpointsHTTP2,
+		},
+		ing: ing(withBasicSpec, withGatewayAPIClass),
+		want: []status.ProbeTarget{{
+			PodIPs:  sets.NewString("1.2.3.4"),
+			PodPort: "8080",
+			URLs: []*url.URL{{
+				Scheme: "http",
+				Host:   "example.com",
+				Path:   "/",
+			}},
+		}},
 	}, {
 		name: "endpoint with multiple addresses and subsets to probe",
 		objects: []runtime.Object{
@@ -149,6 +230,15 @@ func TestListProbeTargets(t *testing.T) {
 			}
 
 			cfg := defaultConfig.DeepCopy()
+			if test.provider != "" {
+				cfg.Gateway.Provider, _ = config.LookupProvider(test.provider)
+			}
+			if test.gatewayProvider != "" {
+				for vis, gw := range cfg.Gateway.Gateways {
+					gw.Provider, _ = config.LookupProvider(test.gatewayProvider)
+					cfg.Gateway.Gateways[vis] = gw
+				}
+			}
 			ctx := (&testConfigStore{config: cfg}).ToContext(context.Background())
 
 			got, gotErr := l.ListProbeTargets(ctx, test.ing)
@@ -198,6 +288,28 @@ var (
 		}},
 	}
 
+	publicEndpointsHTTP2 = &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      publicName,
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Ports: []corev1.EndpointPort{{
+				Name: "status",
+				Port: 15021,
+			}, {
+				Name: "http2",
+				Port: 8082,
+			}, {
+				Name: "http",
+				Port: 8080,
+			}},
+			Addresses: []corev1.EndpointAddress{{
+				IP: "1.2.3.4",
+			}},
+		}},
+	}
+
 	publicSslEndpointsOneAddr = &corev1.Endpoints{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: testNamespace,
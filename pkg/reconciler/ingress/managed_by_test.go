@@ -0,0 +1,175 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewaylistersalpha "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha2"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileUnlabeledObjects(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	ours := func(opts ...IngressOption) *v1alpha1.Ingress {
+		return ing(append([]IngressOption{withBasicSpec, withGatewayAPIClass, withUID("ours"), createdAt(now)}, opts...)...)
+	}
+	other := ing(withBasicSpec, withGatewayAPIClass, withUID("other"), createdAt(now.Add(-time.Hour)), func(i *v1alpha1.Ingress) {
+		i.Namespace = "other-ns"
+		i.Name = "other"
+	})
+
+	table := TableTest{{
+		Name: "unlabeled HTTPRoute is adopted",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withFinalizer),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass), withoutManagedByLabel),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withFinalizer, readyStatus),
+		}},
+	}, {
+		Name: "losing Ingress removes its unlabeled HTTPRoute",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ours(withFinalizer),
+			httpRoute(t, ours(), withoutManagedByLabel),
+			other,
+			httpRoute(t, other),
+		}, servicesAndEndpoints...),
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ours(withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(hostnameConflictReason, `Hostname "example.com" is already in use by Ingress other-ns/other`)
+			}),
+		}},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		r := newTestReconciler(ctx, listers)
+		r.httprouteLister = gatewaylisters.NewHTTPRouteLister(managedOnly(listers, &gatewayapi.HTTPRoute{}))
+		r.referenceGrantLister = gatewaylistersalpha.NewReferenceGrantLister(managedOnly(listers, &gatewayv1alpha2.ReferenceGrant{}))
+		return newTestIngressReconciler(ctx, listers, r, defaultConfig)
+	}))
+}
+
+func TestClassChangeUnlabeledObjects(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+	withOtherClass := withAnnotation(map[string]string{
+		networking.IngressClassAnnotationKey: "fake-controller",
+	})
+	unlabeledGrant := rp(secret(secretName, nsName))
+	delete(unlabeledGrant.Labels, resources.ManagedByLabelKey)
+
+	table := TableTest{{
+		Name:                    "ingress moved to another class removes its unlabeled resources",
+		Key:                     "ns/name",
+		SkipNamespaceValidation: true,
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withOtherClass, withTLS(secretName)),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), withoutManagedByLabel),
+			unlabeledGrant,
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: nsName,
+				Verb:      "delete",
+				Resource:  gatewayv1alpha2.SchemeGroupVersion.WithResource("referencegrants"),
+			},
+			Name: secretName + "-" + testNamespace,
+		}, {
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: nsName,
+				Verb:      "delete",
+				Resource:  gatewayapi.SchemeGroupVersion.WithResource("httproutes"),
+			},
+			Name: "example.com",
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "ns",
+			},
+			Name:  "name",
+			Patch: []byte(`{"metadata":{"finalizers":[],"resourceVersion":""}}`),
+		}, gwPatch(t, listenersPatch{
+			{Operation: "test", Path: "/spec/listeners/1/name", Value: secureListenerName("secure.example.com")},
+			{Operation: "remove", Path: "/spec/listeners/1"},
+		})},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "name" finalizers`),
+		},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		r := newTestReconciler(ctx, listers)
+		r.httprouteLister = gatewaylisters.NewHTTPRouteLister(managedOnly(listers, &gatewayapi.HTTPRoute{}))
+		r.referenceGrantLister = gatewaylistersalpha.NewReferenceGrantLister(managedOnly(listers, &gatewayv1alpha2.ReferenceGrant{}))
+		createGateways(ctx, tr)
+		return newTestClassChangeReconciler(ctx, listers, r)
+	}))
+}
+
+// managedOnly returns an indexer holding the objects of the given type
+// carrying the managed-by label, like the filtered informers do.
+func managedOnly(listers *Listers, obj runtime.Object) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, o := range listers.IndexerFor(obj).List() {
+		if o.(metav1.Object).GetLabels()[resources.ManagedByLabelKey] == resources.ManagedByLabelValue {
+			indexer.Add(o)
+		}
+	}
+	return indexer
+}
+
+func withoutManagedByLabel(r *gatewayapi.HTTPRoute) {
+	delete(r.Labels, resources.ManagedByLabelKey)
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayalphalisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha2"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/status"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+const (
+	// managedObjectsInterval is how often the managed objects are counted.
+	managedObjectsInterval = 30 * time.Second
+
+	httpRouteKind      = "HTTPRoute"
+	referenceGrantKind = "ReferenceGrant"
+	gatewayKind        = "Gateway"
+	listenerKind       = "Listener"
+
+	createOperation   = "create"
+	updateOperation   = "update"
+	conflictOperation = "conflict"
+)
+
+var (
+	gatewayKey    = tag.MustNewKey("gateway")
+	visibilityKey = tag.MustNewKey("visibility")
+	kindKey       = tag.MustNewKey("kind")
+	operationKey  = tag.MustNewKey("operation")
+	resultKey     = tag.MustNewKey("result")
+
+	managedObjectsM = stats.Int64(
+		"managed_objects",
+		"The number of HTTPRoutes, ReferenceGrants and Listeners managed per Gateway and visibility",
+		stats.UnitDimensionless)
+	writeErrorsM = stats.Int64(
+		"write_errors",
+		"The number of failed creates and updates per resource kind, with conflicts counted apart",
+		stats.UnitDimensionless)
+	timeToReadyM = stats.Float64(
+		"time_to_ready",
+		"The time from a change of the generation of a KIngress to its load balancer being ready",
+		stats.UnitMilliseconds)
+	probesM = stats.Int64(
+		"probes",
+		"The number of KIngress readiness checks of the prober per result",
+		stats.UnitDimensionless)
+	probeLatencyM = stats.Float64(
+		"probe_latency",
+		"The time the prober took to find a KIngress generation ready",
+		stats.UnitMilliseconds)
+)
+
+func init() {
+	latencyBuckets := metrics.Buckets125(10, 1000000) // 10ms to ~17min.
+	if err := view.Register(&view.View{
+		Description: managedObjectsM.Description(),
+		Measure:     managedObjectsM,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{gatewayKey, visibilityKey, kindKey},
+	}, &view.View{
+		Description: writeErrorsM.Description(),
+		Measure:     writeErrorsM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{kindKey, operationKey},
+	}, &view.View{
+		Description: timeToReadyM.Description(),
+		Measure:     timeToReadyM,
+		Aggregation: view.Distribution(latencyBuckets...),
+	}, &view.View{
+		Description: probesM.Description(),
+		Measure:     probesM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{resultKey},
+	}, &view.View{
+		Description: probeLatencyM.Description(),
+		Measure:     probeLatencyM,
+		Aggregation: view.Distribution(latencyBuckets...),
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// recordWriteError counts a failed create or update of the given kind.
+// Conflicts are counted apart, as they only mean someone else got there first.
+func recordWriteError(ctx context.Context, kind, operation string, err error) {
+	if apierrs.IsConflict(err) {
+		operation = conflictOperation
+	}
+	metrics.Record(ctx, writeErrorsM.M(1), stats.WithTags(
+		tag.Upsert(kindKey, kind),
+		tag.Upsert(operationKey, operation)))
+}
+
+// managedObjectsReporter periodically counts the objects we manage on each
+// Gateway of config-gateway.
+type managedObjectsReporter struct {
+	configStore          pkgreconciler.ConfigStore
+	httprouteLister      gatewaylisters.HTTPRouteLister
+	referenceGrantLister gatewayalphalisters.ReferenceGrantLister
+	gatewayLister        gatewaylisters.GatewayLister
+
+	// reported are the series reported last time, which are reset to zero
+	// when they go away, rather than reporting their last value forever.
+	reported map[managedObjectsSeries]struct{}
+}
+
+type managedObjectsSeries struct {
+	gateway    types.NamespacedName
+	visibility v1alpha1.IngressVisibility
+	kind       string
+}
+
+// Start reports the managed objects until the context is done.
+func (r *managedObjectsReporter) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(managedObjectsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.report(r.configStore.ToContext(ctx)); err != nil {
+					logging.FromContext(ctx).Warnw("Failed to count the managed objects", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *managedObjectsReporter) report(ctx context.Context) error {
+	counts, err := r.count(ctx)
+	if err != nil {
+		return err
+	}
+	for series := range r.reported {
+		if _, ok := counts[series]; !ok {
+			counts[series] = 0
+		}
+	}
+
+	r.reported = make(map[managedObjectsSeries]struct{}, len(counts))
+	for series, count := range counts {
+		metrics.Record(ctx, managedObjectsM.M(count), stats.WithTags(
+			tag.Upsert(gatewayKey, series.gateway.String()),
+			tag.Upsert(visibilityKey, string(series.visibility)),
+			tag.Upsert(kindKey, series.kind)))
+		if count > 0 {
+			r.reported[series] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// count returns the number of HTTPRoutes attached to each Gateway, the
+// ReferenceGrants letting it reach Secrets and our listeners on it.
+func (r *managedObjectsReporter) count(ctx context.Context) (map[managedObjectsSeries]int64, error) {
+	routes, err := r.httprouteLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	grants, err := r.referenceGrantLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	routesPerGateway := make(map[types.NamespacedName]int64)
+	for _, route := range routes {
+		for _, ref := range route.Spec.ParentRefs {
+			if gwName, ok := parentGateway(ref, route.Namespace); ok {
+				routesPerGateway[gwName]++
+			}
+		}
+	}
+
+	// Our listeners tell which Gateways serve the TLS of each Ingress.
+	gatewayConfig := config.FromContext(ctx).Gateway
+	listenersPerGateway := make(map[types.NamespacedName]int64)
+	gatewaysOf := make(map[types.UID][]types.NamespacedName)
+	for _, visibility := range []v1alpha1.IngressVisibility{v1alpha1.IngressVisibilityExternalIP, v1alpha1.IngressVisibilityClusterLocal} {
+		for _, gwName := range gatewayConfig.AllGatewayPools(visibility) {
+			if _, ok := listenersPerGateway[gwName]; ok {
+				continue
+			}
+			listenersPerGateway[gwName] = 0
+			gw, err := r.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+			if apierrs.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			for _, l := range gw.Spec.Listeners {
+				if strings.HasPrefix(string(l.Name), listenerPrefix) {
+					listenersPerGateway[gwName]++
+				}
+				// An Ingress has a listener per TLS host, all on the same
+				// Gateway.
+				if uid, ok := listenerOwner(l.Name); ok {
+					if gws := gatewaysOf[uid]; len(gws) == 0 || gws[len(gws)-1] != gwName {
+						gatewaysOf[uid] = append(gws, gwName)
+					}
+				}
+			}
+		}
+	}
+
+	// A ReferenceGrant lets in every Gateway of a namespace, but it is only
+	// there for the Gateways carrying the listeners of the Ingress it was
+	// created for, so it is counted for those.
+	grantsPerGateway := make(map[types.NamespacedName]int64)
+	for _, grant := range grants {
+		owner := metav1.GetControllerOf(grant)
+		if owner == nil {
+			continue
+		}
+		for _, gwName := range gatewaysOf[owner.UID] {
+			for _, from := range grant.Spec.From {
+				if from.Kind == gatewayKind && string(from.Namespace) == gwName.Namespace {
+					grantsPerGateway[gwName]++
+					break
+				}
+			}
+		}
+	}
+
+	counts := make(map[managedObjectsSeries]int64)
+	for _, visibility := range []v1alpha1.IngressVisibility{v1alpha1.IngressVisibilityExternalIP, v1alpha1.IngressVisibilityClusterLocal} {
+		for _, gwName := range gatewayConfig.AllGatewayPools(visibility) {
+			series := func(kind string) managedObjectsSeries {
+				return managedObjectsSeries{gateway: gwName, visibility: visibility, kind: kind}
+			}
+			counts[series(httpRouteKind)] = routesPerGateway[gwName]
+			counts[series(referenceGrantKind)] = grantsPerGateway[gwName]
+			counts[series(listenerKind)] = listenersPerGateway[gwName]
+		}
+	}
+	return counts, nil
+}
+
+// readinessTracker measures the time it takes KIngress generations to
+// become ready. The zero value is ready to use.
+type readinessTracker struct {
+	mu    sync.Mutex
+	since map[types.NamespacedName]generationSince
+}
+
+type generationSince struct {
+	generation int64
+	time       time.Time
+}
+
+// start starts the clock for the generation of the KIngress, unless it's
+// running already.
+func (t *readinessTracker) start(ing *v1alpha1.Ingress, now time.Time) {
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if since, ok := t.since[key]; ok && since.generation == ing.Generation {
+		return
+	}
+	if t.since == nil {
+		t.since = make(map[types.NamespacedName]generationSince)
+	}
+	t.since[key] = generationSince{generation: ing.Generation, time: now}
+}
+
+// ready stops the clock of the KIngress, returning how long its generation
+// took to become ready. It returns false when the clock wasn't running.
+func (t *readinessTracker) ready(ing *v1alpha1.Ingress, now time.Time) (time.Duration, bool) {
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	since, ok := t.since[key]
+	if !ok || since.generation != ing.Generation {
+		return 0, false
+	}
+	delete(t.since, key)
+	return now.Sub(since.time), true
+}
+
+// forget drops the clock of a KIngress that went away.
+func (t *readinessTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.since, key)
+}
+
+// observeGeneration starts the clock for the time to ready of the KIngress,
+// unless its current generation is ready already. The controller only learns
+// of a new generation when it reconciles it, except for the first one, which
+// comes with the KIngress.
+func (c *Reconciler) observeGeneration(ing *v1alpha1.Ingress) {
+	if ing.Status.ObservedGeneration == ing.Generation &&
+		ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady).IsTrue() {
+		return
+	}
+	since := time.Now()
+	if ing.Generation == 1 && !ing.CreationTimestamp.IsZero() {
+		since = ing.CreationTimestamp.Time
+	}
+	c.readiness.start(ing, since)
+}
+
+// recordTimeToReady reports the time to ready of a KIngress whose load
+// balancer just became ready.
+func (c *Reconciler) recordTimeToReady(ctx context.Context, ing *v1alpha1.Ingress) {
+	if !ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady).IsTrue() {
+		return
+	}
+	if d, ok := c.readiness.ready(ing, time.Now()); ok {
+		metrics.Record(ctx, timeToReadyM.M(float64(d.Milliseconds())))
+	}
+}
+
+const (
+	probeReady    = "ready"
+	probeNotReady = "not_ready"
+	probeError    = "error"
+)
+
+// probeMetrics reports the results of the prober, and how long it takes to
+// find KIngress generations ready. The prober doesn't expose the requests it
+// sends, so these are per KIngress rather than per request.
+type probeMetrics struct {
+	status.Manager
+
+	probing readinessTracker
+}
+
+var _ status.Manager = (*probeMetrics)(nil)
+
+// IsReady implements status.Manager
+func (p *probeMetrics) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, error) {
+	now := time.Now()
+	ready, err := p.Manager.IsReady(ctx, ing)
+
+	result := probeReady
+	switch {
+	case err != nil:
+		result = probeError
+	case !ready:
+		result = probeNotReady
+		p.probing.start(ing, now)
+	default:
+		if d, ok := p.probing.ready(ing, now); ok {
+			metrics.Record(ctx, probeLatencyM.M(float64(d.Milliseconds())))
+		}
+	}
+	metrics.Record(ctx, probesM.M(1), stats.WithTags(tag.Upsert(resultKey, result)))
+	return ready, err
+}
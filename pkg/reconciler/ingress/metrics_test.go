@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+)
+
+func TestManagedObjectsCount(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	listers := NewListers([]runtime.Object{
+		gw(defaultListener, tlsListener("secure.example.com", "ns", secretName)),
+		httpRoute(t, ing(withBasicSpec, withGatewayAPIClass)),
+		rp(secret(secretName, "ns")),
+	})
+	r := &managedObjectsReporter{
+		httprouteLister:      listers.GetHTTPRouteLister(),
+		referenceGrantLister: listers.GetReferenceGrantLister(),
+		gatewayLister:        listers.GetGatewayLister(),
+	}
+
+	got, err := r.count(config.ToContext(context.Background(), defaultConfig))
+	if err != nil {
+		t.Fatal("count() =", err)
+	}
+
+	public := types.NamespacedName{Namespace: testNamespace, Name: publicName}
+	private := types.NamespacedName{Namespace: testNamespace, Name: privateName}
+	external, local := v1alpha1.IngressVisibilityExternalIP, v1alpha1.IngressVisibilityClusterLocal
+	want := map[managedObjectsSeries]int64{
+		{gateway: public, visibility: external, kind: httpRouteKind}:      1,
+		{gateway: public, visibility: external, kind: referenceGrantKind}: 1,
+		{gateway: public, visibility: external, kind: listenerKind}:       1,
+		{gateway: private, visibility: local, kind: httpRouteKind}:        0,
+		{gateway: private, visibility: local, kind: referenceGrantKind}:   0,
+		{gateway: private, visibility: local, kind: listenerKind}:         0,
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(managedObjectsSeries{})); diff != "" {
+		t.Error("count() (-want, +got):", diff)
+	}
+}
+
+func TestTimeToReady(t *testing.T) {
+	created := time.Now().Add(-time.Minute)
+	ingress := ing(withBasicSpec, func(i *v1alpha1.Ingress) {
+		i.Generation = 1
+		i.CreationTimestamp = metav1.NewTime(created)
+	})
+	c := &Reconciler{}
+
+	c.observeGeneration(ingress)
+	if _, ok := c.readiness.ready(ing(withBasicSpec, func(i *v1alpha1.Ingress) { i.Generation = 2 }), time.Now()); ok {
+		t.Error("ready() = true for a generation that wasn't observed")
+	}
+	if d, ok := c.readiness.ready(ingress, created.Add(time.Minute)); !ok || d != time.Minute {
+		t.Errorf("ready() = %v, %t, want: %v, true", d, ok, time.Minute)
+	}
+	if _, ok := c.readiness.ready(ingress, time.Now()); ok {
+		t.Error("ready() = true twice for the same generation")
+	}
+
+	ready := ingress.DeepCopy()
+	ready.Status.ObservedGeneration = ready.Generation
+	ready.Status.MarkLoadBalancerReady(nil, nil)
+	c.observeGeneration(ready)
+	if _, ok := c.readiness.ready(ready, time.Now()); ok {
+		t.Error("ready() = true for a generation that was ready already")
+	}
+}
+
+func TestProbeMetrics(t *testing.T) {
+	ready := false
+	p := &probeMetrics{Manager: &fakeStatusManager{FakeIsReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
+		return ready, nil
+	}}}
+	ingress := ing(withBasicSpec, func(i *v1alpha1.Ingress) { i.Generation = 3 })
+
+	if got, err := p.IsReady(context.Background(), ingress); err != nil || got {
+		t.Fatalf("IsReady() = %t, %v, want: false, nil", got, err)
+	}
+	ready = true
+	if got, err := p.IsReady(context.Background(), ingress); err != nil || !got {
+		t.Fatalf("IsReady() = %t, %v, want: true, nil", got, err)
+	}
+	if _, ok := p.probing.ready(ingress, time.Now()); ok {
+		t.Error("The probing clock still runs once the Ingress is ready")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// parentGateway returns the Gateway a ParentRef of an HTTPRoute in the given
+// namespace points at, or false if it doesn't point at a Gateway.
+func parentGateway(ref gatewayapi.ParentReference, namespace string) (types.NamespacedName, bool) {
+	if ref.Kind != nil && *ref.Kind != "Gateway" {
+		return types.NamespacedName{}, false
+	}
+	gw := types.NamespacedName{Namespace: namespace, Name: string(ref.Name)}
+	if ref.Namespace != nil {
+		gw.Namespace = string(*ref.Namespace)
+	}
+	return gw, true
+}
+
+// retiringParents returns the ParentRefs of the existing HTTPRoute of the rule
+// that point at other Gateways than the one it belongs on now. Gateways that
+// are gone are left out, there's nothing left to migrate off of them.
+func (c *Reconciler) retiringParents(ing *v1alpha1.Ingress, rule *v1alpha1.IngressRule, gwName types.NamespacedName) ([]gatewayapi.ParentReference, error) {
+	route, err := c.httprouteLister.HTTPRoutes(ing.Namespace).Get(resources.LongestHost(rule.Hosts))
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if !metav1.IsControlledBy(route, ing) {
+		// reconcileHTTPRoute reports these.
+		return nil, nil
+	}
+
+	var retiring []gatewayapi.ParentReference
+	for _, ref := range route.Spec.ParentRefs {
+		gw, ok := parentGateway(ref, route.Namespace)
+		if !ok || gw == gwName {
+			continue
+		}
+		if _, err := c.gatewayLister.Gateways(gw.Namespace).Get(gw.Name); apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		retiring = append(retiring, ref)
+	}
+	return retiring, nil
+}
+
+// parentAccepted returns whether the given Gateway accepted the HTTPRoute.
+func parentAccepted(route *gatewayapi.HTTPRoute, gwName types.NamespacedName) bool {
+	for _, parent := range route.Status.Parents {
+		if gw, ok := parentGateway(parent.ParentRef, route.Namespace); ok && gw == gwName && isGatewayAdmitted(parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationDone returns whether the HTTPRoutes of the Ingress can let go of
+// their retiring parents: the Gateways they belong on now accepted them, and
+// the Ingress probes ready. The given Gateways are those of the rules.
+func (c *Reconciler) migrationDone(ctx context.Context, ing *v1alpha1.Ingress, ruleGateways []types.NamespacedName) (bool, error) {
+	for i, rule := range ing.Spec.Rules {
+		route, err := c.httprouteLister.HTTPRoutes(ing.Namespace).Get(resources.LongestHost(rule.Hosts))
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !parentAccepted(route, ruleGateways[i]) {
+			return false, nil
+		}
+	}
+	return c.statusManager.IsReady(ctx, ing)
+}
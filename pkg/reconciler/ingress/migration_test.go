@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"encoding/json"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+var (
+	newGateway = types.NamespacedName{Namespace: testNamespace, Name: publicName}
+	oldGateway = types.NamespacedName{Namespace: testNamespace, Name: "old-gateway"}
+)
+
+func TestReconcileBlueGreenMigration(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+
+	cfg := defaultConfig.DeepCopy()
+	cfg.Gateway.Migration = config.MigrationBlueGreen
+
+	route := func(i *v1alpha1.Ingress, parents []types.NamespacedName, accepted ...types.NamespacedName) runtime.Object {
+		r := profileRoute(t, i, parents[0]).(*gatewayapi.HTTPRoute)
+		for _, gw := range parents[1:] {
+			r.Spec.ParentRefs = append(r.Spec.ParentRefs, parentRef(gw))
+		}
+		for _, gw := range accepted {
+			r.Status.Parents = append(r.Status.Parents, gatewayapi.RouteParentStatus{
+				ParentRef: parentRef(gw),
+				Conditions: []metav1.Condition{{
+					Type:   string(gatewayapi.RouteConditionAccepted),
+					Status: metav1.ConditionTrue,
+				}},
+			})
+		}
+		return r
+	}
+
+	runMigrationTest(t, cfg, TableTest{{
+		Name: "route attaches to both Gateways",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withFinalizer),
+			gw(defaultListener),
+			gw(named(oldGateway.Name), defaultListener),
+			route(ing(withBasicSpec, withGatewayAPIClass), []types.NamespacedName{oldGateway}, oldGateway),
+		}, servicesAndEndpoints...),
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route(ing(withBasicSpec, withGatewayAPIClass), []types.NamespacedName{newGateway, oldGateway}, oldGateway),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withFinalizer, makeItReady),
+		}},
+	}, {
+		Name: "listeners stay on the old Gateway",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			rp(secret(secretName, nsName)),
+			gw(defaultListener),
+			gw(named(oldGateway.Name), defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			route(ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), []types.NamespacedName{newGateway, oldGateway}, oldGateway),
+		}, servicesAndEndpoints...),
+		// The listeners go on the new Gateway too.
+		WantPatches: []clientgotesting.PatchActionImpl{gwPatch(t, listenersPatch{{
+			Operation: "add",
+			Path:      "/spec/listeners/-",
+			Value:     &gw(tlsListener("secure.example.com", nsName, secretName)).Spec.Listeners[0],
+		}})},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer, makeItReady),
+		}},
+	}, {
+		Name: "new Gateway took over",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			rp(secret(secretName, nsName)),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			gw(named(oldGateway.Name), defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			route(ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), []types.NamespacedName{newGateway, oldGateway}, oldGateway, newGateway),
+		}, servicesAndEndpoints...),
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route(ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), []types.NamespacedName{newGateway}, oldGateway, newGateway),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			namedGwPatch(t, oldGateway.Name, listenersPatch{
+				{Operation: "test", Path: "/spec/listeners/1/name", Value: secureListenerName("secure.example.com")},
+				{Operation: "remove", Path: "/spec/listeners/1"},
+			}),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer, makeItReady),
+		}},
+	}})
+
+	cfg = defaultConfig.DeepCopy()
+	cfg.Gateway.Migration = config.MigrationImmediate
+
+	runMigrationTest(t, cfg, TableTest{{
+		Name: "immediate migration",
+		Key:  "ns/name",
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			rp(secret(secretName, nsName)),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			gw(named(oldGateway.Name), defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			route(ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), []types.NamespacedName{oldGateway}, oldGateway),
+		}, servicesAndEndpoints...),
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: route(ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName)), []types.NamespacedName{newGateway}, oldGateway),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			namedGwPatch(t, oldGateway.Name, listenersPatch{
+				{Operation: "test", Path: "/spec/listeners/1/name", Value: secureListenerName("secure.example.com")},
+				{Operation: "remove", Path: "/spec/listeners/1"},
+			}),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer, makeItReady),
+		}},
+	}})
+}
+
+func runMigrationTest(t *testing.T, cfg *config.Config, table TableTest) {
+	t.Helper()
+	table.Test(t, reconcilerFactory(cfg))
+}
+
+func parentRef(gw types.NamespacedName) gatewayapi.ParentReference {
+	return gatewayapi.ParentReference{
+		Group:     (*gatewayapi.Group)(&gatewayapi.GroupVersion.Group),
+		Kind:      (*gatewayapi.Kind)(pointer.String("Gateway")),
+		Namespace: (*gatewayapi.Namespace)(pointer.String(gw.Namespace)),
+		Name:      gatewayapi.ObjectName(gw.Name),
+	}
+}
+
+func namedGwPatch(t *testing.T, name string, patch listenersPatch) clientgotesting.PatchActionImpl {
+	t.Helper()
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal("Failed to marshal Gateway patch:", err)
+	}
+	return clientgotesting.PatchActionImpl{
+		ActionImpl: clientgotesting.ActionImpl{
+			Namespace: testNamespace,
+		},
+		Name:  name,
+		Patch: data,
+	}
+}
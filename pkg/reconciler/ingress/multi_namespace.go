@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+type (
+	namespacedListFunc  func(namespace string, options metav1.ListOptions) (runtime.Object, error)
+	namespacedWatchFunc func(namespace string, options metav1.ListOptions) (watch.Interface, error)
+)
+
+// newMultiNamespaceInformer returns an informer of the objects of the given
+// namespaces, indexed by namespace like the generated informers.
+func newMultiNamespaceInformer(obj runtime.Object, resync time.Duration, namespaces []string,
+	list namespacedListFunc, watch namespacedWatchFunc, tweak func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&multiNamespaceListWatch{namespaces: namespaces, list: list, watch: watch, tweak: tweak},
+		obj, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// multiNamespaceListWatch lists and watches several namespaces as if they
+// were one. Each namespace is listed separately, and the resource version of
+// one list says nothing about when the others were taken, so it keeps track
+// of the resource version each namespace was last seen at itself and ignores
+// the single one the reflector hands back.
+type multiNamespaceListWatch struct {
+	namespaces []string
+	list       namespacedListFunc
+	watch      namespacedWatchFunc
+	tweak      func(*metav1.ListOptions)
+
+	mu               sync.Mutex
+	resourceVersions map[string]string
+}
+
+var _ cache.ListerWatcher = (*multiNamespaceListWatch)(nil)
+
+// List implements cache.Lister
+func (lw *multiNamespaceListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	// Listing from the watch cache is the only resource version that
+	// applies to every namespace. Paging is turned off, as continue tokens
+	// can't be merged either.
+	if options.ResourceVersion != "0" {
+		options.ResourceVersion = ""
+	}
+	options.ResourceVersionMatch = ""
+	options.Limit = 0
+	options.Continue = ""
+	if lw.tweak != nil {
+		lw.tweak(&options)
+	}
+
+	var merged runtime.Object
+	var items []runtime.Object
+	resourceVersions := make(map[string]string, len(lw.namespaces))
+	for _, ns := range lw.namespaces {
+		list, err := lw.list(ns, options)
+		if err != nil {
+			return nil, err
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, err
+		}
+		resourceVersions[ns] = listMeta.GetResourceVersion()
+		items = append(items, objs...)
+		if merged == nil {
+			merged = list
+		}
+	}
+	if err := meta.SetList(merged, items); err != nil {
+		return nil, err
+	}
+	listMeta, err := meta.ListAccessor(merged)
+	if err != nil {
+		return nil, err
+	}
+	listMeta.SetResourceVersion("")
+	listMeta.SetContinue("")
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.resourceVersions = resourceVersions
+	return merged, nil
+}
+
+// Watch implements cache.Watcher
+func (lw *multiNamespaceListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	if lw.tweak != nil {
+		lw.tweak(&options)
+	}
+
+	lw.mu.Lock()
+	resourceVersions := make(map[string]string, len(lw.resourceVersions))
+	for ns, rv := range lw.resourceVersions {
+		resourceVersions[ns] = rv
+	}
+	lw.mu.Unlock()
+
+	mw := &multiWatch{
+		result: make(chan watch.Event),
+		done:   make(chan struct{}),
+	}
+	watches := make(map[string]watch.Interface, len(lw.namespaces))
+	for _, ns := range lw.namespaces {
+		opts := options
+		opts.ResourceVersion = resourceVersions[ns]
+		w, err := lw.watch(ns, opts)
+		if err != nil {
+			for _, w := range watches {
+				w.Stop()
+			}
+			return nil, err
+		}
+		watches[ns] = w
+		mw.watches = append(mw.watches, w)
+	}
+
+	mw.wg.Add(len(watches))
+	for ns, w := range watches {
+		go mw.forward(w, func(obj runtime.Object) { lw.observe(ns, obj) })
+	}
+	go func() {
+		mw.wg.Wait()
+		close(mw.result)
+	}()
+	return mw, nil
+}
+
+// observe records the resource version of an object the reflector was
+// handed, so that the next watch of its namespace resumes after it.
+func (lw *multiNamespaceListWatch) observe(namespace string, obj runtime.Object) {
+	// Error events carry a Status, which has no object meta.
+	m, err := meta.Accessor(obj)
+	if err != nil || m.GetResourceVersion() == "" {
+		return
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.resourceVersions == nil {
+		lw.resourceVersions = make(map[string]string)
+	}
+	lw.resourceVersions[namespace] = m.GetResourceVersion()
+}
+
+// multiWatch merges the events of several watches. It ends as soon as any of
+// them does, so that the reflector starts over with all of them.
+type multiWatch struct {
+	watches []watch.Interface
+	result  chan watch.Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+var _ watch.Interface = (*multiWatch)(nil)
+
+// ResultChan implements watch.Interface
+func (w *multiWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// Stop implements watch.Interface
+func (w *multiWatch) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+		for _, x := range w.watches {
+			x.Stop()
+		}
+	})
+}
+
+func (w *multiWatch) forward(in watch.Interface, delivered func(runtime.Object)) {
+	defer w.wg.Done()
+	defer w.Stop()
+	for {
+		select {
+		case event, ok := <-in.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case w.result <- event:
+				delivered(event.Object)
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+
+	gatewayfactory "knative.dev/net-gateway-api/pkg/client/injection/informers/factory"
+	filteredFactory "knative.dev/net-gateway-api/pkg/client/injection/informers/factory/filtered"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingclientset "knative.dev/networking/pkg/client/clientset/versioned"
+	networkingfactory "knative.dev/networking/pkg/client/injection/informers/factory"
+	kubefactory "knative.dev/pkg/client/injection/kube/informers/factory"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/logging"
+)
+
+func init() {
+	// Informer factories are set up before the informers, so the informers
+	// the factories hand out afterwards are the ones registered here.
+	injection.Default.RegisterInformerFactory(withNamespaceScopedInformers)
+}
+
+// NamespaceScope limits the controller to the KIngresses of a set of
+// namespaces, so that it can run with Roles instead of ClusterRoles. The zero
+// value watches every namespace.
+type NamespaceScope struct {
+	// Ingresses are the namespaces of the KIngresses to reconcile, and so
+	// of their HTTPRoutes.
+	Ingresses []string
+	// Gateways are the namespaces of the Gateways of config-gateway, and of
+	// the Endpoints of their Services.
+	Gateways []string
+	// Secrets are the namespaces of the TLS Secrets of the KIngresses, and
+	// so of the ReferenceGrants giving the Gateways access to them.
+	Secrets []string
+}
+
+// ParseNamespaceScope parses comma separated lists of namespaces. Without
+// ingress namespaces every namespace is watched. The Gateway namespaces must
+// be given along with them, while the Secrets default to the ingress
+// namespaces.
+func ParseNamespaceScope(ingresses, gateways, secrets string) (NamespaceScope, error) {
+	var scope NamespaceScope
+	var err error
+	if scope.Ingresses, err = parseNamespaces(ingresses); err != nil {
+		return NamespaceScope{}, fmt.Errorf("invalid ingress namespaces: %w", err)
+	}
+	if scope.Gateways, err = parseNamespaces(gateways); err != nil {
+		return NamespaceScope{}, fmt.Errorf("invalid gateway namespaces: %w", err)
+	}
+	if scope.Secrets, err = parseNamespaces(secrets); err != nil {
+		return NamespaceScope{}, fmt.Errorf("invalid secret namespaces: %w", err)
+	}
+
+	switch {
+	case len(scope.Ingresses) == 0 && (len(scope.Gateways) > 0 || len(scope.Secrets) > 0):
+		return NamespaceScope{}, errors.New("gateway and secret namespaces are only used along with ingress namespaces")
+	case len(scope.Ingresses) == 0:
+		return NamespaceScope{}, nil
+	case len(scope.Gateways) == 0:
+		return NamespaceScope{}, errors.New("gateway namespaces are required along with ingress namespaces")
+	case len(scope.Secrets) == 0:
+		scope.Secrets = scope.Ingresses
+	}
+	return scope, nil
+}
+
+func parseNamespaces(s string) ([]string, error) {
+	namespaces := sets.NewString()
+	for _, ns := range strings.Split(s, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return nil, fmt.Errorf("%q: %s", ns, strings.Join(errs, ", "))
+		}
+		namespaces.Insert(ns)
+	}
+	if namespaces.Len() == 0 {
+		return nil, nil
+	}
+	return namespaces.List(), nil
+}
+
+// IsZero returns whether the scope watches every namespace.
+func (s NamespaceScope) IsZero() bool {
+	return len(s.Ingresses) == 0
+}
+
+// includesIngress returns whether KIngresses of the namespace are reconciled.
+func (s NamespaceScope) includesIngress(namespace string) bool {
+	if s.IsZero() {
+		return true
+	}
+	for _, ns := range s.Ingresses {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+type namespaceScopeKey struct{}
+
+// WithNamespaceScope limits the controller, and the informers it sets up, to
+// the namespaces of the given scope.
+func WithNamespaceScope(ctx context.Context, scope NamespaceScope) context.Context {
+	return context.WithValue(ctx, namespaceScopeKey{}, scope)
+}
+
+// namespaceScopeFromContext returns the namespace scope of the controller,
+// the zero value when it watches every namespace.
+func namespaceScopeFromContext(ctx context.Context) NamespaceScope {
+	scope, _ := ctx.Value(namespaceScopeKey{}).(NamespaceScope)
+	return scope
+}
+
+// withNamespaceScopedInformers registers informers limited to the namespaces
+// of the scope with the informer factories, which then hand those out in
+// place of their cluster wide ones.
+func withNamespaceScopedInformers(ctx context.Context) context.Context {
+	scope := namespaceScopeFromContext(ctx)
+	if scope.IsZero() {
+		return ctx
+	}
+	logging.FromContext(ctx).Infof("Only watching the KIngresses of namespaces %v, with Gateways in %v and Secrets in %v",
+		scope.Ingresses, scope.Gateways, scope.Secrets)
+
+	versions := apiVersionsFromContext(ctx)
+	managed := func(options *metav1.ListOptions) {
+		options.LabelSelector = resources.ManagedBySelector
+	}
+
+	networkingfactory.Get(ctx).InformerFor(&netv1alpha1.Ingress{}, func(client networkingclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newMultiNamespaceInformer(&netv1alpha1.Ingress{}, resync, scope.Ingresses,
+			func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1alpha1().Ingresses(ns).List(context.TODO(), options)
+			},
+			func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1alpha1().Ingresses(ns).Watch(context.TODO(), options)
+			}, nil)
+	})
+
+	kube := kubefactory.Get(ctx)
+	kube.InformerFor(&corev1.Endpoints{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newMultiNamespaceInformer(&corev1.Endpoints{}, resync, scope.Gateways,
+			func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Endpoints(ns).List(context.TODO(), options)
+			},
+			func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Endpoints(ns).Watch(context.TODO(), options)
+			}, nil)
+	})
+
+	gateways := gatewayfactory.Get(ctx)
+	if versions.Gateway == resources.V1alpha2 {
+		gateways.InformerFor(&gatewayv1alpha2.Gateway{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayv1alpha2.Gateway{}, resync, scope.Gateways,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1alpha2().Gateways(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1alpha2().Gateways(ns).Watch(context.TODO(), options)
+				}, nil)
+		})
+	} else {
+		gateways.InformerFor(&gatewayapi.Gateway{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayapi.Gateway{}, resync, scope.Gateways,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1beta1().Gateways(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1beta1().Gateways(ns).Watch(context.TODO(), options)
+				}, nil)
+		})
+	}
+
+	// Conflicts are looked for among all the HTTPRoutes of the ingress
+	// namespaces, not only those we created.
+	if versions.HTTPRoute == resources.V1alpha2 {
+		gateways.InformerFor(&gatewayv1alpha2.HTTPRoute{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayv1alpha2.HTTPRoute{}, resync, scope.Ingresses,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1alpha2().HTTPRoutes(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1alpha2().HTTPRoutes(ns).Watch(context.TODO(), options)
+				}, nil)
+		})
+	} else {
+		gateways.InformerFor(&gatewayapi.HTTPRoute{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayapi.HTTPRoute{}, resync, scope.Ingresses,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1beta1().HTTPRoutes(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1beta1().HTTPRoutes(ns).Watch(context.TODO(), options)
+				}, nil)
+		})
+	}
+
+	// The filtered factory only applies its label selector to the informers
+	// it creates, so ours apply it themselves.
+	filtered := filteredFactory.Get(ctx, resources.ManagedBySelector)
+	if versions.HTTPRoute == resources.V1alpha2 {
+		filtered.InformerFor(&gatewayv1alpha2.HTTPRoute{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayv1alpha2.HTTPRoute{}, resync, scope.Ingresses,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1alpha2().HTTPRoutes(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1alpha2().HTTPRoutes(ns).Watch(context.TODO(), options)
+				}, managed)
+		})
+	} else {
+		filtered.InformerFor(&gatewayapi.HTTPRoute{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayapi.HTTPRoute{}, resync, scope.Ingresses,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1beta1().HTTPRoutes(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1beta1().HTTPRoutes(ns).Watch(context.TODO(), options)
+				}, managed)
+		})
+	}
+	if versions.UsesReferencePolicies() {
+		filtered.InformerFor(&gatewayv1alpha2.ReferencePolicy{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayv1alpha2.ReferencePolicy{}, resync, scope.Secrets,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1alpha2().ReferencePolicies(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1alpha2().ReferencePolicies(ns).Watch(context.TODO(), options)
+				}, managed)
+		})
+	} else {
+		filtered.InformerFor(&gatewayv1alpha2.ReferenceGrant{}, func(client gatewayclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newMultiNamespaceInformer(&gatewayv1alpha2.ReferenceGrant{}, resync, scope.Secrets,
+				func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+					return client.GatewayV1alpha2().ReferenceGrants(ns).List(context.TODO(), options)
+				},
+				func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+					return client.GatewayV1alpha2().ReferenceGrants(ns).Watch(context.TODO(), options)
+				}, managed)
+		})
+	}
+
+	return ctx
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseNamespaceScope(t *testing.T) {
+	tests := []struct {
+		name                         string
+		ingresses, gateways, secrets string
+		want                         NamespaceScope
+		wantErr                      bool
+	}{{
+		name: "every namespace",
+	}, {
+		name:      "secrets default to the ingress namespaces",
+		ingresses: "team-b, team-a,team-b",
+		gateways:  "istio-system",
+		want: NamespaceScope{
+			Ingresses: []string{"team-a", "team-b"},
+			Gateways:  []string{"istio-system"},
+			Secrets:   []string{"team-a", "team-b"},
+		},
+	}, {
+		name:      "all namespaces",
+		ingresses: "team-a",
+		gateways:  "istio-system",
+		secrets:   "certs",
+		want: NamespaceScope{
+			Ingresses: []string{"team-a"},
+			Gateways:  []string{"istio-system"},
+			Secrets:   []string{"certs"},
+		},
+	}, {
+		name:      "no gateway namespaces",
+		ingresses: "team-a",
+		wantErr:   true,
+	}, {
+		name:     "gateway namespaces alone",
+		gateways: "istio-system",
+		wantErr:  true,
+	}, {
+		name:      "invalid namespace",
+		ingresses: "Team_A",
+		gateways:  "istio-system",
+		wantErr:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseNamespaceScope(test.ingresses, test.gateways, test.secrets)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseNamespaceScope() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error("ParseNamespaceScope() (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestMultiNamespaceListWatch(t *testing.T) {
+	ctx := context.Background()
+	secret := func(ns, name string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	client := fakekubeclientset.NewSimpleClientset(
+		secret("team-a", "a"),
+		secret("team-b", "b"),
+		secret("team-c", "c"),
+	)
+
+	lw := &multiNamespaceListWatch{
+		namespaces: []string{"team-a", "team-b"},
+		list: func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Secrets(ns).List(ctx, options)
+		},
+		watch: func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Secrets(ns).Watch(ctx, options)
+		},
+	}
+
+	list, err := lw.List(metav1.ListOptions{ResourceVersion: "0", Limit: 500})
+	if err != nil {
+		t.Fatal("List() =", err)
+	}
+	var got []string
+	for _, s := range list.(*corev1.SecretList).Items {
+		got = append(got, s.Namespace+"/"+s.Name)
+	}
+	if want := []string{"team-a/a", "team-b/b"}; !cmp.Equal(want, got) {
+		t.Errorf("List() = %v, want: %v", got, want)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal("Watch() =", err)
+	}
+	defer w.Stop()
+
+	for _, s := range []*corev1.Secret{secret("team-c", "c2"), secret("team-b", "b2")} {
+		if _, err := client.CoreV1().Secrets(s.Namespace).Create(ctx, s, metav1.CreateOptions{}); err != nil {
+			t.Fatal("Create() =", err)
+		}
+	}
+
+	select {
+	case event := <-w.ResultChan():
+		if s := event.Object.(*corev1.Secret); s.Namespace != "team-b" || s.Name != "b2" {
+			t.Errorf("Watch() got %s/%s, want: team-b/b2", s.Namespace, s.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the watch event")
+	}
+
+	w.Stop()
+	for range w.ResultChan() {
+		// Drained until closed, which Stop must lead to.
+	}
+}
@@ -18,92 +18,178 @@ package ingress
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"go.opencensus.io/trace"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/pointer"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 
-	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
 	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
 	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/pkg/controller"
 )
 
-const listenerPrefix = "kni-"
+const (
+	listenerPrefix = "kni-"
 
-// reconcileHTTPRoute reconciles HTTPRoute.
+	// listenerHashLength is the number of hex digits of the hash of the host
+	// that ends the name of a TLS listener.
+	listenerHashLength = 16
+
+	// maxGatewayListeners is the number of listeners Gateway API allows on a
+	// single Gateway.
+	maxGatewayListeners = 64
+)
+
+// reconcileHTTPRoute reconciles the desired HTTPRoute, attached to the given
+// Gateway. It stays attached to the retiring parents too, if any.
 func (c *Reconciler) reconcileHTTPRoute(
-	ctx context.Context, ing *netv1alpha1.Ingress,
-	rule *netv1alpha1.IngressRule,
-) (*gatewayapi.HTTPRoute, error) {
+	ctx context.Context, ing *netv1alpha1.Ingress, desired *gatewayapi.HTTPRoute,
+	gwName types.NamespacedName, retiring []gatewayapi.ParentReference,
+) (_ *gatewayapi.HTTPRoute, err error) {
+	ctx, span := trace.StartSpan(ctx, "reconcileHTTPRoute")
+	defer func() { endSpan(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("httproute", desired.Name),
+		trace.StringAttribute("gateway", gwName.String()))
 	recorder := controller.GetEventRecorder(ctx)
 
-	httproute, err := c.httprouteLister.HTTPRoutes(ing.Namespace).Get(resources.LongestHost(rule.Hosts))
+	desired.Spec.ParentRefs = append(desired.Spec.ParentRefs, retiring...)
+	c.debug.desiredHTTPRoute(ing, desired)
+
+	httproute, err := c.httprouteLister.HTTPRoutes(desired.Namespace).Get(desired.Name)
 	if apierrs.IsNotFound(err) {
-		desired, err := resources.MakeHTTPRoute(ctx, ing, rule)
-		if err != nil {
-			return nil, err
+		created, err := c.gwapiclient.GatewayV1beta1().HTTPRoutes(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if !apierrs.IsAlreadyExists(err) {
+			if err != nil {
+				recordWriteError(ctx, httpRouteKind, createOperation, err)
+				recorder.Eventf(ing, corev1.EventTypeWarning, "CreationFailed", "Failed to create HTTPRoute: %v", err)
+				return nil, fmt.Errorf("failed to create HTTPRoute: %w", err)
+			}
+
+			c.debug.applied(ing, httpRouteKind, created.Name, nil, created)
+			recorder.Eventf(ing, corev1.EventTypeNormal, "Created", "Created HTTPRoute %q", created.GetName())
+			return created, nil
 		}
-		httproute, err = c.gwapiclient.GatewayV1beta1().HTTPRoutes(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
-		if err != nil {
-			recorder.Eventf(ing, corev1.EventTypeWarning, "CreationFailed", "Failed to create HTTPRoute: %v", err)
-			return nil, fmt.Errorf("failed to create HTTPRoute: %w", err)
+		// HTTPRoutes created before they were labeled as managed by us are
+		// invisible to the informer. Fetch it, so that it gets labeled below.
+		if httproute, err = c.gwapiclient.GatewayV1beta1().HTTPRoutes(desired.Namespace).Get(ctx, desired.Name, metav1.GetOptions{}); err != nil {
+			return nil, err
 		}
-
-		recorder.Eventf(ing, corev1.EventTypeNormal, "Created", "Created HTTPRoute %q", httproute.GetName())
-		return httproute, nil
 	} else if err != nil {
 		return nil, err
-	} else {
-		desired, err := resources.MakeHTTPRoute(ctx, ing, rule)
-		if err != nil {
-			return nil, err
-		}
+	}
 
-		if !equality.Semantic.DeepEqual(httproute.Spec, desired.Spec) ||
-			!equality.Semantic.DeepEqual(httproute.Annotations, desired.Annotations) ||
-			!equality.Semantic.DeepEqual(httproute.Labels, desired.Labels) {
+	if !metav1.IsControlledBy(httproute, ing) {
+		recorder.Eventf(ing, corev1.EventTypeWarning, "NotOwned", "HTTPRoute %s not owned by this object", httproute.Name)
+		return nil, fmt.Errorf("HTTPRoute %s not owned by %s", httproute.Name, ing.Name)
+	}
 
-			// Don't modify the informers copy.
-			origin := httproute.DeepCopy()
-			origin.Spec = desired.Spec
-			origin.Annotations = desired.Annotations
-			origin.Labels = desired.Labels
+	if !equality.Semantic.DeepEqual(httproute.Spec, desired.Spec) ||
+		!equality.Semantic.DeepEqual(httproute.Annotations, desired.Annotations) ||
+		!equality.Semantic.DeepEqual(httproute.Labels, desired.Labels) {
 
-			updated, err := c.gwapiclient.GatewayV1beta1().HTTPRoutes(origin.Namespace).Update(
-				ctx, origin, metav1.UpdateOptions{})
-			if err != nil {
-				recorder.Eventf(ing, corev1.EventTypeWarning, "UpdateFailed", "Failed to update HTTPRoute: %v", err)
-				return nil, fmt.Errorf("failed to update HTTPRoute: %w", err)
-			}
-			return updated, nil
+		// Don't modify the informers copy.
+		origin := httproute.DeepCopy()
+		origin.Spec = desired.Spec
+		origin.Annotations = desired.Annotations
+		origin.Labels = desired.Labels
+
+		updated, err := c.gwapiclient.GatewayV1beta1().HTTPRoutes(origin.Namespace).Update(
+			ctx, origin, metav1.UpdateOptions{})
+		if err != nil {
+			recordWriteError(ctx, httpRouteKind, updateOperation, err)
+			recorder.Eventf(ing, corev1.EventTypeWarning, "UpdateFailed", "Failed to update HTTPRoute: %v", err)
+			return nil, fmt.Errorf("failed to update HTTPRoute: %w", err)
 		}
+		c.debug.applied(ing, httpRouteKind, updated.Name, httproute, updated)
+		return updated, nil
 	}
 
-	return httproute, err
+	return httproute, nil
 }
 
+// reconcileTLS reconciles the desired ReferenceGrant, which lets the given
+// Gateway use the Secret of the TLS section.
 func (c *Reconciler) reconcileTLS(
 	ctx context.Context, tls *netv1alpha1.IngressTLS, ing *netv1alpha1.Ingress,
-) (
-	[]*gatewayapi.Listener, error) {
+	gwName types.NamespacedName, desired *gatewayv1alpha2.ReferenceGrant,
+) (err error) {
+	ctx, span := trace.StartSpan(ctx, "reconcileTLS")
+	defer func() { endSpan(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("secret", tls.SecretNamespace+"/"+tls.SecretName),
+		trace.StringAttribute("gateway", gwName.String()))
 	recorder := controller.GetEventRecorder(ctx)
-	gatewayConfig := config.FromContext(ctx).Gateway.Gateways
-	externalGw := gatewayConfig[netv1alpha1.IngressVisibilityExternalIP]
 
+	rp, err := c.referenceGrantLister.ReferenceGrants(desired.Namespace).Get(desired.Name)
+
+	if apierrs.IsNotFound(err) {
+		rp, err = c.gwapiclient.GatewayV1alpha2().ReferenceGrants(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+		if err == nil {
+			c.debug.applied(ing, referenceGrantKind, rp.Name, nil, rp)
+		} else if apierrs.IsAlreadyExists(err) {
+			// Like HTTPRoutes, ReferenceGrants created before they were
+			// labeled are invisible to the informer.
+			rp, err = c.gwapiclient.GatewayV1alpha2().ReferenceGrants(desired.Namespace).Get(ctx, desired.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+		} else {
+			recordWriteError(ctx, referenceGrantKind, createOperation, err)
+			recorder.Eventf(ing, corev1.EventTypeWarning, "CreationFailed", "Failed to create ReferenceGrant: %v", err)
+			return fmt.Errorf("failed to create ReferenceGrant: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(rp, ing) {
+		recorder.Eventf(ing, corev1.EventTypeWarning, "NotOwned", "ReferenceGrant %s not owned by this object", desired.Name)
+		return fmt.Errorf("ReferenceGrant %s not owned by %s", rp.Name, ing.Name)
+	}
+
+	if !equality.Semantic.DeepEqual(rp.Spec, desired.Spec) ||
+		!equality.Semantic.DeepEqual(rp.Labels, desired.Labels) {
+		update := rp.DeepCopy()
+		update.Spec = desired.Spec
+		update.Labels = desired.Labels
+
+		updated, err := c.gwapiclient.GatewayV1alpha2().ReferenceGrants(update.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+		if err != nil {
+			recordWriteError(ctx, referenceGrantKind, updateOperation, err)
+			recorder.Eventf(ing, corev1.EventTypeWarning, "UpdateFailed", "Failed to update ReferenceGrant: %v", err)
+			return fmt.Errorf("failed to update ReferenceGrant: %w", err)
+		}
+		c.debug.applied(ing, referenceGrantKind, updated.Name, rp, updated)
+	}
+
+	return c.migrateReferencePolicy(ctx, ing, desired)
+}
+
+// makeTLSReferenceGrant returns the ReferenceGrant that lets the Gateway use
+// the Secret of the TLS section.
+func makeTLSReferenceGrant(ctx context.Context, ing *netv1alpha1.Ingress, tls *netv1alpha1.IngressTLS, gwName types.NamespacedName) *gatewayv1alpha2.ReferenceGrant {
 	gateway := metav1.PartialObjectMetadata{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Gateway",
 			APIVersion: gatewayapi.GroupVersion.String(),
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      externalGw.Gateway.Name,
-			Namespace: externalGw.Gateway.Namespace,
+			Name:      gwName.Name,
+			Namespace: gwName.Namespace,
 		},
 	}
 	secret := metav1.PartialObjectMetadata{
@@ -117,37 +203,32 @@ func (c *Reconciler) reconcileTLS(
 		},
 	}
 
-	desired := resources.MakeReferenceGrant(ctx, ing, secret, gateway)
-
-	rp, err := c.referenceGrantLister.ReferenceGrants(desired.Namespace).Get(desired.Name)
-
-	if apierrs.IsNotFound(err) {
-		rp, err = c.gwapiclient.GatewayV1alpha2().ReferenceGrants(desired.Namespace).Create(ctx, desired, metav1.CreateOptions{})
+	return resources.MakeReferenceGrant(ctx, ing, secret, gateway)
+}
 
-		if err != nil {
-			recorder.Eventf(ing, corev1.EventTypeWarning, "CreationFailed", "Failed to create ReferenceGrant: %v", err)
-			return nil, fmt.Errorf("failed to create ReferenceGrant: %w", err)
-		}
-	} else if err != nil {
-		return nil, err
-	}
+// tlsListenerName returns the name of the listener serving the given host of
+// the Ingress, kni-<uid>-<hash of the host>, so that each host gets its own.
+func tlsListenerName(ing *netv1alpha1.Ingress, host string) gatewayapi.SectionName {
+	sum := sha256.Sum256([]byte(host))
+	return gatewayapi.SectionName(fmt.Sprintf("%s%s-%x", listenerPrefix, ing.GetUID(), sum[:listenerHashLength/2]))
+}
 
-	if !metav1.IsControlledBy(rp, ing) {
-		recorder.Eventf(ing, corev1.EventTypeWarning, "NotOwned", "ReferenceGrant %s not owned by this object", desired.Name)
-		return nil, fmt.Errorf("ReferenceGrant %s not owned by %s", rp.Name, ing.Name)
+// listenerOwner returns the UID of the Ingress the named listener was written
+// for, and false if it isn't the listener of an Ingress. The listeners written
+// before they were named per host are named after the UID alone.
+func listenerOwner(name gatewayapi.SectionName) (types.UID, bool) {
+	if !strings.HasPrefix(string(name), listenerPrefix) || isDefaultListener(name) {
+		return "", false
 	}
-
-	if !equality.Semantic.DeepEqual(rp.Spec, desired.Spec) {
-		update := rp.DeepCopy()
-		update.Spec = desired.Spec
-
-		_, err := c.gwapiclient.GatewayV1alpha2().ReferenceGrants(update.Namespace).Update(ctx, update, metav1.UpdateOptions{})
-		if err != nil {
-			recorder.Eventf(ing, corev1.EventTypeWarning, "UpdateFailed", "Failed to update ReferenceGrant: %v", err)
-			return nil, fmt.Errorf("failed to update ReferenceGrant: %w", err)
-		}
+	uid := strings.TrimPrefix(string(name), listenerPrefix)
+	if i := strings.LastIndexByte(uid, '-'); i >= 0 && len(uid)-i-1 == listenerHashLength {
+		uid = uid[:i]
 	}
+	return types.UID(uid), true
+}
 
+// makeTLSListeners returns the listeners serving the hosts of the TLS section.
+func makeTLSListeners(ing *netv1alpha1.Ingress, tls *netv1alpha1.IngressTLS) []*gatewayapi.Listener {
 	// Gateway API loves typed pointers and constants, so we need to copy the constants
 	// to something we can reference
 	mode := gatewayapi.TLSModeTerminate
@@ -156,7 +237,7 @@ func (c *Reconciler) reconcileTLS(
 	for _, h := range tls.Hosts {
 		h := h
 		listener := gatewayapi.Listener{
-			Name:     gatewayapi.SectionName(listenerPrefix + ing.GetUID()),
+			Name:     tlsListenerName(ing, h),
 			Hostname: (*gatewayapi.Hostname)(&h),
 			Port:     443,
 			Protocol: gatewayapi.HTTPSProtocolType,
@@ -184,98 +265,248 @@ func (c *Reconciler) reconcileTLS(
 		listeners = append(listeners, &listener)
 	}
 
-	return listeners, err
+	return listeners
 }
 
+// placeListeners returns the Gateway of the pool that holds the listeners of
+// the Ingress. Listeners that aren't on any Gateway yet are placed on the
+// least-loaded Gateway with room for them. The listeners on the Gateways
+// record the placement, so an Ingress stays put once they are written.
+func (c *Reconciler) placeListeners(
+	ctx context.Context, ing *netv1alpha1.Ingress, pool []types.NamespacedName,
+) (types.NamespacedName, error) {
+	if len(pool) == 1 {
+		return pool[0], nil
+	}
+
+	// A host listed by several TLS sections still gets a single listener.
+	names := sets.NewString()
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			names.Insert(string(tlsListenerName(ing, host)))
+		}
+	}
+	count := names.Len()
+
+	ingKey := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+
+	var (
+		found    bool
+		best     types.NamespacedName
+		bestLoad = -1
+	)
+	for _, gwName := range pool {
+		gw, err := c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return types.NamespacedName{}, err
+		}
+		found = true
+
+		for _, l := range gw.Spec.Listeners {
+			if uid, ok := listenerOwner(l.Name); ok && uid == ing.UID {
+				return gwName, nil
+			}
+		}
+
+		load := len(gw.Spec.Listeners) + c.listeners.pendingLoad(gwName, ingKey)
+		if load+count <= maxGatewayListeners && (bestLoad < 0 || load < bestLoad) {
+			best, bestLoad = gwName, load
+		}
+	}
+
+	if !found {
+		// Let reconcileGatewayListeners report the missing Gateway.
+		return pool[0], nil
+	}
+	if bestLoad < 0 {
+		controller.GetEventRecorder(ctx).Eventf(ing, corev1.EventTypeWarning, "GatewayPoolFull",
+			"No Gateway has room for %d more listeners", count)
+		return types.NamespacedName{}, fmt.Errorf("no Gateway in the pool has room for %d more listeners", count)
+	}
+	return best, nil
+}
+
+// reconcileGatewayListeners registers the listeners the Ingress wants on the
+// Gateway with the listener aggregator, which writes them out in batches. It
+// returns whether the listeners are in place.
 func (c *Reconciler) reconcileGatewayListeners(
 	ctx context.Context, listeners []*gatewayapi.Listener,
 	ing *netv1alpha1.Ingress, gwName types.NamespacedName,
-) error {
+) (_ bool, err error) {
+	ctx, span := trace.StartSpan(ctx, "reconcileGatewayListeners")
+	defer func() { endSpan(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("gateway", gwName.String()),
+		trace.Int64Attribute("listeners", int64(len(listeners))))
 	recorder := controller.GetEventRecorder(ctx)
-	gw, err := c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+	_, err = c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
 	if apierrs.IsNotFound(err) {
 		recorder.Eventf(ing, corev1.EventTypeWarning, "GatewayMissing", "Unable to update Gateway %s", gwName.String())
-		return fmt.Errorf("Gateway %s does not exist: %w", gwName, err) //nolint:stylecheck
+		return false, fmt.Errorf("Gateway %s does not exist: %w", gwName, err) //nolint:stylecheck
 	} else if err != nil {
-		return err
+		return false, err
+	}
+
+	ready, err := c.listeners.set(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}, gwName, ing.UID, listeners)
+	if err != nil {
+		recorder.Eventf(ing, corev1.EventTypeWarning, "GatewayUpdateFailed", "Failed to update Gateway %s: %v", gwName, err)
+		return false, fmt.Errorf("failed to update Gateway %s: %w", gwName, err)
 	}
+	return ready, nil
+}
 
-	update := gw.DeepCopy()
+// clearGatewayListeners has the listeners of the Ingress removed from the
+// Gateway by the listener aggregator. It returns whether they are gone.
+func (c *Reconciler) clearGatewayListeners(ctx context.Context, ing *netv1alpha1.Ingress, gwName *types.NamespacedName) (bool, error) {
+	recorder := controller.GetEventRecorder(ctx)
 
-	lmap := map[string]*gatewayapi.Listener{}
-	for _, l := range listeners {
-		lmap[string(l.Name)] = l
+	done, err := c.listeners.remove(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}, *gwName, ing.UID)
+	if err != nil {
+		recorder.Eventf(ing, corev1.EventTypeWarning, "GatewayUpdateFailed", "Failed to remove Listener from Gateway %s: %v", gwName, err)
+		return false, fmt.Errorf("failed to update Gateway %s: %w", gwName, err)
 	}
-	// TODO: how do we track and remove listeners if they are removed from the KIngress spec?
-	// Tracked in https://github.com/knative-sandbox/net-gateway-api/issues/319
+	return done, nil
+}
 
-	updated := false
-	for i, l := range gw.Spec.Listeners {
-		l := l
-		desired, ok := lmap[string(l.Name)]
-		if !ok {
-			// This listener doesn't match any that we control.
-			continue
-		}
-		delete(lmap, string(l.Name))
-		if equality.Semantic.DeepEqual(&l, desired) {
-			// Already present and correct
-			continue
-		}
-		update.Spec.Listeners[i] = *desired
-		updated = true
+func patchGatewayListeners(ctx context.Context, client gatewayclientset.Interface, gw *gatewayapi.Gateway, patch listenersPatch) (*gatewayapi.Gateway, error) {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := client.GatewayV1beta1().Gateways(gw.Namespace).Patch(
+		ctx, gw.Name, types.JSONPatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		recordWriteError(ctx, gatewayKind, updateOperation, err)
 	}
+	return patched, err
+}
+
+// listenersPatch is a JSON patch of a Gateway's listeners. Every change to an
+// existing listener is preceded by a test of the name at its index, so the
+// patch fails rather than clobbering a listener someone else moved. Appends
+// can't be guarded by name, as a JSON patch can't test for the absence of a
+// listener, so they're preceded by a test of the resourceVersion the patch
+// was built on. They fail rather than duplicating a listener someone else
+// just added, and the write is retried on a fresh read.
+type listenersPatch []jsonPatchOperation
+
+type jsonPatchOperation struct {
+	Operation string      `json:"op"`
+	Path      string      `json:"path"`
+	Value     interface{} `json:"value,omitempty"`
+}
 
-	for _, l := range lmap {
-		// Add all remaining listeners
-		update.Spec.Listeners = append(update.Spec.Listeners, *l)
-		updated = true
+// add appends the listeners, guarded by the resourceVersion of the Gateway
+// they're appended to when it has one.
+func (p *listenersPatch) add(resourceVersion string, ls ...*gatewayapi.Listener) {
+	if len(ls) == 0 {
+		return
+	}
+	if resourceVersion != "" {
+		*p = append(*p, jsonPatchOperation{Operation: "test", Path: "/metadata/resourceVersion", Value: resourceVersion})
+	}
+	for _, l := range ls {
+		*p = append(*p, jsonPatchOperation{Operation: "add", Path: "/spec/listeners/-", Value: l})
 	}
+}
 
-	if updated {
-		_, err := c.gwapiclient.GatewayV1beta1().Gateways(update.Namespace).Update(
-			ctx, update, metav1.UpdateOptions{})
-		if err != nil {
-			recorder.Eventf(ing, corev1.EventTypeWarning, "GatewayUpdateFailed", "Failed to update Gateway %s: %v", gwName, err)
-			return fmt.Errorf("failed to update Gateway %s/%s: %w", update.Namespace, update.Name, err)
+func (p *listenersPatch) replace(i int, name gatewayapi.SectionName, l *gatewayapi.Listener) {
+	*p = append(*p,
+		jsonPatchOperation{Operation: "test", Path: fmt.Sprintf("/spec/listeners/%d/name", i), Value: name},
+		jsonPatchOperation{Operation: "replace", Path: fmt.Sprintf("/spec/listeners/%d", i), Value: l})
+}
+
+func (p *listenersPatch) remove(i int, name gatewayapi.SectionName) {
+	*p = append(*p,
+		jsonPatchOperation{Operation: "test", Path: fmt.Sprintf("/spec/listeners/%d/name", i), Value: name},
+		jsonPatchOperation{Operation: "remove", Path: fmt.Sprintf("/spec/listeners/%d", i)})
+}
+
+// unlabeledSelector selects the objects created before they were labeled as
+// managed by us, which the informers don't see.
+var unlabeledSelector = "!" + resources.ManagedByLabelKey
+
+// ownedHTTPRoutes returns the HTTPRoutes controlled by the given Ingress. The
+// unlabeled ones are listed from the API server, so that they get cleaned up
+// along with the rest.
+func (c *Reconciler) ownedHTTPRoutes(ctx context.Context, ing *netv1alpha1.Ingress) ([]*gatewayapi.HTTPRoute, error) {
+	routes, err := c.httprouteLister.HTTPRoutes(ing.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	unlabeled, err := c.gwapiclient.GatewayV1beta1().HTTPRoutes(ing.Namespace).List(ctx, metav1.ListOptions{LabelSelector: unlabeledSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unlabeled HTTPRoutes: %w", err)
+	}
+	for i := range unlabeled.Items {
+		routes = append(routes, &unlabeled.Items[i])
+	}
+
+	owned := make([]*gatewayapi.HTTPRoute, 0, len(ing.Spec.Rules))
+	seen := sets.NewString()
+	for _, route := range routes {
+		// The informer may not have seen a route lose its label yet.
+		if metav1.IsControlledBy(route, ing) && !seen.Has(route.Name) {
+			seen.Insert(route.Name)
+			owned = append(owned, route)
 		}
 	}
+	return owned, nil
+}
+
+// deleteHTTPRoutes deletes the given HTTPRoutes of the Ingress.
+func (c *Reconciler) deleteHTTPRoutes(ctx context.Context, ing *netv1alpha1.Ingress, routes []*gatewayapi.HTTPRoute) error {
+	recorder := controller.GetEventRecorder(ctx)
 
+	for _, route := range routes {
+		err := c.gwapiclient.GatewayV1beta1().HTTPRoutes(route.Namespace).Delete(ctx, route.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrs.IsNotFound(err) {
+			recorder.Eventf(ing, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete HTTPRoute %q: %v", route.Name, err)
+			return fmt.Errorf("failed to delete HTTPRoute: %w", err)
+		}
+	}
 	return nil
 }
 
-func (c *Reconciler) clearGatewayListeners(ctx context.Context, ing *netv1alpha1.Ingress, gwName *types.NamespacedName) error {
+// deleteReferenceGrants deletes the ReferenceGrants controlled by the given Ingress.
+// Those normally go away through garbage collection once the Ingress is deleted.
+// The unlabeled ones are listed from the API server, in the namespaces of the
+// Secrets of the Ingress, where they were created.
+func (c *Reconciler) deleteReferenceGrants(ctx context.Context, ing *netv1alpha1.Ingress) error {
 	recorder := controller.GetEventRecorder(ctx)
 
-	gw, err := c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
-	if apierrs.IsNotFound(err) {
-		// Nothing to clean up, all done!
-		return nil
-	} else if err != nil {
+	grants, err := c.referenceGrantLister.List(labels.Everything())
+	if err != nil {
 		return err
 	}
-
-	listenerName := listenerPrefix + string(ing.GetUID())
-	update := gw.DeepCopy()
-
-	numListeners := len(update.Spec.Listeners)
-	for i := numListeners - 1; i >= 0; i-- {
-		// March backwards down the list removing items by swapping in the last item and trimming the list
-		// A generic list.remove(func) would be nice here.
-		l := update.Spec.Listeners[i]
-		if string(l.Name) == listenerName {
-			update.Spec.Listeners[i] = update.Spec.Listeners[len(update.Spec.Listeners)-1]
-			update.Spec.Listeners = update.Spec.Listeners[:len(update.Spec.Listeners)-1]
-		}
+	namespaces := sets.NewString()
+	for _, tls := range ing.Spec.TLS {
+		namespaces.Insert(tls.SecretNamespace)
 	}
-
-	if len(update.Spec.Listeners) != numListeners {
-		_, err := c.gwapiclient.GatewayV1beta1().Gateways(update.Namespace).Update(ctx, update, metav1.UpdateOptions{})
+	for _, ns := range namespaces.List() {
+		unlabeled, err := c.gwapiclient.GatewayV1alpha2().ReferenceGrants(ns).List(ctx, metav1.ListOptions{LabelSelector: unlabeledSelector})
 		if err != nil {
-			recorder.Eventf(ing, corev1.EventTypeWarning, "GatewayUpdateFailed", "Failed to remove Listener from Gateway %s: %v", gwName, err)
-			return fmt.Errorf("failed to update Gateway %s/%s: %w", update.Namespace, update.Name, err)
+			return fmt.Errorf("failed to list unlabeled ReferenceGrants: %w", err)
+		}
+		for i := range unlabeled.Items {
+			grants = append(grants, &unlabeled.Items[i])
 		}
 	}
 
+	deleted := sets.NewString()
+	for _, rg := range grants {
+		key := rg.Namespace + "/" + rg.Name
+		if !metav1.IsControlledBy(rg, ing) || deleted.Has(key) {
+			continue
+		}
+		deleted.Insert(key)
+		err := c.gwapiclient.GatewayV1alpha2().ReferenceGrants(rg.Namespace).Delete(ctx, rg.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrs.IsNotFound(err) {
+			recorder.Eventf(ing, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete ReferenceGrant %q: %v", rg.Name, err)
+			return fmt.Errorf("failed to delete ReferenceGrant: %w", err)
+		}
+	}
 	return nil
 }
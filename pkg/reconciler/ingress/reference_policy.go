@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+)
+
+// referencePolicyMigrations remembers the ReferenceGrants that have no
+// ReferencePolicy left to replace, so that each is only looked up once.
+// The zero value is ready to use.
+type referencePolicyMigrations struct {
+	mu   sync.Mutex
+	done map[types.NamespacedName]struct{}
+}
+
+func (m *referencePolicyMigrations) isDone(name types.NamespacedName) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.done[name]
+	return ok
+}
+
+func (m *referencePolicyMigrations) markDone(name types.NamespacedName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done == nil {
+		m.done = make(map[types.NamespacedName]struct{})
+	}
+	m.done[name] = struct{}{}
+}
+
+// migrateReferencePolicy deletes the ReferencePolicy of the Ingress created
+// in place of the given ReferenceGrant before the cluster was upgraded to
+// serve ReferenceGrants. The ReferenceGrant must exist by then, so that the
+// Gateway doesn't lose access to the Secret in between.
+func (c *Reconciler) migrateReferencePolicy(ctx context.Context, ing *netv1alpha1.Ingress, grant *gatewayv1alpha2.ReferenceGrant) error {
+	if !c.apiVersions.MigratesReferencePolicies() {
+		return nil
+	}
+	name := types.NamespacedName{Namespace: grant.Namespace, Name: grant.Name}
+	if c.policyMigrations.isDone(name) {
+		return nil
+	}
+
+	policies := c.gwapiclient.GatewayV1alpha2().ReferencePolicies(name.Namespace)
+	policy, err := policies.Get(ctx, name.Name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		c.policyMigrations.markDone(name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get ReferencePolicy: %w", err)
+	}
+
+	if metav1.IsControlledBy(policy, ing) {
+		err := policies.Delete(ctx, policy.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrs.IsNotFound(err) {
+			controller.GetEventRecorder(ctx).Eventf(ing, corev1.EventTypeWarning, "DeleteFailed",
+				"Failed to delete ReferencePolicy %q: %v", policy.Name, err)
+			return fmt.Errorf("failed to delete ReferencePolicy: %w", err)
+		}
+		controller.GetEventRecorder(ctx).Eventf(ing, corev1.EventTypeNormal, "Migrated",
+			"Replaced ReferencePolicy %q with a ReferenceGrant", policy.Name)
+	}
+	c.policyMigrations.markDone(name)
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgotesting "k8s.io/client-go/testing"
+
+	fakegwapiclientset "knative.dev/net-gateway-api/pkg/client/injection/client/fake"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileReferencePolicy(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+	policy := resources.ReferenceGrantToPolicy(rp(secret(secretName, nsName)))
+
+	table := TableTest{{
+		Name: "ReferencePolicy in place of ReferenceGrant",
+		Key:  "ns/name",
+		Ctx: servingAPIVersions(resources.APIVersions{
+			HTTPRoute:       resources.V1beta1,
+			Gateway:         resources.V1beta1,
+			ReferencePolicy: resources.V1alpha2,
+		}),
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+		},
+		WantCreates: []runtime.Object{
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			policy,
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer, readyStatus),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "ReferencePolicy replaced by ReferenceGrant",
+		Key:  "ns/name",
+		Ctx: servingAPIVersions(resources.APIVersions{
+			HTTPRoute:       resources.V1beta1,
+			Gateway:         resources.V1beta1,
+			ReferenceGrant:  resources.V1alpha2,
+			ReferencePolicy: resources.V1alpha2,
+		}),
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer),
+			secret(secretName, nsName),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			policy,
+		},
+		WantCreates: []runtime.Object{
+			rp(secret(secretName, nsName)),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: policy.Namespace,
+				Verb:      "delete",
+				Resource: schema.GroupVersionResource{
+					Group:    "gateway.networking.k8s.io",
+					Version:  "v1alpha2",
+					Resource: "referencepolicies",
+				},
+			},
+			Name: policy.Name,
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName), withFinalizer, readyStatus),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Migrated", `Replaced ReferencePolicy %q with a ReferenceGrant`, policy.Name),
+		},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		versions := ctx.Value(apiVersionsKey{}).(resources.APIVersions)
+		r := newTestReconciler(ctx, listers)
+		r.gwapiclient = newVersionedClient(fakegwapiclientset.Get(ctx), versions)
+		r.apiVersions = versions
+		createGateways(ctx, tr)
+		return newTestIngressReconciler(ctx, listers, r, defaultConfig)
+	}))
+}
+
+func servingAPIVersions(versions resources.APIVersions) context.Context {
+	return context.WithValue(context.Background(), apiVersionsKey{}, versions)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1beta1"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/ingress"
+	"knative.dev/pkg/controller"
+)
+
+// Rendering is what the controller makes of a KIngress: the Gateway API
+// objects it writes, and what it reports about the KIngress.
+type Rendering struct {
+	// Ingress is the KIngress with the conditions the controller sets.
+	Ingress *v1alpha1.Ingress
+
+	HTTPRoutes      []*gatewayapi.HTTPRoute
+	ReferenceGrants []*gatewayv1alpha2.ReferenceGrant
+	// ListenerPatches are the JSON patches of the listeners of each Gateway,
+	// ordered by Gateway.
+	ListenerPatches []GatewayListenersPatch
+
+	// Events are the events the controller records on the KIngress.
+	Events []string
+}
+
+// GatewayListenersPatch is a JSON patch of the listeners of a Gateway.
+type GatewayListenersPatch struct {
+	Gateway types.NamespacedName
+	Patch   json.RawMessage
+}
+
+// Render computes what the controller does with the KIngress, without a
+// cluster. The configuration comes from the context, as for the Reconciler,
+// and the state of the Gateways from the given ones; the listener patches
+// of Gateways missing from them add every listener. Hostname conflicts with
+// other KIngresses, Gateway migrations and the TLS Secrets aren't checked,
+// as they depend on the state of the cluster.
+func Render(ctx context.Context, ing *v1alpha1.Ingress, gateways []*gatewayapi.Gateway) (*Rendering, error) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, gw := range gateways {
+		if err := indexer.Add(gw); err != nil {
+			return nil, err
+		}
+	}
+	c := &Reconciler{
+		gatewayLister:   gatewaylisters.NewGatewayLister(indexer),
+		httprouteLister: gatewaylisters.NewHTTPRouteLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		listeners:       &listenerAggregator{},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	ctx = controller.WithEventRecorder(ctx, recorder)
+
+	r := &Rendering{Ingress: ing.DeepCopy()}
+	listeners, err := c.render(ctx, r)
+	close(recorder.Events)
+	for event := range recorder.Events {
+		r.Events = append(r.Events, event)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gwNames := make([]types.NamespacedName, 0, len(listeners))
+	for gwName := range listeners {
+		gwNames = append(gwNames, gwName)
+	}
+	sort.Slice(gwNames, func(i, j int) bool {
+		return gwNames[i].String() < gwNames[j].String()
+	})
+	for _, gwName := range gwNames {
+		gw, err := c.gatewayLister.Gateways(gwName.Namespace).Get(gwName.Name)
+		if err != nil {
+			gw = &gatewayapi.Gateway{}
+		}
+		patch := makeListenersPatch(gw, listeners[gwName], isDefaultListener)
+		if len(patch) == 0 {
+			continue
+		}
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return nil, err
+		}
+		r.ListenerPatches = append(r.ListenerPatches, GatewayListenersPatch{Gateway: gwName, Patch: data})
+	}
+	return r, nil
+}
+
+// render follows reconcileIngress, collecting what desired computes in r
+// rather than writing it. It returns the listeners of each Gateway, along
+// with the default listeners the listener aggregator puts on them.
+func (c *Reconciler) render(ctx context.Context, r *Rendering) (map[types.NamespacedName][]*gatewayapi.Listener, error) {
+	ing := r.Ingress
+
+	ing.SetDefaults(ctx)
+	ing.Status.InitializeConditions()
+	if validateIngress(ctx, ing) {
+		return nil, nil
+	}
+	if _, err := ingress.InsertProbe(ing); err != nil {
+		return nil, fmt.Errorf("failed to add knative probe header: %w", err)
+	}
+
+	gateways, err := gatewaysFor(ctx, ing)
+	if err != nil {
+		ing.Status.MarkIngressNotReady(unknownGatewayProfileReason, err.Error())
+		return nil, nil
+	}
+
+	externalConfig := gateways[v1alpha1.IngressVisibilityExternalIP]
+	externalGw := *externalConfig.Gateway
+	if len(ing.Spec.TLS) > 0 {
+		if externalGw, err = c.placeListeners(ctx, ing, externalConfig.GatewayPool()); err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := c.desired(ctx, ing, gateways, externalGw)
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayListeners := make(map[types.NamespacedName][]*gatewayapi.Listener)
+	for visibility, gw := range gateways {
+		gw := gw
+		for _, gwName := range gw.GatewayPool() {
+			gatewayListeners[gwName] = append(gatewayListeners[gwName], d.defaults[visibility]...)
+		}
+	}
+
+	if d.httpsMissing != "" {
+		markHTTPDisabled(ing, d.httpsMissing)
+		return gatewayListeners, nil
+	}
+	if d.unsupported != nil {
+		ing.Status.MarkIngressNotReady(unsupportedFeatureReason, d.unsupported.Error())
+		return gatewayListeners, nil
+	}
+
+	r.HTTPRoutes = d.routes
+	r.ReferenceGrants = d.grants
+	for gwName, ls := range d.listeners {
+		gatewayListeners[gwName] = append(gatewayListeners[gwName], ls...)
+	}
+	return gatewayListeners, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func TestRender(t *testing.T) {
+	ctx := config.ToContext(context.Background(), defaultConfig)
+	ingress := ing(withBasicSpec, withGatewayAPIClass, withTLS(""))
+
+	r, err := Render(ctx, ingress, []*gatewayapi.Gateway{gw(defaultListener)})
+	if err != nil {
+		t.Fatal("Render() =", err)
+	}
+
+	if len(r.HTTPRoutes) != 1 {
+		t.Fatalf("HTTPRoutes = %v, want one", r.HTTPRoutes)
+	}
+	if diff := cmp.Diff(httpRoute(t, ingress), r.HTTPRoutes[0]); diff != "" {
+		t.Error("HTTPRoute (-want, +got):", diff)
+	}
+	want := rp(secret("name-WE-STICK-A-LONG-UID-HERE", "ns"))
+	if len(r.ReferenceGrants) != 1 || r.ReferenceGrants[0].Name != want.Name || r.ReferenceGrants[0].Namespace != want.Namespace {
+		t.Errorf("ReferenceGrants = %v, want: [%s/%s]", r.ReferenceGrants, want.Namespace, want.Name)
+	}
+
+	if len(r.ListenerPatches) != 1 {
+		t.Fatalf("ListenerPatches = %v, want one", r.ListenerPatches)
+	}
+	if got, want := r.ListenerPatches[0].Gateway, (types.NamespacedName{Namespace: testNamespace, Name: publicName}); got != want {
+		t.Errorf("Gateway = %v, want: %v", got, want)
+	}
+	var patch []struct {
+		Operation string              `json:"op"`
+		Path      string              `json:"path"`
+		Value     gatewayapi.Listener `json:"value"`
+	}
+	if err := json.Unmarshal(r.ListenerPatches[0].Patch, &patch); err != nil {
+		t.Fatal("Failed to decode the patch:", err)
+	}
+	if len(patch) != 1 || patch[0].Operation != "add" || string(*patch[0].Value.Hostname) != "secure.example.com" {
+		t.Errorf("Patch = %s, want the addition of the TLS listener", r.ListenerPatches[0].Patch)
+	}
+
+	// The input is left alone.
+	if len(ingress.Status.Conditions) != 0 {
+		t.Error("Render() changed the status of its input")
+	}
+}
+
+func TestRenderNotReady(t *testing.T) {
+	ctx := config.ToContext(context.Background(), defaultConfig)
+
+	r, err := Render(ctx, ing(withBasicSpec, withGatewayAPIClass, withProfile("missing")), nil)
+	if err != nil {
+		t.Fatal("Render() =", err)
+	}
+	if len(r.HTTPRoutes) != 0 || len(r.ListenerPatches) != 0 {
+		t.Errorf("Render() = %v, want nothing rendered", r)
+	}
+	if c := r.Ingress.Status.GetCondition(v1alpha1.IngressConditionReady); c == nil || c.Reason != unknownGatewayProfileReason {
+		t.Errorf("Ready = %v, want reason %s", c, unknownGatewayProfileReason)
+	}
+}
+
+func TestRenderDefaultListeners(t *testing.T) {
+	cfg := defaultConfig.DeepCopy()
+	external := cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP]
+	external.DefaultListeners = []config.DefaultListener{wildcardListener}
+	cfg.Gateway.Gateways[v1alpha1.IngressVisibilityExternalIP] = external
+	ctx := config.ToContext(context.Background(), cfg)
+
+	withHost := func(i *v1alpha1.Ingress) {
+		i.Spec.Rules[0].Hosts = []string{"www.example.com"}
+	}
+	wildcard := makeDefaultListeners(external.DefaultListeners)[0]
+
+	tests := []struct {
+		name      string
+		gateways  []*gatewayapi.Gateway
+		wantRoute runtime.Object
+	}{{
+		name:     "routes attach to the default listener",
+		gateways: []*gatewayapi.Gateway{gw(defaultListener)},
+		wantRoute: sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost),
+			"http", defaultListenerName("*.example.com")),
+	}, {
+		// Like the Reconciler, which leaves the missing Gateway to
+		// reconcileGatewayListeners to report.
+		name:      "Gateway missing",
+		wantRoute: sectionRoute(t, cfg, ing(withBasicSpec, withGatewayAPIClass, withHost)),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := Render(ctx, ing(withBasicSpec, withGatewayAPIClass, withHost), test.gateways)
+			if err != nil {
+				t.Fatal("Render() =", err)
+			}
+			if len(r.HTTPRoutes) != 1 {
+				t.Fatalf("HTTPRoutes = %v, want one", r.HTTPRoutes)
+			}
+			if diff := cmp.Diff(test.wantRoute, r.HTTPRoutes[0]); diff != "" {
+				t.Error("HTTPRoute (-want, +got):", diff)
+			}
+
+			// The default listener goes on the Gateway, once.
+			if len(r.ListenerPatches) != 1 {
+				t.Fatalf("ListenerPatches = %v, want one", r.ListenerPatches)
+			}
+			var patch []struct {
+				Operation string              `json:"op"`
+				Value     gatewayapi.Listener `json:"value"`
+			}
+			if err := json.Unmarshal(r.ListenerPatches[0].Patch, &patch); err != nil {
+				t.Fatal("Failed to decode the patch:", err)
+			}
+			if len(patch) != 1 || patch[0].Operation != "add" || patch[0].Value.Name != wildcard.Name {
+				t.Errorf("Patch = %s, want the addition of the default listener", r.ListenerPatches[0].Patch)
+			}
+		})
+	}
+}
@@ -22,6 +22,18 @@ import (
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
+const (
+	// ManagedByLabelKey is put on the objects the controller creates, so that
+	// it only needs to watch those.
+	ManagedByLabelKey = "app.kubernetes.io/managed-by"
+
+	// ManagedByLabelValue is the value of ManagedByLabelKey.
+	ManagedByLabelValue = "net-gateway-api"
+
+	// ManagedBySelector selects the objects the controller created.
+	ManagedBySelector = ManagedByLabelKey + "=" + ManagedByLabelValue
+)
+
 func ptr[T any](val T) *T {
 	return &val
 }
@@ -18,10 +18,12 @@ package resources
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
 
@@ -31,11 +33,29 @@ import (
 	"knative.dev/pkg/kmeta"
 )
 
-// MakeHTTPRoute creates HTTPRoute to set up routing rules.
+// UnsupportedFeatureError is returned when an Ingress can't be translated to
+// an HTTPRoute without a feature its Gateway doesn't support.
+type UnsupportedFeatureError struct {
+	Gateway types.NamespacedName
+	Feature string
+	// Use is what the Ingress needs the feature for.
+	Use string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("Gateway %s does not support %s, needed to %s", e.Gateway, e.Feature, e.Use)
+}
+
+// MakeHTTPRoute creates HTTPRoute to set up routing rules, attached to the
+// given Gateway, or to the given sections (listeners) of it. It only uses the
+// filters the Gateway supports, as given by caps, or all of them for nil.
 func MakeHTTPRoute(
 	ctx context.Context,
 	ing *netv1alpha1.Ingress,
 	rule *netv1alpha1.IngressRule,
+	gateway types.NamespacedName,
+	caps *config.Capabilities,
+	sections ...gatewayapi.SectionName,
 ) (*gatewayapi.HTTPRoute, error) {
 
 	visibility := ""
@@ -43,93 +63,79 @@ func MakeHTTPRoute(
 		visibility = "cluster-local"
 	}
 
+	spec, err := makeHTTPRouteSpec(rule, gateway, caps, sections)
+	if err != nil {
+		return nil, err
+	}
+
 	return &gatewayapi.HTTPRoute{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      LongestHost(rule.Hosts),
 			Namespace: ing.Namespace,
 			Labels: kmeta.UnionMaps(ing.Labels, map[string]string{
 				networking.VisibilityLabelKey: visibility,
+				ManagedByLabelKey:             ManagedByLabelValue,
 			}),
 			Annotations: kmeta.FilterMap(ing.GetAnnotations(), func(key string) bool {
 				return key == corev1.LastAppliedConfigAnnotation
 			}),
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(ing)},
 		},
-		Spec: makeHTTPRouteSpec(ctx, rule),
+		Spec: spec,
 	}, nil
 }
 
 func makeHTTPRouteSpec(
-	ctx context.Context,
 	rule *netv1alpha1.IngressRule,
-) gatewayapi.HTTPRouteSpec {
+	gateway types.NamespacedName,
+	caps *config.Capabilities,
+	sections []gatewayapi.SectionName,
+) (gatewayapi.HTTPRouteSpec, error) {
 
 	hostnames := make([]gatewayapi.Hostname, 0, len(rule.Hosts))
 	for _, hostname := range rule.Hosts {
 		hostnames = append(hostnames, gatewayapi.Hostname(hostname))
 	}
 
-	rules := makeHTTPRouteRule(rule)
-
-	gatewayConfig := config.FromContext(ctx).Gateway
-	namespacedNameGateway := gatewayConfig.Gateways[rule.Visibility].Gateway
+	rules, err := makeHTTPRouteRule(rule, gateway, caps)
+	if err != nil {
+		return gatewayapi.HTTPRouteSpec{}, err
+	}
 
 	gatewayRef := gatewayapi.ParentReference{
 		Group:     (*gatewayapi.Group)(&gatewayapi.GroupVersion.Group),
 		Kind:      (*gatewayapi.Kind)(pointer.String("Gateway")),
-		Namespace: ptr(gatewayapi.Namespace(namespacedNameGateway.Namespace)),
-		Name:      gatewayapi.ObjectName(namespacedNameGateway.Name),
+		Namespace: ptr(gatewayapi.Namespace(gateway.Namespace)),
+		Name:      gatewayapi.ObjectName(gateway.Name),
+	}
+
+	parentRefs := []gatewayapi.ParentReference{gatewayRef}
+	if len(sections) > 0 {
+		parentRefs = make([]gatewayapi.ParentReference, 0, len(sections))
+		for _, section := range sections {
+			ref := gatewayRef
+			ref.SectionName = ptr(section)
+			parentRefs = append(parentRefs, ref)
+		}
 	}
 
 	return gatewayapi.HTTPRouteSpec{
 		Hostnames: hostnames,
 		Rules:     rules,
-		CommonRouteSpec: gatewayapi.CommonRouteSpec{ParentRefs: []gatewayapi.ParentReference{
-			gatewayRef,
-		}},
-	}
+		CommonRouteSpec: gatewayapi.CommonRouteSpec{
+			ParentRefs: parentRefs,
+		},
+	}, nil
 }
 
-func makeHTTPRouteRule(rule *netv1alpha1.IngressRule) []gatewayapi.HTTPRouteRule {
+func makeHTTPRouteRule(rule *netv1alpha1.IngressRule, gateway types.NamespacedName, caps *config.Capabilities) ([]gatewayapi.HTTPRouteRule, error) {
 	rules := []gatewayapi.HTTPRouteRule{}
 
 	for _, path := range rule.HTTP.Paths {
 		backendRefs := make([]gatewayapi.HTTPBackendRef, 0, len(path.Splits))
-		var preFilters []gatewayapi.HTTPRouteFilter
-
-		if path.AppendHeaders != nil {
-			headers := []gatewayapi.HTTPHeader{}
-			for k, v := range path.AppendHeaders {
-				header := gatewayapi.HTTPHeader{
-					Name:  gatewayapi.HTTPHeaderName(k),
-					Value: v,
-				}
-				headers = append(headers, header)
-			}
-
-			// Sort HTTPHeader as the order is random.
-			sort.Sort(HTTPHeaderList(headers))
-
-			preFilters = []gatewayapi.HTTPRouteFilter{{
-				Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
-				RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
-					Set: headers,
-				}}}
-		}
+		headers := makeHTTPHeaders(path.AppendHeaders)
 
 		for _, split := range path.Splits {
-			headers := []gatewayapi.HTTPHeader{}
-			for k, v := range split.AppendHeaders {
-				header := gatewayapi.HTTPHeader{
-					Name:  gatewayapi.HTTPHeaderName(k),
-					Value: v,
-				}
-				headers = append(headers, header)
-			}
-
-			// Sort HTTPHeader as the order is random.
-			sort.Sort(HTTPHeaderList(headers))
-
 			name := split.IngressBackend.ServiceName
 			backendRef := gatewayapi.HTTPBackendRef{
 				BackendRef: gatewayapi.BackendRef{
@@ -141,15 +147,48 @@ func makeHTTPRouteRule(rule *netv1alpha1.IngressRule) []gatewayapi.HTTPRouteRule
 					},
 					Weight: pointer.Int32(int32(split.Percent)),
 				},
-				Filters: []gatewayapi.HTTPRouteFilter{{
+			}
+
+			splitHeaders := makeHTTPHeaders(split.AppendHeaders)
+			switch {
+			case len(splitHeaders) == 0:
+			case caps == nil || caps.SupportsBackendFilter(gatewayapi.HTTPRouteFilterRequestHeaderModifier):
+				backendRef.Filters = []gatewayapi.HTTPRouteFilter{{
 					Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
 					RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
-						Set: headers,
+						Set: splitHeaders,
 					}},
-				}}
+				}
+			case len(path.Splits) == 1:
+				// All requests go to this backend, so its headers can go
+				// on the rule instead.
+				headers = mergeHTTPHeaders(headers, splitHeaders)
+			default:
+				return nil, &UnsupportedFeatureError{
+					Gateway: gateway,
+					Feature: "RequestHeaderModifier filters on backendRefs",
+					Use:     fmt.Sprintf("set headers on the %d backends of host %q", len(path.Splits), LongestHost(rule.Hosts)),
+				}
+			}
 			backendRefs = append(backendRefs, backendRef)
 		}
 
+		var preFilters []gatewayapi.HTTPRouteFilter
+		if len(headers) > 0 {
+			if caps != nil && !caps.SupportsFilter(gatewayapi.HTTPRouteFilterRequestHeaderModifier) {
+				return nil, &UnsupportedFeatureError{
+					Gateway: gateway,
+					Feature: "RequestHeaderModifier filters",
+					Use:     fmt.Sprintf("set headers on host %q", LongestHost(rule.Hosts)),
+				}
+			}
+			preFilters = []gatewayapi.HTTPRouteFilter{{
+				Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+				RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
+					Set: headers,
+				}}}
+		}
+
 		pathPrefix := "/"
 		if path.Path != "" {
 			pathPrefix = path.Path
@@ -181,7 +220,34 @@ func makeHTTPRouteRule(rule *netv1alpha1.IngressRule) []gatewayapi.HTTPRouteRule
 		}
 		rules = append(rules, rule)
 	}
-	return rules
+	return rules, nil
+}
+
+// makeHTTPHeaders returns the headers to set for the given map, sorted as the
+// order of the map is random.
+func makeHTTPHeaders(m map[string]string) []gatewayapi.HTTPHeader {
+	if len(m) == 0 {
+		return nil
+	}
+	headers := make([]gatewayapi.HTTPHeader, 0, len(m))
+	for k, v := range m {
+		headers = append(headers, gatewayapi.HTTPHeader{
+			Name:  gatewayapi.HTTPHeaderName(k),
+			Value: v,
+		})
+	}
+	sort.Sort(HTTPHeaderList(headers))
+	return headers
+}
+
+// mergeHTTPHeaders returns the headers of both lists, with those of the second
+// taking precedence, as backend filters apply after those of the rule.
+func mergeHTTPHeaders(headers, overrides []gatewayapi.HTTPHeader) []gatewayapi.HTTPHeader {
+	merged := make(map[string]string, len(headers)+len(overrides))
+	for _, h := range append(headers, overrides...) {
+		merged[string(h.Name)] = h.Value
+	}
+	return makeHTTPHeaders(merged)
 }
 
 type HTTPHeaderList []gatewayapi.HTTPHeader
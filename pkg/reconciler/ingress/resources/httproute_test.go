@@ -18,6 +18,7 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -143,6 +144,7 @@ func TestMakeHTTPRoute(t *testing.T) {
 						Labels: map[string]string{
 							networking.IngressLabelKey:          testIngressName,
 							"networking.knative.dev/visibility": "",
+							ManagedByLabelKey:                   ManagedByLabelValue,
 						},
 						Annotations: map[string]string{},
 					},
@@ -228,6 +230,7 @@ func TestMakeHTTPRoute(t *testing.T) {
 						Labels: map[string]string{
 							networking.IngressLabelKey:          testIngressName,
 							"networking.knative.dev/visibility": "cluster-local",
+							ManagedByLabelKey:                   ManagedByLabelValue,
 						},
 						Annotations: map[string]string{},
 					},
@@ -358,6 +361,7 @@ func TestMakeHTTPRoute(t *testing.T) {
 					Labels: map[string]string{
 						networking.IngressLabelKey:          testIngressName,
 						"networking.knative.dev/visibility": "",
+						ManagedByLabelKey:                   ManagedByLabelValue,
 					},
 					Annotations: map[string]string{},
 				},
@@ -375,10 +379,6 @@ func TestMakeHTTPRoute(t *testing.T) {
 									},
 									Weight: pointer.Int32(int32(100)),
 								},
-								Filters: []gatewayapi.HTTPRouteFilter{{
-									Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
-									RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
-										Set: []gatewayapi.HTTPHeader{}}}},
 							}},
 							Matches: []gatewayapi.HTTPRouteMatch{
 								{
@@ -403,10 +403,6 @@ func TestMakeHTTPRoute(t *testing.T) {
 									},
 									Weight: pointer.Int32(int32(100)),
 								},
-								Filters: []gatewayapi.HTTPRouteFilter{{
-									Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
-									RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
-										Set: []gatewayapi.HTTPHeader{}}}},
 							}},
 							Matches: []gatewayapi.HTTPRouteMatch{
 								{
@@ -439,7 +435,7 @@ func TestMakeHTTPRoute(t *testing.T) {
 				tcs := &testConfigStore{config: testConfig}
 				ctx := tcs.ToContext(context.Background())
 
-				route, err := MakeHTTPRoute(ctx, tc.ing, &rule)
+				route, err := MakeHTTPRoute(ctx, tc.ing, &rule, *testConfig.Gateway.Gateways[rule.Visibility].Gateway, nil)
 				if err != nil {
 					t.Fatal("MakeHTTPRoute failed:", err)
 				}
@@ -452,6 +448,112 @@ func TestMakeHTTPRoute(t *testing.T) {
 	}
 }
 
+func TestMakeHTTPRouteCapabilities(t *testing.T) {
+	gateway := types.NamespacedName{Namespace: "test-ns", Name: "foo"}
+	ruleFilters := &config.Capabilities{
+		Filters: []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier},
+	}
+	split := func(name string, percent int, headers map[string]string) v1alpha1.IngressBackendSplit {
+		return v1alpha1.IngressBackendSplit{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceName: name,
+				ServicePort: intstr.FromInt(80),
+			},
+			Percent:       percent,
+			AppendHeaders: headers,
+		}
+	}
+	rule := func(headers map[string]string, splits ...v1alpha1.IngressBackendSplit) *v1alpha1.IngressRule {
+		return &v1alpha1.IngressRule{
+			Hosts:      testHosts,
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					AppendHeaders: headers,
+					Splits:        splits,
+				}},
+			},
+		}
+	}
+	setHeaders := func(headers ...gatewayapi.HTTPHeader) []gatewayapi.HTTPRouteFilter {
+		return []gatewayapi.HTTPRouteFilter{{
+			Type: gatewayapi.HTTPRouteFilterRequestHeaderModifier,
+			RequestHeaderModifier: &gatewayapi.HTTPRequestHeaderFilter{
+				Set: headers,
+			},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		rule        *v1alpha1.IngressRule
+		caps        *config.Capabilities
+		wantFilters []gatewayapi.HTTPRouteFilter
+		wantBackend [][]gatewayapi.HTTPRouteFilter
+		wantErr     string
+	}{{
+		name:        "no headers, no filters",
+		rule:        rule(nil, split("goo", 100, nil)),
+		caps:        ruleFilters,
+		wantBackend: [][]gatewayapi.HTTPRouteFilter{nil},
+	}, {
+		name:        "backend filters supported",
+		rule:        rule(nil, split("goo", 100, map[string]string{"Rev": "goo"})),
+		caps:        &config.Capabilities{BackendFilters: []gatewayapi.HTTPRouteFilterType{gatewayapi.HTTPRouteFilterRequestHeaderModifier}},
+		wantBackend: [][]gatewayapi.HTTPRouteFilter{setHeaders(gatewayapi.HTTPHeader{Name: "Rev", Value: "goo"})},
+	}, {
+		name: "headers of the only backend are hoisted",
+		rule: rule(map[string]string{"Foo": "bar", "Rev": "path"}, split("goo", 100, map[string]string{"Rev": "goo"})),
+		caps: ruleFilters,
+		wantFilters: setHeaders(
+			gatewayapi.HTTPHeader{Name: "Rev", Value: "goo"},
+			gatewayapi.HTTPHeader{Name: "Foo", Value: "bar"},
+		),
+		wantBackend: [][]gatewayapi.HTTPRouteFilter{nil},
+	}, {
+		name:    "headers of split backends",
+		rule:    rule(nil, split("goo", 50, map[string]string{"Rev": "goo"}), split("doo", 50, map[string]string{"Rev": "doo"})),
+		caps:    ruleFilters,
+		wantErr: `Gateway test-ns/foo does not support RequestHeaderModifier filters on backendRefs, needed to set headers on the 2 backends of host "hello-example.default.example.com"`,
+	}, {
+		name:    "no header filters at all",
+		rule:    rule(map[string]string{"Foo": "bar"}, split("goo", 100, nil)),
+		caps:    &config.Capabilities{},
+		wantErr: `Gateway test-ns/foo does not support RequestHeaderModifier filters, needed to set headers on host "hello-example.default.example.com"`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing := &v1alpha1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: testIngressName, Namespace: testNamespace},
+				Spec:       v1alpha1.IngressSpec{Rules: []v1alpha1.IngressRule{*test.rule}},
+			}
+			route, err := MakeHTTPRoute(context.Background(), ing, test.rule, gateway, test.caps)
+			if test.wantErr != "" {
+				var unsupported *UnsupportedFeatureError
+				if !errors.As(err, &unsupported) || err.Error() != test.wantErr {
+					t.Fatalf("MakeHTTPRoute() = %v, want: %s", err, test.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("MakeHTTPRoute() =", err)
+			}
+
+			got := route.Spec.Rules[0]
+			if diff := cmp.Diff(test.wantFilters, got.Filters); diff != "" {
+				t.Error("Rule filters (-want, +got):", diff)
+			}
+			backendFilters := make([][]gatewayapi.HTTPRouteFilter, 0, len(got.BackendRefs))
+			for _, backend := range got.BackendRefs {
+				backendFilters = append(backendFilters, backend.Filters)
+			}
+			if diff := cmp.Diff(test.wantBackend, backendFilters); diff != "" {
+				t.Error("Backend filters (-want, +got):", diff)
+			}
+		})
+	}
+}
+
 type testConfigStore struct {
 	config *config.Config
 }
@@ -37,7 +37,7 @@ func MakeReferenceGrant(ctx context.Context, ing *netv1alpha1.Ingress, to, from
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            name,
 			Namespace:       to.Namespace,
-			Labels:          to.Labels,
+			Labels:          kmeta.UnionMaps(to.Labels, map[string]string{ManagedByLabelKey: ManagedByLabelValue}),
 			Annotations:     to.Annotations,
 			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(ing)},
 		},
@@ -55,3 +55,32 @@ func MakeReferenceGrant(ctx context.Context, ing *netv1alpha1.Ingress, to, from
 		},
 	}
 }
+
+// ReferenceGrantToPolicy renders the ReferenceGrant as the ReferencePolicy it
+// was called before Gateway API v0.5.0, for clusters that don't serve
+// ReferenceGrants yet.
+func ReferenceGrantToPolicy(grant *gatewayv1alpha2.ReferenceGrant) *gatewayv1alpha2.ReferencePolicy {
+	policy := &gatewayv1alpha2.ReferencePolicy{
+		TypeMeta:   grant.TypeMeta,
+		ObjectMeta: grant.ObjectMeta,
+		Spec:       grant.Spec,
+	}
+	if policy.Kind != "" {
+		policy.Kind = "ReferencePolicy"
+	}
+	return policy
+}
+
+// ReferenceGrantFromPolicy reads the ReferencePolicy back as a
+// ReferenceGrant.
+func ReferenceGrantFromPolicy(policy *gatewayv1alpha2.ReferencePolicy) *gatewayv1alpha2.ReferenceGrant {
+	grant := &gatewayv1alpha2.ReferenceGrant{
+		TypeMeta:   policy.TypeMeta,
+		ObjectMeta: policy.ObjectMeta,
+		Spec:       policy.Spec,
+	}
+	if grant.Kind != "" {
+		grant.Kind = "ReferenceGrant"
+	}
+	return grant
+}
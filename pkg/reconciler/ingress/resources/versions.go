@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// APIVersion is a version of the Gateway API group.
+type APIVersion string
+
+const (
+	// V1beta1 is the version the resources are modeled in.
+	V1beta1 APIVersion = "v1beta1"
+
+	// V1alpha2 is served by Gateway API installs older than v0.5.0.
+	V1alpha2 APIVersion = "v1alpha2"
+)
+
+// GroupVersion returns the Gateway API group at the version.
+func (v APIVersion) GroupVersion() schema.GroupVersion {
+	return schema.GroupVersion{Group: gatewayapi.GroupName, Version: string(v)}
+}
+
+// APIVersions are the versions of the Gateway API kinds served by the cluster.
+// An empty version means the kind isn't served at all.
+type APIVersions struct {
+	HTTPRoute      APIVersion
+	Gateway        APIVersion
+	ReferenceGrant APIVersion
+
+	// ReferencePolicy is what ReferenceGrant was called before v0.5.0. It
+	// stands in for ReferenceGrant on clusters that don't serve the latter.
+	ReferencePolicy APIVersion
+}
+
+// UsesReferencePolicies returns whether ReferencePolicies are created in
+// place of ReferenceGrants.
+func (v APIVersions) UsesReferencePolicies() bool {
+	return v.ReferenceGrant == "" && v.ReferencePolicy != ""
+}
+
+// MigratesReferencePolicies returns whether ReferencePolicies left from before
+// the cluster served ReferenceGrants are to be replaced by the latter.
+func (v APIVersions) MigratesReferencePolicies() bool {
+	return v.ReferenceGrant != "" && v.ReferencePolicy != ""
+}
+
+// DefaultAPIVersions are the versions used when nothing tells otherwise.
+var DefaultAPIVersions = APIVersions{
+	HTTPRoute:      V1beta1,
+	Gateway:        V1beta1,
+	ReferenceGrant: V1alpha2,
+}
+
+// The resources are modeled with the v1beta1 types, whose v1alpha2
+// counterparts share their schema. The functions below render them to, and
+// read them back from, v1alpha2 for clusters that don't serve v1beta1.
+
+// HTTPRouteToV1alpha2 renders the HTTPRoute as v1alpha2.
+func HTTPRouteToV1alpha2(route *gatewayapi.HTTPRoute) (*gatewayv1alpha2.HTTPRoute, error) {
+	out := &gatewayv1alpha2.HTTPRoute{}
+	if err := convert(route, out); err != nil {
+		return nil, fmt.Errorf("failed to render HTTPRoute %s/%s as v1alpha2: %w", route.Namespace, route.Name, err)
+	}
+	fixAPIVersion(&out.APIVersion, V1alpha2)
+	return out, nil
+}
+
+// HTTPRouteFromV1alpha2 reads the v1alpha2 HTTPRoute back.
+func HTTPRouteFromV1alpha2(route *gatewayv1alpha2.HTTPRoute) (*gatewayapi.HTTPRoute, error) {
+	out := &gatewayapi.HTTPRoute{}
+	if err := convert(route, out); err != nil {
+		return nil, fmt.Errorf("failed to read v1alpha2 HTTPRoute %s/%s: %w", route.Namespace, route.Name, err)
+	}
+	fixAPIVersion(&out.APIVersion, V1beta1)
+	return out, nil
+}
+
+// HTTPRouteListFromV1alpha2 reads the v1alpha2 HTTPRoutes back.
+func HTTPRouteListFromV1alpha2(list *gatewayv1alpha2.HTTPRouteList) (*gatewayapi.HTTPRouteList, error) {
+	out := &gatewayapi.HTTPRouteList{}
+	if err := convert(list, out); err != nil {
+		return nil, fmt.Errorf("failed to read v1alpha2 HTTPRoutes: %w", err)
+	}
+	fixAPIVersion(&out.APIVersion, V1beta1)
+	return out, nil
+}
+
+// GatewayToV1alpha2 renders the Gateway as v1alpha2.
+func GatewayToV1alpha2(gw *gatewayapi.Gateway) (*gatewayv1alpha2.Gateway, error) {
+	out := &gatewayv1alpha2.Gateway{}
+	if err := convert(gw, out); err != nil {
+		return nil, fmt.Errorf("failed to render Gateway %s/%s as v1alpha2: %w", gw.Namespace, gw.Name, err)
+	}
+	fixAPIVersion(&out.APIVersion, V1alpha2)
+	return out, nil
+}
+
+// GatewayFromV1alpha2 reads the v1alpha2 Gateway back.
+func GatewayFromV1alpha2(gw *gatewayv1alpha2.Gateway) (*gatewayapi.Gateway, error) {
+	out := &gatewayapi.Gateway{}
+	if err := convert(gw, out); err != nil {
+		return nil, fmt.Errorf("failed to read v1alpha2 Gateway %s/%s: %w", gw.Namespace, gw.Name, err)
+	}
+	fixAPIVersion(&out.APIVersion, V1beta1)
+	return out, nil
+}
+
+// GatewayListFromV1alpha2 reads the v1alpha2 Gateways back.
+func GatewayListFromV1alpha2(list *gatewayv1alpha2.GatewayList) (*gatewayapi.GatewayList, error) {
+	out := &gatewayapi.GatewayList{}
+	if err := convert(list, out); err != nil {
+		return nil, fmt.Errorf("failed to read v1alpha2 Gateways: %w", err)
+	}
+	fixAPIVersion(&out.APIVersion, V1beta1)
+	return out, nil
+}
+
+func convert(from, to interface{}) error {
+	bs, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, to)
+}
+
+// fixAPIVersion points the apiVersion of a converted object at its new
+// version, leaving it alone when unset as it is on typed client objects.
+func fixAPIVersion(apiVersion *string, version APIVersion) {
+	if *apiVersion != "" {
+		*apiVersion = version.GroupVersion().String()
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestHTTPRouteV1alpha2RoundTrip(t *testing.T) {
+	route := &gatewayapi.HTTPRoute{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: gatewayapi.GroupVersion.String(),
+			Kind:       "HTTPRoute",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example.com",
+			Namespace: testNamespace,
+			Labels:    map[string]string{"a": "b"},
+		},
+		Spec: gatewayapi.HTTPRouteSpec{
+			CommonRouteSpec: gatewayapi.CommonRouteSpec{
+				ParentRefs: []gatewayapi.ParentReference{{
+					Name:      "gateway",
+					Namespace: ptr(gatewayapi.Namespace("gateway-ns")),
+				}},
+			},
+			Hostnames: []gatewayapi.Hostname{externalHost},
+			Rules: []gatewayapi.HTTPRouteRule{{
+				BackendRefs: []gatewayapi.HTTPBackendRef{{
+					BackendRef: gatewayapi.BackendRef{
+						BackendObjectReference: gatewayapi.BackendObjectReference{
+							Name: "goo",
+							Port: portNumPtr(123),
+						},
+						Weight: ptr(int32(100)),
+					},
+				}},
+			}},
+		},
+	}
+
+	alpha, err := HTTPRouteToV1alpha2(route)
+	if err != nil {
+		t.Fatal("HTTPRouteToV1alpha2() =", err)
+	}
+	if got, want := alpha.APIVersion, "gateway.networking.k8s.io/v1alpha2"; got != want {
+		t.Errorf("APIVersion = %q, want: %q", got, want)
+	}
+	if got, want := string(alpha.Spec.Rules[0].BackendRefs[0].Name), "goo"; got != want {
+		t.Errorf("backend = %q, want: %q", got, want)
+	}
+
+	back, err := HTTPRouteFromV1alpha2(alpha)
+	if err != nil {
+		t.Fatal("HTTPRouteFromV1alpha2() =", err)
+	}
+	if diff := cmp.Diff(route, back); diff != "" {
+		t.Error("HTTPRoute changed in the round trip (-want, +got):", diff)
+	}
+}
+
+func TestGatewayV1alpha2RoundTrip(t *testing.T) {
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gateway",
+			Namespace: "gateway-ns",
+		},
+		Spec: gatewayapi.GatewaySpec{
+			GatewayClassName: testGatewayClass,
+			Listeners: []gatewayapi.Listener{{
+				Name:     "http",
+				Port:     80,
+				Protocol: gatewayapi.HTTPProtocolType,
+			}},
+		},
+	}
+
+	alpha, err := GatewayToV1alpha2(gw)
+	if err != nil {
+		t.Fatal("GatewayToV1alpha2() =", err)
+	}
+	if alpha.APIVersion != "" {
+		t.Errorf("APIVersion = %q, want it unset", alpha.APIVersion)
+	}
+
+	back, err := GatewayFromV1alpha2(alpha)
+	if err != nil {
+		t.Fatal("GatewayFromV1alpha2() =", err)
+	}
+	if diff := cmp.Diff(gw, back); diff != "" {
+		t.Error("Gateway changed in the round trip (-want, +got):", diff)
+	}
+}
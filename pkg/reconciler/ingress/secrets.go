@@ -0,0 +1,305 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/metadata/metadatalister"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/tracker"
+)
+
+const (
+	secretMissingReason           = "SecretMissing"
+	secretInvalidReason           = "SecretInvalid"
+	certificateHostMismatchReason = "CertificateHostMismatch"
+	certificateExpiredReason      = "CertificateExpired"
+)
+
+var secretsResource = corev1.SchemeGroupVersion.WithResource("secrets")
+
+func init() {
+	injection.Default.RegisterClient(withMetadataClient)
+	injection.Default.RegisterInformer(withSecretInformer)
+}
+
+type metadataClientKey struct{}
+
+func withMetadataClient(ctx context.Context, cfg *rest.Config) context.Context {
+	return context.WithValue(ctx, metadataClientKey{}, metadata.NewForConfigOrDie(cfg))
+}
+
+func getMetadataClient(ctx context.Context) metadata.Interface {
+	untyped := ctx.Value(metadataClientKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the metadata client from context.")
+	}
+	return untyped.(metadata.Interface)
+}
+
+type secretInformerKey struct{}
+
+// withSecretInformer sets up the informer for the Secrets referenced by the
+// TLS section of Ingresses. It only watches their metadata, which is enough
+// to notice them being created, rotated or deleted; the Secrets themselves
+// are fetched by the secretCache once an Ingress references them.
+func withSecretInformer(ctx context.Context) (context.Context, controller.Informer) {
+	client := getMetadataClient(ctx)
+	resync := controller.GetResyncPeriod(ctx)
+
+	var inf cache.SharedIndexInformer
+	if scope := namespaceScopeFromContext(ctx); scope.IsZero() {
+		inf = metadatainformer.NewFilteredMetadataInformer(client, secretsResource, metav1.NamespaceAll, resync,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil).Informer()
+	} else {
+		inf = newMultiNamespaceInformer(&metav1.PartialObjectMetadata{}, resync, scope.Secrets,
+			func(ns string, options metav1.ListOptions) (runtime.Object, error) {
+				return client.Resource(secretsResource).Namespace(ns).List(context.TODO(), options)
+			},
+			func(ns string, options metav1.ListOptions) (watch.Interface, error) {
+				return client.Resource(secretsResource).Namespace(ns).Watch(context.TODO(), options)
+			}, nil)
+	}
+	if err := inf.SetTransform(trimSecretMetadata); err != nil {
+		logging.FromContext(ctx).Panicw("Failed to set the Secret transform", "error", err)
+	}
+	return context.WithValue(ctx, secretInformerKey{}, inf), inf
+}
+
+func getSecretInformer(ctx context.Context) cache.SharedIndexInformer {
+	untyped := ctx.Value(secretInformerKey{})
+	if untyped == nil {
+		logging.FromContext(ctx).Panic("Unable to fetch the Secret informer from context.")
+	}
+	return untyped.(cache.SharedIndexInformer)
+}
+
+// trimSecretMetadata keeps no more of the metadata of Secrets than we need
+// to tell them apart and notice them change. Their annotations may hold the
+// last applied configuration, private key included.
+func trimSecretMetadata(obj interface{}) (interface{}, error) {
+	m, ok := obj.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return obj, nil
+	}
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: m.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       m.Namespace,
+			Name:            m.Name,
+			UID:             m.UID,
+			ResourceVersion: m.ResourceVersion,
+		},
+	}, nil
+}
+
+// trimSecret drops everything but the certificate from the Secrets we cache.
+// We only ever look at certificates, and private keys have no business
+// sitting in our memory.
+func trimSecret(obj interface{}) (interface{}, error) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return obj, nil
+	}
+	secret.ManagedFields = nil
+	secret.Annotations = nil
+	secret.StringData = nil
+	if cert, ok := secret.Data[corev1.TLSCertKey]; ok && secret.Type == corev1.SecretTypeTLS {
+		secret.Data = map[string][]byte{corev1.TLSCertKey: cert}
+	} else {
+		secret.Data = nil
+	}
+	return secret, nil
+}
+
+// secretCache hands out the Secrets Ingresses reference, trimmed down to
+// their certificate. A Secret is fetched when it is first asked for, and again
+// once the metadata informer has seen its resourceVersion move on. It is
+// dropped once no Ingress references it any more, so we never hold the
+// Secrets nobody references.
+type secretCache struct {
+	client kubernetes.Interface
+	lister metadatalister.Lister
+
+	mu      sync.Mutex
+	secrets map[types.NamespacedName]*corev1.Secret
+	// refs holds the Secrets each Ingress references, and users the number
+	// of Ingresses referencing each Secret.
+	refs  map[types.NamespacedName][]types.NamespacedName
+	users map[types.NamespacedName]int
+}
+
+func newSecretCache(client kubernetes.Interface, indexer cache.Indexer) *secretCache {
+	return &secretCache{
+		client:  client,
+		lister:  metadatalister.New(indexer, secretsResource),
+		secrets: make(map[types.NamespacedName]*corev1.Secret),
+		refs:    make(map[types.NamespacedName][]types.NamespacedName),
+		users:   make(map[types.NamespacedName]int),
+	}
+}
+
+// retain records the Secrets the Ingress references, replacing those it
+// referenced before, and drops the Secrets no Ingress references any more.
+func (c *secretCache) retain(ing types.NamespacedName, secrets []types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Count the new references first, so the Secrets the Ingress keeps
+	// referencing stay cached.
+	for _, key := range secrets {
+		c.users[key]++
+	}
+	for _, key := range c.refs[ing] {
+		if c.users[key]--; c.users[key] <= 0 {
+			delete(c.users, key)
+			delete(c.secrets, key)
+		}
+	}
+	if len(secrets) == 0 {
+		delete(c.refs, ing)
+	} else {
+		c.refs[ing] = secrets
+	}
+}
+
+// get returns the Secret, or a NotFound error when the informer hasn't seen
+// it.
+func (c *secretCache) get(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	m, err := c.lister.Namespace(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	c.mu.Lock()
+	cached, ok := c.secrets[key]
+	c.mu.Unlock()
+	if ok && cached.UID == m.UID && cached.ResourceVersion == m.ResourceVersion {
+		return cached, nil
+	}
+
+	secret, err := c.client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	trimSecret(secret)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users[key] > 0 {
+		c.secrets[key] = secret
+	}
+	return secret, nil
+}
+
+// forget drops the Secret the informer saw deleted.
+func (c *secretCache) forget(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, ok := obj.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.secrets, types.NamespacedName{Namespace: m.Namespace, Name: m.Name})
+}
+
+// checkTLSSecret tracks the Secret of the TLS section, so the Ingress gets
+// reconciled again when it changes, and checks that it holds a certificate
+// serving the hosts of the section. A valid certificate has the Ingress
+// reconciled again when it expires. It returns the reason and message of a
+// problem with the Secret, if any.
+func (c *Reconciler) checkTLSSecret(ctx context.Context, ing *v1alpha1.Ingress, tls *v1alpha1.IngressTLS) (string, string, error) {
+	if err := c.tracker.TrackReference(tracker.Reference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  tls.SecretNamespace,
+		Name:       tls.SecretName,
+	}, ing); err != nil {
+		return "", "", fmt.Errorf("failed to track Secret %s/%s: %w", tls.SecretNamespace, tls.SecretName, err)
+	}
+
+	secret, err := c.secrets.get(ctx, tls.SecretNamespace, tls.SecretName)
+	if apierrs.IsNotFound(err) {
+		return secretMissingReason, fmt.Sprintf("Secret %s/%s does not exist", tls.SecretNamespace, tls.SecretName), nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	notAfter, reason, message := validateCertificate(secret, tls.Hosts, now)
+	if reason == "" {
+		// Nothing changes when the certificate expires, so come back then.
+		c.enqueueAfter(ing, notAfter.Sub(now))
+	}
+	return reason, message, nil
+}
+
+// validateCertificate checks that the Secret holds a certificate that is
+// valid at the given time for all the given hosts. It returns when the
+// certificate expires, along with the reason and message of a problem, if
+// any.
+func validateCertificate(secret *corev1.Secret, hosts []string, now time.Time) (time.Time, string, string) {
+	name := secret.Namespace + "/" + secret.Name
+	if secret.Type != corev1.SecretTypeTLS {
+		return time.Time{}, secretInvalidReason, fmt.Sprintf("Secret %s is of type %q, not %q", name, secret.Type, corev1.SecretTypeTLS)
+	}
+
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, secretInvalidReason, fmt.Sprintf("Secret %s does not hold a PEM encoded certificate", name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, secretInvalidReason, fmt.Sprintf("Secret %s holds an invalid certificate: %v", name, err)
+	}
+
+	if now.After(cert.NotAfter) {
+		return cert.NotAfter, certificateExpiredReason, fmt.Sprintf("Certificate in Secret %s expired at %s", name, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			return cert.NotAfter, certificateHostMismatchReason, fmt.Sprintf("Certificate in Secret %s is not valid for host %q", name, host)
+		}
+	}
+	return cert.NotAfter, "", ""
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestReconcileTLSSecret(t *testing.T) {
+	secretName := "name-WE-STICK-A-LONG-UID-HERE"
+	nsName := "ns"
+
+	table := TableTest{{
+		Name: "missing secret",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			gw(defaultListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(secretMissingReason, "Secret ns/"+secretName+" does not exist")
+			}),
+		}},
+	}, {
+		Name: "not a TLS secret",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			withSecretType(secret(secretName, nsName), corev1.SecretTypeOpaque),
+			gw(defaultListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(secretInvalidReason,
+					`Secret ns/`+secretName+` is of type "Opaque", not "kubernetes.io/tls"`)
+			}),
+		}},
+	}, {
+		Name: "certificate for another host",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			withCertificate(secret(secretName, nsName), testCertificate(time.Now().Add(time.Hour), "other.example.com")),
+			gw(defaultListener),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(certificateHostMismatchReason,
+					`Certificate in Secret ns/`+secretName+` is not valid for host "secure.example.com"`)
+			}),
+		}},
+	}, {
+		Name: "wildcard certificate",
+		Key:  "ns/name",
+		Objects: []runtime.Object{
+			ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName)),
+			withCertificate(secret(secretName, nsName), testCertificate(time.Now().Add(time.Hour), "*.example.com")),
+			gw(defaultListener, tlsListener("secure.example.com", nsName, secretName)),
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withTLS(secretName))),
+			rp(secret(secretName, nsName)),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withFinalizer, withGatewayAPIClass, withTLS(secretName), readyStatus),
+		}},
+	}}
+
+	table.Test(t, reconcilerFactory(defaultConfig))
+}
+
+func TestValidateCertificate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		secret     *corev1.Secret
+		hosts      []string
+		wantReason string
+	}{{
+		name:   "valid",
+		secret: secret("s", "ns"),
+		hosts:  []string{"secure.example.com"},
+	}, {
+		name:       "expired",
+		secret:     withCertificate(secret("s", "ns"), testCertificate(now.Add(-time.Minute), "secure.example.com")),
+		hosts:      []string{"secure.example.com"},
+		wantReason: certificateExpiredReason,
+	}, {
+		name:       "one of the hosts isn't covered",
+		secret:     secret("s", "ns"),
+		hosts:      []string{"secure.example.com", "insecure.example.com"},
+		wantReason: certificateHostMismatchReason,
+	}, {
+		name:       "no certificate",
+		secret:     withCertificate(secret("s", "ns"), nil),
+		hosts:      []string{"secure.example.com"},
+		wantReason: secretInvalidReason,
+	}, {
+		name:       "garbage certificate",
+		secret:     withCertificate(secret("s", "ns"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})),
+		hosts:      []string{"secure.example.com"},
+		wantReason: secretInvalidReason,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, reason, message := validateCertificate(test.secret, test.hosts, now)
+			if reason != test.wantReason {
+				t.Errorf("validateCertificate() = %q (%s), want: %q", reason, message, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestTrimSecret(t *testing.T) {
+	cert := testCertificate(time.Now().Add(time.Hour), "secure.example.com")
+
+	got, err := trimSecret(withCertificate(secret("s", "ns"), cert))
+	if err != nil {
+		t.Fatal("trimSecret() =", err)
+	}
+	if diff := cmp.Diff(map[string][]byte{corev1.TLSCertKey: cert}, got.(*corev1.Secret).Data); diff != "" {
+		t.Error("TLS Secret data (-want, +got):", diff)
+	}
+
+	got, err = trimSecret(withSecretType(secret("s", "ns"), corev1.SecretTypeOpaque))
+	if err != nil {
+		t.Fatal("trimSecret() =", err)
+	}
+	if data := got.(*corev1.Secret).Data; data != nil {
+		t.Errorf("Opaque Secret data = %v, want: nil", data)
+	}
+}
+
+func TestSecretCache(t *testing.T) {
+	ctx := context.Background()
+	cert := testCertificate(time.Now().Add(time.Hour), "secure.example.com")
+	s := withCertificate(secret("s", "ns"), cert)
+	s.ResourceVersion = "1"
+	client := fakekubeclientset.NewSimpleClientset(s)
+	metadata := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	c := newSecretCache(client, metadata)
+
+	// Secrets the informer hasn't seen aren't fetched.
+	if _, err := c.get(ctx, "ns", "s"); !apierrs.IsNotFound(err) {
+		t.Fatalf("get() = %v, want a NotFound error", err)
+	}
+	gets := func() int {
+		n := 0
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "get" {
+				n++
+			}
+		}
+		return n
+	}
+
+	metadata.Add(&metav1.PartialObjectMetadata{ObjectMeta: s.ObjectMeta})
+	ingKey := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	secretKey := types.NamespacedName{Namespace: "ns", Name: "s"}
+	c.retain(ingKey, []types.NamespacedName{secretKey})
+	for i := 0; i < 2; i++ {
+		got, err := c.get(ctx, "ns", "s")
+		if err != nil {
+			t.Fatal("get() =", err)
+		}
+		if diff := cmp.Diff(map[string][]byte{corev1.TLSCertKey: cert}, got.Data); diff != "" {
+			t.Error("Secret data (-want, +got):", diff)
+		}
+	}
+	if got := gets(); got != 1 {
+		t.Errorf("Secret fetched %d times, want it fetched once", got)
+	}
+
+	// A rotated Secret is fetched again.
+	rotated := testCertificate(time.Now().Add(2*time.Hour), "secure.example.com")
+	s = withCertificate(s.DeepCopy(), rotated)
+	s.ResourceVersion = "2"
+	if _, err := client.CoreV1().Secrets("ns").Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+		t.Fatal("Update() =", err)
+	}
+	metadata.Update(&metav1.PartialObjectMetadata{ObjectMeta: s.ObjectMeta})
+	got, err := c.get(ctx, "ns", "s")
+	if err != nil {
+		t.Fatal("get() =", err)
+	}
+	if diff := cmp.Diff(rotated, got.Data[corev1.TLSCertKey]); diff != "" {
+		t.Error("Rotated certificate (-want, +got):", diff)
+	}
+
+	c.forget(&metav1.PartialObjectMetadata{ObjectMeta: s.ObjectMeta})
+	if len(c.secrets) != 0 {
+		t.Errorf("secrets = %v after forget(), want none", c.secrets)
+	}
+
+	// Secrets go once no Ingress references them.
+	other := types.NamespacedName{Namespace: "ns", Name: "other"}
+	c.retain(other, []types.NamespacedName{secretKey})
+	if _, err := c.get(ctx, "ns", "s"); err != nil {
+		t.Fatal("get() =", err)
+	}
+	c.retain(ingKey, nil)
+	if _, ok := c.secrets[secretKey]; !ok {
+		t.Error("Secret dropped while another Ingress references it")
+	}
+	c.retain(other, nil)
+	if len(c.secrets) != 0 {
+		t.Errorf("secrets = %v once unreferenced, want none", c.secrets)
+	}
+
+	// Nor are the Secrets no Ingress references cached.
+	if _, err := c.get(ctx, "ns", "s"); err != nil {
+		t.Fatal("get() =", err)
+	}
+	if len(c.secrets) != 0 {
+		t.Errorf("secrets = %v after get() of an unreferenced Secret, want none", c.secrets)
+	}
+}
+
+func TestCheckTLSSecretEnqueuesAtExpiry(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, test := range []struct {
+		name        string
+		hosts       []string
+		wantEnqueue bool
+	}{{
+		name:        "valid certificate",
+		hosts:       []string{"secure.example.com"},
+		wantEnqueue: true,
+	}, {
+		name:  "invalid certificate",
+		hosts: []string{"insecure.example.com"},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			s := withCertificate(secret("s", "ns"), testCertificate(notAfter, "secure.example.com"))
+			metadata := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			metadata.Add(&metav1.PartialObjectMetadata{ObjectMeta: s.ObjectMeta})
+
+			var enqueued []time.Duration
+			c := &Reconciler{
+				secrets: newSecretCache(fakekubeclientset.NewSimpleClientset(s), metadata),
+				tracker: &NullTracker{},
+				enqueueAfter: func(_ interface{}, after time.Duration) {
+					enqueued = append(enqueued, after)
+				},
+			}
+			tls := &v1alpha1.IngressTLS{Hosts: test.hosts, SecretNamespace: "ns", SecretName: "s"}
+			start := time.Now()
+			if _, _, err := c.checkTLSSecret(context.Background(), ing(), tls); err != nil {
+				t.Fatal("checkTLSSecret() =", err)
+			}
+
+			if !test.wantEnqueue {
+				if len(enqueued) != 0 {
+					t.Errorf("Enqueued after %v, want no enqueue", enqueued)
+				}
+				return
+			}
+			if len(enqueued) != 1 {
+				t.Fatalf("Enqueued %d times, want once", len(enqueued))
+			}
+			if max := notAfter.Sub(start); enqueued[0] > max || enqueued[0] < max-time.Minute {
+				t.Errorf("Enqueued after %v, want about %v", enqueued[0], max)
+			}
+		})
+	}
+}
+
+func withSecretType(s *corev1.Secret, typ corev1.SecretType) *corev1.Secret {
+	s.Type = typ
+	return s
+}
+
+func withCertificate(s *corev1.Secret, cert []byte) *corev1.Secret {
+	s.Data[corev1.TLSCertKey] = cert
+	return s
+}
+
+// testCertificate returns a PEM encoded self-signed certificate for the given
+// hosts, expiring at the given time.
+func testCertificate(notAfter time.Time, hosts ...string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
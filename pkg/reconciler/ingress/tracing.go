@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"sync"
+
+	"go.opencensus.io/trace"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/status"
+)
+
+// generationTraces ties the reconciles of a KIngress generation together, so
+// that the whole time it takes to become ready shows up in a single trace.
+// The span of each reconcile is a child of the span of the first reconcile
+// of the generation, and links to the span of the previous one. The zero
+// value is ready to use.
+type generationTraces struct {
+	mu    sync.Mutex
+	spans map[types.NamespacedName]generationSpans
+}
+
+type generationSpans struct {
+	generation int64
+	first      trace.SpanContext
+	last       trace.SpanContext
+}
+
+// startReconcile starts the span of a reconcile of the KIngress.
+func (t *generationTraces) startReconcile(ctx context.Context, ing *v1alpha1.Ingress) (context.Context, *trace.Span) {
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans, ok := t.spans[key]
+
+	var span *trace.Span
+	if ok && spans.generation == ing.Generation {
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, "ReconcileKind", spans.first)
+		span.AddLink(trace.Link{TraceID: spans.last.TraceID, SpanID: spans.last.SpanID})
+	} else {
+		ctx, span = trace.StartSpan(ctx, "ReconcileKind")
+		spans = generationSpans{generation: ing.Generation, first: span.SpanContext()}
+	}
+	span.AddAttributes(ingressAttributes(ing)...)
+
+	spans.last = span.SpanContext()
+	if t.spans == nil {
+		t.spans = make(map[types.NamespacedName]generationSpans)
+	}
+	t.spans[key] = spans
+	return ctx, span
+}
+
+// annotate adds a span to the trace of the current generation of the
+// KIngress, for what happens outside of its reconciles.
+func (t *generationTraces) annotate(ing *v1alpha1.Ingress, name, message string) {
+	t.mu.Lock()
+	spans, ok := t.spans[types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}]
+	t.mu.Unlock()
+	if !ok || spans.generation != ing.Generation {
+		return
+	}
+
+	_, span := trace.StartSpanWithRemoteParent(context.Background(), name, spans.first)
+	span.AddAttributes(ingressAttributes(ing)...)
+	span.Annotate(nil, message)
+	span.End()
+}
+
+// forget drops the spans of a KIngress that went away.
+func (t *generationTraces) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.spans, key)
+}
+
+func ingressAttributes(ing *v1alpha1.Ingress) []trace.Attribute {
+	return []trace.Attribute{
+		trace.StringAttribute("kingress.namespace", ing.Namespace),
+		trace.StringAttribute("kingress.name", ing.Name),
+		trace.Int64Attribute("kingress.generation", ing.Generation),
+	}
+}
+
+// endSpan ends the span, recording the error it ended with, if any.
+func endSpan(span *trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	span.End()
+}
+
+// probeTracing traces the checks of the prober.
+type probeTracing struct {
+	status.Manager
+}
+
+var _ status.Manager = (*probeTracing)(nil)
+
+// IsReady implements status.Manager
+func (p *probeTracing) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (ready bool, err error) {
+	ctx, span := trace.StartSpan(ctx, "IsReady")
+	defer func() {
+		span.AddAttributes(trace.BoolAttribute("ready", ready))
+		endSpan(span, err)
+	}()
+	return p.Manager.IsReady(ctx, ing)
+}
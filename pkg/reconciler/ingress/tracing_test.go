@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+// spanRecorder keeps the spans it's handed.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestGenerationTraces(t *testing.T) {
+	var traces generationTraces
+	generation := func(g int64) *v1alpha1.Ingress {
+		return ing(withBasicSpec, func(i *v1alpha1.Ingress) { i.Generation = g })
+	}
+
+	_, first := traces.startReconcile(context.Background(), generation(1))
+	first.End()
+	_, again := traces.startReconcile(context.Background(), generation(1))
+	again.End()
+	if again.SpanContext().TraceID != first.SpanContext().TraceID {
+		t.Error("The reconciles of a generation are in different traces")
+	}
+
+	_, next := traces.startReconcile(context.Background(), generation(2))
+	next.End()
+	if next.SpanContext().TraceID == first.SpanContext().TraceID {
+		t.Error("The reconciles of two generations are in the same trace")
+	}
+
+	traces.forget(types.NamespacedName{Namespace: "ns", Name: "name"})
+	_, restarted := traces.startReconcile(context.Background(), generation(2))
+	restarted.End()
+	if restarted.SpanContext().TraceID == next.SpanContext().TraceID {
+		t.Error("The trace of a forgotten Ingress was continued")
+	}
+}
+
+func TestReconcileKindSpan(t *testing.T) {
+	spans := &spanRecorder{}
+	trace.RegisterExporter(spans)
+	t.Cleanup(func() { trace.UnregisterExporter(spans) })
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	t.Cleanup(func() { trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)}) })
+
+	theError := errors.New("this is the error")
+	tests := []struct {
+		name     string
+		isReady  func(context.Context, *v1alpha1.Ingress) (bool, error)
+		wantCode int32
+	}{{
+		name: "failed reconcile",
+		isReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
+			return false, theError
+		},
+		wantCode: trace.StatusCodeUnknown,
+	}, {
+		name: "panicking reconcile",
+		isReady: func(context.Context, *v1alpha1.Ingress) (bool, error) {
+			panic(theError)
+		},
+		wantCode: trace.StatusCodeOK,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := SetupFakeContext(t, withManagedBySelector)
+			ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+			ctx = (&testConfigStore{config: defaultConfig}).ToContext(ctx)
+			ingress := ing(withBasicSpec, withGatewayAPIClass)
+			listers := NewListers(append([]runtime.Object{ingress}, servicesAndEndpoints...))
+
+			spans.mu.Lock()
+			spans.spans = nil
+			spans.mu.Unlock()
+
+			r := newTestReconciler(ctx, &listers)
+			r.statusManager = &fakeStatusManager{FakeIsReady: test.isReady}
+			func() {
+				defer func() { recover() }()
+				r.ReconcileKind(ctx, ingress)
+			}()
+
+			spans.mu.Lock()
+			defer spans.mu.Unlock()
+			for _, span := range spans.spans {
+				if span.Name != "ReconcileKind" {
+					continue
+				}
+				if span.Status.Code != test.wantCode {
+					t.Errorf("ReconcileKind span status = %d, want: %d", span.Status.Code, test.wantCode)
+				}
+				return
+			}
+			t.Error("ReconcileKind span was never ended")
+		})
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+)
+
+// unsupportedFieldsReason is the reason set on Ingresses with fields the
+// HTTPRoutes can't honor, and of the warning events about them.
+const unsupportedFieldsReason = "UnsupportedFields"
+
+// unsupportedFields returns what the HTTPRoutes of the Ingress can't honor,
+// or only in part, one entry per field.
+func unsupportedFields(ing *v1alpha1.Ingress) []string {
+	var problems []string
+	if ing.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected {
+		problems = append(problems, "httpOption Redirected is not supported, plain HTTP is served instead of redirected")
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		host := resources.LongestHost(rule.Hosts)
+		for _, path := range rule.HTTP.Paths {
+			where := fmt.Sprintf("path %q of host %q", pathOrRoot(path.Path), host)
+
+			if path.RewriteHost != "" {
+				problems = append(problems, fmt.Sprintf("%s: rewriteHost is not supported", where))
+			}
+
+			names := make([]string, 0, len(path.Headers))
+			for name := range path.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if path.Headers[name].Exact == "" {
+					problems = append(problems, fmt.Sprintf("%s: header %q has no exact value, only exact header matches are supported", where, name))
+				}
+			}
+
+			for _, split := range path.Splits {
+				if split.ServiceNamespace != "" && split.ServiceNamespace != ing.Namespace {
+					problems = append(problems, fmt.Sprintf("%s: backend %s/%s is not in the namespace of the Ingress, which is not supported",
+						where, split.ServiceNamespace, split.ServiceName))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// validateIngress checks the Ingress for fields the HTTPRoutes can't honor.
+// In strict mode, it fails the Ingress with the list of them and reports
+// that it is done with it. Otherwise it warns about them with an event, once
+// per generation of the Ingress rather than on every resync.
+func validateIngress(ctx context.Context, ing *v1alpha1.Ingress) bool {
+	problems := unsupportedFields(ing)
+	if len(problems) == 0 {
+		return false
+	}
+
+	message := strings.Join(problems, "; ")
+	if config.FromContext(ctx).Gateway.Validation == config.ValidationStrict {
+		ing.Status.MarkIngressNotReady(unsupportedFieldsReason, message)
+		return true
+	}
+	// The observed generation only catches up once we are done with this
+	// reconcile, so it still tells whether we have warned about this one.
+	if ing.Status.ObservedGeneration != ing.Generation {
+		controller.GetEventRecorder(ctx).Event(ing, corev1.EventTypeWarning, unsupportedFieldsReason, message)
+	}
+	return false
+}
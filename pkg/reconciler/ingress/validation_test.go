@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgotesting "k8s.io/client-go/testing"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+
+	. "knative.dev/net-gateway-api/pkg/reconciler/testing"
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+const rewriteHostProblem = `path "/" of host "example.com": rewriteHost is not supported`
+
+func TestUnsupportedFields(t *testing.T) {
+	tests := []struct {
+		name string
+		ing  *v1alpha1.Ingress
+		want []string
+	}{{
+		name: "nothing unsupported",
+		ing:  ing(withBasicSpec, withInternalSpec),
+	}, {
+		name: "redirected",
+		ing:  ing(withBasicSpec, withHTTPOption(v1alpha1.HTTPOptionRedirected)),
+		want: []string{"httpOption Redirected is not supported, plain HTTP is served instead of redirected"},
+	}, {
+		name: "rewrite host",
+		ing:  ing(withBasicSpec, withRewriteHost),
+		want: []string{rewriteHostProblem},
+	}, {
+		name: "header match without exact value",
+		ing: ing(withBasicSpec, func(i *v1alpha1.Ingress) {
+			i.Spec.Rules[0].HTTP.Paths[0].Path = "/api"
+			i.Spec.Rules[0].HTTP.Paths[0].Headers = map[string]v1alpha1.HeaderMatch{
+				"b-tag": {},
+				"a-tag": {},
+				"exact": {Exact: "yes"},
+			}
+		}),
+		want: []string{
+			`path "/api" of host "example.com": header "a-tag" has no exact value, only exact header matches are supported`,
+			`path "/api" of host "example.com": header "b-tag" has no exact value, only exact header matches are supported`,
+		},
+	}, {
+		name: "backend in another namespace",
+		ing: ing(withBasicSpec, func(i *v1alpha1.Ingress) {
+			i.Spec.Rules[0].HTTP.Paths[0].Splits[0].ServiceNamespace = "elsewhere"
+		}),
+		want: []string{`path "/" of host "example.com": backend elsewhere/goo is not in the namespace of the Ingress, which is not supported`},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, unsupportedFields(test.ing)); diff != "" {
+				t.Error("unsupportedFields() (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileValidation(t *testing.T) {
+	table := TableTest{{
+		Name: "strict mode fails the Ingress",
+		Key:  "ns/name",
+		Ctx:  withValidation(config.ValidationStrict),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer),
+		}, servicesAndEndpoints...),
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer, func(i *v1alpha1.Ingress) {
+				i.Status.InitializeConditions()
+				i.Status.MarkIngressNotReady(unsupportedFieldsReason, rewriteHostProblem)
+			}),
+		}},
+	}, {
+		Name: "warn mode reconciles the Ingress",
+		Key:  "ns/name",
+		Ctx:  withValidation(config.ValidationWarn),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer, withGeneration(1)),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withRewriteHost)),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer, withGeneration(1), readyStatus, withObservedGeneration(1)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, unsupportedFieldsReason, rewriteHostProblem),
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}, {
+		Name: "warn mode warns once per generation",
+		Key:  "ns/name",
+		Ctx:  withValidation(config.ValidationWarn),
+		Objects: append([]runtime.Object{
+			ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer, withGeneration(1), withObservedGeneration(1)),
+		}, servicesAndEndpoints...),
+		WantCreates: []runtime.Object{
+			httpRoute(t, ing(withBasicSpec, withGatewayAPIClass, withRewriteHost)),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: ing(withBasicSpec, withGatewayAPIClass, withRewriteHost, withFinalizer, withGeneration(1), readyStatus, withObservedGeneration(1)),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "Created", `Created HTTPRoute "example.com"`),
+		},
+	}}
+
+	table.Test(t, GatewayFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
+		cfg := defaultConfig.DeepCopy()
+		cfg.Gateway.Validation = ctx.Value(validationKey{}).(config.ValidationMode)
+		return newTestIngressReconciler(ctx, listers, newTestReconciler(ctx, listers), cfg)
+	}))
+}
+
+type validationKey struct{}
+
+func withValidation(mode config.ValidationMode) context.Context {
+	return context.WithValue(context.Background(), validationKey{}, mode)
+}
+
+func withGeneration(g int64) IngressOption {
+	return func(i *v1alpha1.Ingress) {
+		i.Generation = g
+	}
+}
+
+func withObservedGeneration(g int64) IngressOption {
+	return func(i *v1alpha1.Ingress) {
+		i.Status.ObservedGeneration = g
+	}
+}
+
+func withRewriteHost(i *v1alpha1.Ingress) {
+	i.Spec.Rules[0].HTTP.Paths[0].RewriteHost = "goo.ns.svc.cluster.local"
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	typedv1alpha2 "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/typed/apis/v1alpha2"
+	typedv1beta1 "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/typed/apis/v1beta1"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/resources"
+)
+
+// newVersionedClient returns a Gateway API clientset whose v1beta1
+// HTTPRoutes and Gateways are read and written in the given versions,
+// converting from and to v1beta1 as needed. Its ReferenceGrants are
+// ReferencePolicies on clusters that don't serve the former.
+func newVersionedClient(client gatewayclientset.Interface, versions resources.APIVersions) gatewayclientset.Interface {
+	if versions.HTTPRoute != resources.V1alpha2 && versions.Gateway != resources.V1alpha2 &&
+		!versions.UsesReferencePolicies() {
+		return client
+	}
+	return &versionedClient{Interface: client, versions: versions}
+}
+
+type versionedClient struct {
+	gatewayclientset.Interface
+	versions resources.APIVersions
+}
+
+func (c *versionedClient) GatewayV1beta1() typedv1beta1.GatewayV1beta1Interface {
+	return &versionedV1beta1{
+		GatewayV1beta1Interface: c.Interface.GatewayV1beta1(),
+		alpha:                   c.Interface.GatewayV1alpha2(),
+		versions:                c.versions,
+	}
+}
+
+func (c *versionedClient) GatewayV1alpha2() typedv1alpha2.GatewayV1alpha2Interface {
+	return &versionedV1alpha2{
+		GatewayV1alpha2Interface: c.Interface.GatewayV1alpha2(),
+		versions:                 c.versions,
+	}
+}
+
+type versionedV1beta1 struct {
+	typedv1beta1.GatewayV1beta1Interface
+	alpha    typedv1alpha2.GatewayV1alpha2Interface
+	versions resources.APIVersions
+}
+
+func (c *versionedV1beta1) HTTPRoutes(namespace string) typedv1beta1.HTTPRouteInterface {
+	if c.versions.HTTPRoute != resources.V1alpha2 {
+		return c.GatewayV1beta1Interface.HTTPRoutes(namespace)
+	}
+	return &v1alpha2HTTPRoutes{client: c.alpha.HTTPRoutes(namespace)}
+}
+
+func (c *versionedV1beta1) Gateways(namespace string) typedv1beta1.GatewayInterface {
+	if c.versions.Gateway != resources.V1alpha2 {
+		return c.GatewayV1beta1Interface.Gateways(namespace)
+	}
+	return &v1alpha2Gateways{client: c.alpha.Gateways(namespace)}
+}
+
+type versionedV1alpha2 struct {
+	typedv1alpha2.GatewayV1alpha2Interface
+	versions resources.APIVersions
+}
+
+func (c *versionedV1alpha2) ReferenceGrants(namespace string) typedv1alpha2.ReferenceGrantInterface {
+	if !c.versions.UsesReferencePolicies() {
+		return c.GatewayV1alpha2Interface.ReferenceGrants(namespace)
+	}
+	return &referencePolicies{client: c.GatewayV1alpha2Interface.ReferencePolicies(namespace)}
+}
+
+// v1alpha2HTTPRoutes serves v1beta1 HTTPRoutes out of v1alpha2 ones.
+type v1alpha2HTTPRoutes struct {
+	client typedv1alpha2.HTTPRouteInterface
+}
+
+var _ typedv1beta1.HTTPRouteInterface = (*v1alpha2HTTPRoutes)(nil)
+
+func (c *v1alpha2HTTPRoutes) Create(ctx context.Context, route *gatewayapi.HTTPRoute, opts metav1.CreateOptions) (*gatewayapi.HTTPRoute, error) {
+	alpha, err := resources.HTTPRouteToV1alpha2(route)
+	if err != nil {
+		return nil, err
+	}
+	return readHTTPRoute(c.client.Create(ctx, alpha, opts))
+}
+
+func (c *v1alpha2HTTPRoutes) Update(ctx context.Context, route *gatewayapi.HTTPRoute, opts metav1.UpdateOptions) (*gatewayapi.HTTPRoute, error) {
+	alpha, err := resources.HTTPRouteToV1alpha2(route)
+	if err != nil {
+		return nil, err
+	}
+	return readHTTPRoute(c.client.Update(ctx, alpha, opts))
+}
+
+func (c *v1alpha2HTTPRoutes) UpdateStatus(ctx context.Context, route *gatewayapi.HTTPRoute, opts metav1.UpdateOptions) (*gatewayapi.HTTPRoute, error) {
+	alpha, err := resources.HTTPRouteToV1alpha2(route)
+	if err != nil {
+		return nil, err
+	}
+	return readHTTPRoute(c.client.UpdateStatus(ctx, alpha, opts))
+}
+
+func (c *v1alpha2HTTPRoutes) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete(ctx, name, opts)
+}
+
+func (c *v1alpha2HTTPRoutes) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	return c.client.DeleteCollection(ctx, opts, listOpts)
+}
+
+func (c *v1alpha2HTTPRoutes) Get(ctx context.Context, name string, opts metav1.GetOptions) (*gatewayapi.HTTPRoute, error) {
+	return readHTTPRoute(c.client.Get(ctx, name, opts))
+}
+
+func (c *v1alpha2HTTPRoutes) List(ctx context.Context, opts metav1.ListOptions) (*gatewayapi.HTTPRouteList, error) {
+	list, err := c.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resources.HTTPRouteListFromV1alpha2(list)
+}
+
+func (c *v1alpha2HTTPRoutes) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return convertWatch(c.client.Watch(ctx, opts))(httpRouteFromV1alpha2)
+}
+
+func (c *v1alpha2HTTPRoutes) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*gatewayapi.HTTPRoute, error) {
+	return readHTTPRoute(c.client.Patch(ctx, name, pt, data, opts, subresources...))
+}
+
+func readHTTPRoute(route *gatewayv1alpha2.HTTPRoute, err error) (*gatewayapi.HTTPRoute, error) {
+	if err != nil {
+		return nil, err
+	}
+	return resources.HTTPRouteFromV1alpha2(route)
+}
+
+// v1alpha2Gateways serves v1beta1 Gateways out of v1alpha2 ones.
+type v1alpha2Gateways struct {
+	client typedv1alpha2.GatewayInterface
+}
+
+var _ typedv1beta1.GatewayInterface = (*v1alpha2Gateways)(nil)
+
+func (c *v1alpha2Gateways) Create(ctx context.Context, gw *gatewayapi.Gateway, opts metav1.CreateOptions) (*gatewayapi.Gateway, error) {
+	alpha, err := resources.GatewayToV1alpha2(gw)
+	if err != nil {
+		return nil, err
+	}
+	return readGateway(c.client.Create(ctx, alpha, opts))
+}
+
+func (c *v1alpha2Gateways) Update(ctx context.Context, gw *gatewayapi.Gateway, opts metav1.UpdateOptions) (*gatewayapi.Gateway, error) {
+	alpha, err := resources.GatewayToV1alpha2(gw)
+	if err != nil {
+		return nil, err
+	}
+	return readGateway(c.client.Update(ctx, alpha, opts))
+}
+
+func (c *v1alpha2Gateways) UpdateStatus(ctx context.Context, gw *gatewayapi.Gateway, opts metav1.UpdateOptions) (*gatewayapi.Gateway, error) {
+	alpha, err := resources.GatewayToV1alpha2(gw)
+	if err != nil {
+		return nil, err
+	}
+	return readGateway(c.client.UpdateStatus(ctx, alpha, opts))
+}
+
+func (c *v1alpha2Gateways) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete(ctx, name, opts)
+}
+
+func (c *v1alpha2Gateways) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	return c.client.DeleteCollection(ctx, opts, listOpts)
+}
+
+func (c *v1alpha2Gateways) Get(ctx context.Context, name string, opts metav1.GetOptions) (*gatewayapi.Gateway, error) {
+	return readGateway(c.client.Get(ctx, name, opts))
+}
+
+func (c *v1alpha2Gateways) List(ctx context.Context, opts metav1.ListOptions) (*gatewayapi.GatewayList, error) {
+	list, err := c.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resources.GatewayListFromV1alpha2(list)
+}
+
+func (c *v1alpha2Gateways) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return convertWatch(c.client.Watch(ctx, opts))(gatewayFromV1alpha2)
+}
+
+func (c *v1alpha2Gateways) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*gatewayapi.Gateway, error) {
+	return readGateway(c.client.Patch(ctx, name, pt, data, opts, subresources...))
+}
+
+func readGateway(gw *gatewayv1alpha2.Gateway, err error) (*gatewayapi.Gateway, error) {
+	if err != nil {
+		return nil, err
+	}
+	return resources.GatewayFromV1alpha2(gw)
+}
+
+// referencePolicies serves ReferenceGrants out of ReferencePolicies.
+type referencePolicies struct {
+	client typedv1alpha2.ReferencePolicyInterface
+}
+
+var _ typedv1alpha2.ReferenceGrantInterface = (*referencePolicies)(nil)
+
+func (c *referencePolicies) Create(ctx context.Context, grant *gatewayv1alpha2.ReferenceGrant, opts metav1.CreateOptions) (*gatewayv1alpha2.ReferenceGrant, error) {
+	return readReferencePolicy(c.client.Create(ctx, resources.ReferenceGrantToPolicy(grant), opts))
+}
+
+func (c *referencePolicies) Update(ctx context.Context, grant *gatewayv1alpha2.ReferenceGrant, opts metav1.UpdateOptions) (*gatewayv1alpha2.ReferenceGrant, error) {
+	return readReferencePolicy(c.client.Update(ctx, resources.ReferenceGrantToPolicy(grant), opts))
+}
+
+func (c *referencePolicies) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete(ctx, name, opts)
+}
+
+func (c *referencePolicies) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	return c.client.DeleteCollection(ctx, opts, listOpts)
+}
+
+func (c *referencePolicies) Get(ctx context.Context, name string, opts metav1.GetOptions) (*gatewayv1alpha2.ReferenceGrant, error) {
+	return readReferencePolicy(c.client.Get(ctx, name, opts))
+}
+
+func (c *referencePolicies) List(ctx context.Context, opts metav1.ListOptions) (*gatewayv1alpha2.ReferenceGrantList, error) {
+	list, err := c.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	grants := &gatewayv1alpha2.ReferenceGrantList{ListMeta: list.ListMeta}
+	for i := range list.Items {
+		grants.Items = append(grants.Items, *resources.ReferenceGrantFromPolicy(&list.Items[i]))
+	}
+	return grants, nil
+}
+
+func (c *referencePolicies) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return convertWatch(c.client.Watch(ctx, opts))(referenceGrantFromPolicy)
+}
+
+func (c *referencePolicies) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*gatewayv1alpha2.ReferenceGrant, error) {
+	return readReferencePolicy(c.client.Patch(ctx, name, pt, data, opts, subresources...))
+}
+
+func readReferencePolicy(policy *gatewayv1alpha2.ReferencePolicy, err error) (*gatewayv1alpha2.ReferenceGrant, error) {
+	if err != nil {
+		return nil, err
+	}
+	return resources.ReferenceGrantFromPolicy(policy), nil
+}
+
+// convertWatch returns a function converting the objects of the watch with
+// the given transform. Objects that fail to convert turn into error events.
+func convertWatch(w watch.Interface, err error) func(cache.TransformFunc) (watch.Interface, error) {
+	return func(transform cache.TransformFunc) (watch.Interface, error) {
+		if err != nil {
+			return nil, err
+		}
+		return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+			if in.Type == watch.Error {
+				return in, true
+			}
+			obj, err := transform(in.Object)
+			if err != nil {
+				return watch.Event{Type: watch.Error, Object: &apierrs.NewInternalError(err).ErrStatus}, true
+			}
+			in.Object = obj.(runtime.Object)
+			return in, true
+		}), nil
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package configmaps validates the gateway ConfigMaps when they are applied,
+// rather than when the controller loads them, with the ConfigMap admission
+// controller of knative.dev/pkg. It adds warnings about the Gateways and
+// Services they reference that don't exist.
+package configmaps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	gatewayclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	pkgwebhook "knative.dev/pkg/webhook"
+)
+
+// admissionController is what the ConfigMap admission controller of
+// knative.dev/pkg implements.
+type admissionController interface {
+	controller.Reconciler
+	pkgreconciler.LeaderAware
+	pkgwebhook.AdmissionController
+}
+
+// warningAdmissionController warns about the missing references of the
+// gateway ConfigMaps the admission controller it wraps lets through.
+type warningAdmissionController struct {
+	admissionController
+	pkgwebhook.StatelessAdmissionImpl
+
+	// gatewayConfigNames are the names of the gateway ConfigMaps, one per
+	// controller.
+	gatewayConfigNames sets.String
+
+	kubeclient  kubernetes.Interface
+	gwapiclient gatewayclientset.Interface
+}
+
+var (
+	_ admissionController                     = (*warningAdmissionController)(nil)
+	_ pkgwebhook.StatelessAdmissionController = (*warningAdmissionController)(nil)
+)
+
+// Admit implements AdmissionController. It adds warnings about the Gateways
+// and Services a valid gateway ConfigMap references that don't exist.
+func (ac *warningAdmissionController) Admit(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := ac.admissionController.Admit(ctx, request)
+	if !resp.Allowed || (request.Operation != admissionv1.Create && request.Operation != admissionv1.Update) {
+		return resp
+	}
+
+	var cm corev1.ConfigMap
+	if err := json.Unmarshal(request.Object.Raw, &cm); err != nil || !ac.gatewayConfigNames.Has(cm.Name) {
+		return resp
+	}
+	gateway, err := config.NewGatewayFromConfigMap(&cm)
+	if err != nil {
+		// It was validated already.
+		return resp
+	}
+	resp.Warnings = append(resp.Warnings, ac.missingReferences(ctx, gateway)...)
+	return resp
+}
+
+// missingReferences lists the Gateways and Services the configuration
+// references that don't exist. Failing to look them up isn't a reason to
+// reject the configuration, so those errors are only logged.
+func (ac *warningAdmissionController) missingReferences(ctx context.Context, gateway *config.Gateway) []string {
+	logger := logging.FromContext(ctx)
+
+	var warnings []string
+	gateways := make(map[types.NamespacedName]struct{})
+	services := make(map[types.NamespacedName]struct{})
+	addServices := func(gateways map[v1alpha1.IngressVisibility]config.GatewayConfig) {
+		for _, gw := range gateways {
+			if gw.Service != nil {
+				services[*gw.Service] = struct{}{}
+			}
+		}
+	}
+	for _, vis := range []v1alpha1.IngressVisibility{
+		v1alpha1.IngressVisibilityClusterLocal,
+		v1alpha1.IngressVisibilityExternalIP,
+	} {
+		for _, name := range gateway.AllGatewayPools(vis) {
+			gateways[name] = struct{}{}
+		}
+	}
+	addServices(gateway.Gateways)
+	for _, profile := range gateway.Profiles {
+		addServices(profile)
+	}
+
+	for _, name := range sortedNames(gateways) {
+		if exists, err := ac.gatewayExists(ctx, name); err != nil {
+			logger.Warnw("Failed to look up Gateway "+name.String(), zap.Error(err))
+		} else if !exists {
+			warnings = append(warnings, fmt.Sprintf("Gateway %s does not exist", name))
+		}
+	}
+	for _, name := range sortedNames(services) {
+		_, err := ac.kubeclient.CoreV1().Services(name.Namespace).Get(ctx, name.Name, metav1.GetOptions{})
+		if apierrs.IsNotFound(err) {
+			warnings = append(warnings, fmt.Sprintf("Service %s does not exist", name))
+		} else if err != nil {
+			logger.Warnw("Failed to look up Service "+name.String(), zap.Error(err))
+		}
+	}
+	return warnings
+}
+
+// gatewayExists looks the Gateway up in either version, as clusters may
+// serve only v1alpha2.
+func (ac *warningAdmissionController) gatewayExists(ctx context.Context, name types.NamespacedName) (bool, error) {
+	_, err := ac.gwapiclient.GatewayV1beta1().Gateways(name.Namespace).Get(ctx, name.Name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = ac.gwapiclient.GatewayV1alpha2().Gateways(name.Namespace).Get(ctx, name.Name, metav1.GetOptions{})
+	}
+	if apierrs.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func sortedNames(set map[types.NamespacedName]struct{}) []types.NamespacedName {
+	names := make([]types.NamespacedName, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].String() < names[j].String()
+	})
+	return names
+}
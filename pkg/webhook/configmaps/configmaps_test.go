@@ -0,0 +1,264 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmaps
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	fakegwapiclient "knative.dev/net-gateway-api/pkg/client/injection/client/fake"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	fakevwcinformer "knative.dev/pkg/client/injection/kube/informers/admissionregistration/v1/validatingwebhookconfiguration/fake"
+	"knative.dev/pkg/controller"
+	fakesecretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret/fake"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/system"
+	pkgwebhook "knative.dev/pkg/webhook"
+	certresources "knative.dev/pkg/webhook/certificates/resources"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+const secretName = "webhook-certs"
+
+const visibility = `
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway
+ClusterLocal:
+  class: istio
+  gateway: istio-system/knative-local-gateway
+  service: istio-system/knative-local-gateway`
+
+func TestAdmit(t *testing.T) {
+	existing := []*corev1.Service{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "istio-ingressgateway"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "knative-local-gateway"}},
+	}
+	gateways := []*gatewayapi.Gateway{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "knative-gateway"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "knative-local-gateway"}},
+	}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		cmName    string
+		data      map[string]string
+		wantErr   string
+		warnings  []string
+	}{{
+		name: "valid",
+		data: map[string]string{"visibility": visibility},
+	}, {
+		name: "missing ClusterLocal",
+		data: map[string]string{"visibility": `
+ExternalIP:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway`},
+		wantErr: `visibility "ClusterLocal" must not be empty`,
+	}, {
+		name:    "bad gateway name",
+		data:    map[string]string{"visibility": strings.Replace(visibility, "istio-system/knative-gateway", "knative-gateway", 1)},
+		wantErr: `missing namespace or name in "knative-gateway"`,
+	}, {
+		name: "unknown visibility",
+		data: map[string]string{"visibility": visibility + `
+Internal:
+  class: istio
+  gateway: istio-system/knative-gateway
+  service: istio-system/istio-ingressgateway`},
+		wantErr: `unrecognized visibility: "Internal"`,
+	}, {
+		name: "missing references",
+		data: map[string]string{"visibility": strings.NewReplacer(
+			"istio-system/knative-gateway", "istio-system/gone-gateway",
+			"istio-system/istio-ingressgateway", "istio-system/gone-service",
+		).Replace(visibility)},
+		warnings: []string{
+			"Gateway istio-system/gone-gateway does not exist",
+			"Service istio-system/gone-service does not exist",
+		},
+	}, {
+		name:    "the ConfigMap of another controller",
+		cmName:  "config-gateway-contour",
+		data:    map[string]string{"visibility": "not even YAML: ["},
+		wantErr: "error converting YAML to JSON",
+	}, {
+		name:   "another ConfigMap",
+		cmName: "config-network",
+		data:   map[string]string{"visibility": "not even YAML: ["},
+	}, {
+		name:      "deletion",
+		operation: admissionv1.Delete,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, ac := newTestAdmissionController(t, config.GatewayConfigName, "config-gateway-contour")
+			for _, svc := range existing {
+				if _, err := fakekubeclient.Get(ctx).CoreV1().Services(svc.Namespace).Create(ctx, svc, metav1.CreateOptions{}); err != nil {
+					t.Fatal("Failed to create Service:", err)
+				}
+			}
+			// The fake tracker files objects passed to NewSimpleClientset under
+			// the first version of the kind, so the Gateways are created.
+			for _, gw := range gateways {
+				if _, err := fakegwapiclient.Get(ctx).GatewayV1beta1().Gateways(gw.Namespace).Create(ctx, gw, metav1.CreateOptions{}); err != nil {
+					t.Fatal("Failed to create Gateway:", err)
+				}
+			}
+
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "knative-serving", Name: config.GatewayConfigName},
+				Data:       test.data,
+			}
+			if test.cmName != "" {
+				cm.Name = test.cmName
+			}
+			raw, err := json.Marshal(cm)
+			if err != nil {
+				t.Fatal("json.Marshal() =", err)
+			}
+			operation := admissionv1.Update
+			if test.operation != "" {
+				operation = test.operation
+			}
+
+			resp := ac.Admit(ctx, &admissionv1.AdmissionRequest{
+				Operation: operation,
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+				Object:    runtime.RawExtension{Raw: raw},
+			})
+			if test.wantErr != "" {
+				if resp.Allowed {
+					t.Fatalf("Admit() allowed it, want an error containing %q", test.wantErr)
+				}
+				if got := resp.Result.Message; !strings.Contains(got, test.wantErr) {
+					t.Errorf("Admit() = %q, want an error containing %q", got, test.wantErr)
+				}
+				return
+			}
+			if !resp.Allowed {
+				t.Fatalf("Admit() = %v, want it allowed", resp.Result)
+			}
+			if diff := cmp.Diff(test.warnings, resp.Warnings); diff != "" {
+				t.Error("Warnings (-want, +got):", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileValidatingWebhook(t *testing.T) {
+	ctx, ac := newTestAdmissionController(t)
+
+	vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: WebhookName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: WebhookName,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Namespace: system.Namespace(), Name: "webhook"},
+			},
+		}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: secretName},
+		Data:       map[string][]byte{certresources.CACert: []byte("ca")},
+	}
+	kubeclient := fakekubeclient.Get(ctx)
+	kubeclient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: system.Namespace()}}, metav1.CreateOptions{})
+	kubeclient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(ctx, vwc, metav1.CreateOptions{})
+	fakevwcinformer.Get(ctx).Informer().GetIndexer().Add(vwc)
+	fakesecretinformer.Get(ctx).Informer().GetIndexer().Add(secret)
+
+	promote(t, ac)
+	if err := ac.Reconcile(ctx, ""); err != nil {
+		t.Fatal("Reconcile() =", err)
+	}
+
+	got, err := kubeclient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, WebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get the ValidatingWebhookConfiguration:", err)
+	}
+	wh := got.Webhooks[0]
+	if string(wh.ClientConfig.CABundle) != "ca" {
+		t.Errorf("CABundle = %q, want the CA of the Secret", wh.ClientConfig.CABundle)
+	}
+	if wh.ClientConfig.Service.Path == nil || *wh.ClientConfig.Service.Path != path {
+		t.Errorf("Path = %v, want: %s", wh.ClientConfig.Service.Path, path)
+	}
+}
+
+func TestSecretReconciler(t *testing.T) {
+	ctx, _ := SetupFakeContext(t)
+	ctx = pkgwebhook.WithOptions(ctx, pkgwebhook.Options{ServiceName: "webhook", SecretName: secretName})
+	r := NewSecretController(ctx, nil).Reconciler
+	key := types.NamespacedName{Namespace: system.Namespace(), Name: secretName}
+
+	if err := r.Reconcile(ctx, key.String()); !controller.IsSkipKey(err) {
+		t.Errorf("Reconcile() = %v before being promoted, want it skipped", err)
+	}
+	promote(t, r)
+
+	// A deleted Secret comes back, for the certificate controller to fill in.
+	if err := r.Reconcile(ctx, key.String()); err != nil {
+		t.Fatal("Reconcile() =", err)
+	}
+	secret, err := fakekubeclient.Get(ctx).CoreV1().Secrets(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get the Secret:", err)
+	}
+
+	// An existing one is left alone.
+	fakesecretinformer.Get(ctx).Informer().GetIndexer().Add(secret)
+	fakekubeclient.Get(ctx).ClearActions()
+	if err := r.Reconcile(ctx, key.String()); err != nil {
+		t.Fatal("Reconcile() =", err)
+	}
+	if actions := fakekubeclient.Get(ctx).Actions(); len(actions) != 0 {
+		t.Errorf("Actions = %v, want none", actions)
+	}
+}
+
+func newTestAdmissionController(t *testing.T, names ...string) (context.Context, admissionController) {
+	t.Helper()
+	ctx, _ := SetupFakeContext(t)
+	ctx = pkgwebhook.WithOptions(ctx, pkgwebhook.Options{ServiceName: "webhook", SecretName: secretName})
+	ctx = WithGatewayConfigNames(ctx, names...)
+	return ctx, NewController(ctx, nil).Reconciler.(admissionController)
+}
+
+func promote(t *testing.T, r controller.Reconciler) {
+	t.Helper()
+	if err := r.(pkgreconciler.LeaderAware).Promote(pkgreconciler.UniversalBucket(), func(pkgreconciler.Bucket, types.NamespacedName) {}); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmaps
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	gwapiclient "knative.dev/net-gateway-api/pkg/client/injection/client"
+	"knative.dev/net-gateway-api/pkg/reconciler/ingress/config"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	pkgconfigmaps "knative.dev/pkg/webhook/configmaps"
+)
+
+const (
+	// WebhookName is the name of the ValidatingWebhookConfiguration, and of
+	// its webhook, config/webhook.yaml installs.
+	WebhookName = "config.webhook.gateway-api.networking.internal.knative.dev"
+
+	// path is where the webhook serves the admission reviews.
+	path = "/config-validation"
+)
+
+type gatewayConfigNamesKey struct{}
+
+// WithGatewayConfigNames sets the names of the gateway ConfigMaps to
+// validate, one per controller. It defaults to config-gateway.
+func WithGatewayConfigNames(ctx context.Context, names ...string) context.Context {
+	return context.WithValue(ctx, gatewayConfigNamesKey{}, names)
+}
+
+func gatewayConfigNames(ctx context.Context) []string {
+	if names, _ := ctx.Value(gatewayConfigNamesKey{}).([]string); len(names) > 0 {
+		return names
+	}
+	return []string{config.GatewayConfigName}
+}
+
+// NewController returns the admission controller validating the gateway
+// ConfigMaps. It keeps the ValidatingWebhookConfiguration pointing at it,
+// trusting the certificate of the Secret the webhook.Options of the context
+// name.
+func NewController(
+	ctx context.Context,
+	_ configmap.Watcher,
+) *controller.Impl {
+	names := gatewayConfigNames(ctx)
+	constructors := make(configmap.Constructors, len(names))
+	for _, name := range names {
+		constructors[name] = config.NewGatewayFromConfigMap
+	}
+
+	impl := pkgconfigmaps.NewAdmissionController(ctx, WebhookName, path, constructors)
+	impl.Reconciler = &warningAdmissionController{
+		admissionController: impl.Reconciler.(admissionController),
+		gatewayConfigNames:  sets.NewString(names...),
+		kubeclient:          kubeclient.Get(ctx),
+		gwapiclient:         gwapiclient.Get(ctx),
+	}
+	return impl
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmaps
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	secretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/system"
+	pkgwebhook "knative.dev/pkg/webhook"
+)
+
+// providerLabels are the labels config/webhook.yaml puts on the Secret.
+var providerLabels = map[string]string{
+	"networking.knative.dev/ingress-provider": "net-gateway-api",
+	"app.kubernetes.io/component":             "net-gateway-api",
+	"app.kubernetes.io/name":                  "knative-serving",
+}
+
+// secretReconciler brings the Secret the webhook serves with back, empty,
+// when it's deleted. The certificate controller of knative.dev/pkg only fills
+// in a Secret that exists.
+type secretReconciler struct {
+	pkgreconciler.LeaderAwareFuncs
+
+	key          types.NamespacedName
+	kubeclient   kubernetes.Interface
+	secretLister corev1listers.SecretLister
+}
+
+var (
+	_ controller.Reconciler     = (*secretReconciler)(nil)
+	_ pkgreconciler.LeaderAware = (*secretReconciler)(nil)
+)
+
+// NewSecretController returns the controller keeping the Secret the
+// webhook.Options of the context name around, for certificates.NewController
+// to fill in.
+func NewSecretController(
+	ctx context.Context,
+	_ configmap.Watcher,
+) *controller.Impl {
+	logger := logging.FromContext(ctx)
+	secretInformer := secretinformer.Get(ctx)
+	key := types.NamespacedName{Namespace: system.Namespace(), Name: pkgwebhook.GetOptions(ctx).SecretName}
+
+	r := &secretReconciler{
+		LeaderAwareFuncs: pkgreconciler.LeaderAwareFuncs{
+			// Have this reconciler enqueue our singleton whenever it becomes leader.
+			PromoteFunc: func(bkt pkgreconciler.Bucket, enq func(pkgreconciler.Bucket, types.NamespacedName)) error {
+				enq(bkt, key)
+				return nil
+			},
+		},
+		key:          key,
+		kubeclient:   kubeclient.Get(ctx),
+		secretLister: secretInformer.Lister(),
+	}
+
+	const queueName = "WebhookSecret"
+	impl := controller.NewContext(ctx, r, controller.ControllerOptions{
+		WorkQueueName: queueName,
+		Logger:        logger.Named(queueName),
+	})
+
+	secretInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterWithNameAndNamespace(key.Namespace, key.Name),
+		Handler: cache.ResourceEventHandlerFuncs{
+			DeleteFunc: func(interface{}) { impl.EnqueueKey(key) },
+		},
+	})
+	return impl
+}
+
+// Reconcile implements controller.Reconciler. It creates the Secret if it's
+// missing.
+func (r *secretReconciler) Reconcile(ctx context.Context, key string) error {
+	if !r.IsLeaderFor(r.key) {
+		return controller.NewSkipKey(key)
+	}
+
+	_, err := r.secretLister.Secrets(r.key.Namespace).Get(r.key.Name)
+	if !apierrs.IsNotFound(err) {
+		return err
+	}
+	logging.FromContext(ctx).Info("Creating the webhook certificate Secret ", r.key)
+	_, err = r.kubeclient.CoreV1().Secrets(r.key.Namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.key.Name,
+			Namespace: r.key.Namespace,
+			Labels:    providerLabels,
+		},
+	}, metav1.CreateOptions{})
+	if apierrs.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}